@@ -10,7 +10,9 @@ import (
 	"github.com/alde/melkor/config"
 	"github.com/alde/melkor/crawlers"
 	"github.com/alde/melkor/server"
+	"github.com/alde/melkor/storage"
 	"github.com/alde/melkor/version"
+	"github.com/alde/melkor/webhook"
 
 	"github.com/braintree/manners"
 	"github.com/sirupsen/logrus"
@@ -18,7 +20,7 @@ import (
 
 func main() {
 	if len(os.Args) == 2 && os.Args[1] == "--version" {
-		fmt.Printf(version.Version)
+		fmt.Print(version.Version)
 		os.Exit(0)
 	}
 
@@ -26,9 +28,19 @@ func main() {
 
 	cfg := config.Initialize()
 	setupLogging(cfg)
+	if err := cfg.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid configuration")
+	}
 
-	crawlers := initializeCrawlers(cfg)
-	go doCrawl(crawlers, cfg)
+	ordered := initializeCrawlers(cfg)
+	crawlers := byResource(ordered)
+	store := openStorage(cfg, crawlers)
+	dispatcher, err := webhook.New(cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Unable to set up webhooks")
+	}
+	dispatcher.Watch(crawlers)
+	go doCrawl(ordered, cfg, store)
 
 	bind := fmt.Sprintf("%s:%d", cfg.Address, cfg.Port)
 	logrus.WithFields(logrus.Fields{
@@ -64,7 +76,33 @@ func catchInterrupt() {
 	os.Exit(0)
 }
 
-func doCrawl(crawlers melkor.Crawlers, cfg *config.Config) {
+// openStorage opens the configured storage and warm-starts the crawlers from
+// it. Melkor runs without storage if it cannot be opened.
+func openStorage(cfg *config.Config, crawlers melkor.Crawlers) storage.Store {
+	store, err := storage.Open(cfg)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to open storage, running without it")
+		cfg.Storage = "none"
+		store, _ = storage.Open(cfg)
+	}
+	for _, crawler := range crawlers {
+		if err := storage.Load(store, crawler); err != nil {
+			logrus.WithError(err).
+				WithField("resource", crawler.Resource()).
+				Warn("Unable to restore from storage")
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"resource":     crawler.Resource(),
+			"count":        crawler.Count(),
+			"last_crawled": crawler.LastCrawled(),
+		}).Info("Restored from storage")
+	}
+	return store
+}
+
+// doCrawl crawls every resource in turn, in order, forever
+func doCrawl(crawlers []melkor.Crawler, cfg *config.Config, store storage.Store) {
 	for {
 		for _, crawler := range crawlers {
 			if err := crawler.DoCrawl(); err != nil {
@@ -72,14 +110,43 @@ func doCrawl(crawlers melkor.Crawlers, cfg *config.Config) {
 					WithField("resource", crawler.Resource()).
 					Error("Error while crawling")
 			}
+			if err := storage.Save(store, crawler); err != nil {
+				logrus.WithError(err).
+					WithField("resource", crawler.Resource()).
+					Error("Error while saving to storage")
+			}
 		}
 		time.Sleep(time.Duration(cfg.CrawlInterval) * time.Second)
 	}
 }
 
-func initializeCrawlers(c *config.Config) melkor.Crawlers {
+// initializeCrawlers creates all crawlers, in the order they are crawled in.
+// Images count the instances launched from them, so instances come first.
+func initializeCrawlers(c *config.Config) []melkor.Crawler {
 	ic := crawlers.NewInstancesCrawler(c)
-	return melkor.Crawlers{
-		ic.Resource(): ic,
+	return []melkor.Crawler{
+		ic,
+		crawlers.NewImagesCrawler(c, ic),
+		crawlers.NewSecurityGroupsCrawler(c),
+		crawlers.NewVolumesCrawler(c),
+		crawlers.NewSnapshotsCrawler(c),
+		crawlers.NewVpcsCrawler(c),
+		crawlers.NewSubnetsCrawler(c),
+		crawlers.NewRouteTablesCrawler(c),
+		crawlers.NewInternetGatewaysCrawler(c),
+		crawlers.NewNatGatewaysCrawler(c),
+		crawlers.NewNetworkInterfacesCrawler(c),
+		crawlers.NewClassicLoadBalancersCrawler(c),
+		crawlers.NewLoadBalancersCrawler(c),
+		crawlers.NewTargetGroupsCrawler(c),
+	}
+}
+
+// byResource looks up crawlers by the name of their resource
+func byResource(all []melkor.Crawler) melkor.Crawlers {
+	data := make(melkor.Crawlers, len(all))
+	for _, crawler := range all {
+		data[crawler.Resource()] = crawler
 	}
+	return data
 }
@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
 
 	yaml "gopkg.in/yaml.v2"
 
@@ -23,9 +26,38 @@ type Config struct {
 
 	// CrawlInterval in seconds
 	CrawlInterval int `yaml:"crawl_interval" envconfig:"crawlinterval"`
+	// HistoryRetention in hours. Zero keeps history forever.
+	HistoryRetention int `yaml:"history_retention" envconfig:"historyretention"`
 
 	// AWS settings
-	AWSRegion string `yaml:"aws_region" envconfig:"awsregion"`
+	// - AWSRegions lists the regions to crawl. If empty, only AWSRegion is
+	//   crawled.
+	AWSRegion  string   `yaml:"aws_region" envconfig:"awsregion"`
+	AWSRegions []string `yaml:"aws_regions" envconfig:"awsregions"`
+	// - PageSize is the number of results requested per call when paging
+	//   through the AWS APIs, between MinPageSize and MaxPageSize. Zero
+	//   leaves it up to AWS.
+	PageSize int `yaml:"page_size" envconfig:"pagesize"`
+	// - Accounts to crawl by assuming a role in each of them. If empty, the
+	//   account of the default credentials is crawled.
+	Accounts []Account `yaml:"accounts" ignored:"true"`
+
+	// Storage settings
+	// - Storage is the backend persisting the crawled resources across
+	//   restarts: file, or none to turn it off
+	Storage string `yaml:"storage" envconfig:"storage"`
+	// - StoragePath is the directory the file backend keeps its files in
+	StoragePath string `yaml:"storage_path" envconfig:"storagepath"`
+
+	// Webhook settings
+	// - Webhooks to POST the changes found by every crawl to
+	Webhooks []Webhook `yaml:"webhooks" ignored:"true"`
+	// - WebhookRetries is the number of times a failed delivery is retried,
+	//   backing off exponentially
+	WebhookRetries int `yaml:"webhook_retries" envconfig:"webhookretries"`
+	// - WebhookDeadLetter is the file deliveries failing for good are
+	//   appended to. If empty, they are only logged.
+	WebhookDeadLetter string `yaml:"webhook_dead_letter" envconfig:"webhookdeadletter"`
 
 	// Service settings
 	// - Owner of the service. For example the team running it.
@@ -33,6 +65,81 @@ type Config struct {
 	Owner string `yaml:"owner" envconfig:"owner"`
 }
 
+// The page sizes the EC2 APIs accept
+const (
+	MinPageSize = 5
+	MaxPageSize = 1000
+)
+
+// Account is an AWS account crawled by assuming a role in it
+type Account struct {
+	RoleARN     string `yaml:"role_arn"`
+	ExternalID  string `yaml:"external_id"`
+	SessionName string `yaml:"session_name"`
+}
+
+// roleARN matches the ARN of an IAM role, such as
+// arn:aws:iam::123456789012:role/name
+var roleARN = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$`)
+
+// ID returns the account ID, as found in the role ARN
+func (a Account) ID() string {
+	// arn:aws:iam::123456789012:role/name
+	parts := strings.Split(a.RoleARN, ":")
+	if len(parts) < 5 {
+		return ""
+	}
+	return parts[4]
+}
+
+// Webhook is a URL to POST changes to. Resources, Events and Filter narrow
+// down the changes sent, all of them are sent if left empty.
+type Webhook struct {
+	// Name identifies the webhook in logs and metrics. Defaults to the host
+	// of the URL, as the URL itself may hold secrets.
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// Secret signs the payloads, if set
+	Secret    string   `yaml:"secret"`
+	Resources []string `yaml:"resources"`
+	Events    []string `yaml:"events"`
+	Filter    string   `yaml:"filter"`
+}
+
+// ID returns the name of the webhook
+func (w Webhook) ID() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	if u, err := url.Parse(w.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "webhook"
+}
+
+// Regions returns the regions to crawl
+func (c *Config) Regions() []string {
+	if len(c.AWSRegions) > 0 {
+		return c.AWSRegions
+	}
+	return []string{c.AWSRegion}
+}
+
+// Validate reports settings which would make every crawl fail, or crawl
+// accounts under the wrong id
+func (c *Config) Validate() error {
+	if c.PageSize != 0 && (c.PageSize < MinPageSize || c.PageSize > MaxPageSize) {
+		return fmt.Errorf("page_size must be between %d and %d, or 0 to leave it up to AWS, got %d",
+			MinPageSize, MaxPageSize, c.PageSize)
+	}
+	for idx, a := range c.Accounts {
+		if !roleARN.MatchString(a.RoleARN) {
+			return fmt.Errorf("accounts[%d]: role_arn %q is not the ARN of an IAM role", idx, a.RoleARN)
+		}
+	}
+	return nil
+}
+
 // Initialize a new Config
 func Initialize() *Config {
 	cfg := DefaultConfig()
@@ -51,9 +158,16 @@ func DefaultConfig() *Config {
 		LogLevel:  "debug",
 		LogFormat: "text",
 
-		CrawlInterval: 600,
+		CrawlInterval:    600,
+		HistoryRetention: 168,
+
+		Storage:     "file",
+		StoragePath: "/var/lib/melkor",
+
+		WebhookRetries: 5,
 
 		AWSRegion: "eu-west-1",
+		PageSize:  1000,
 
 		Owner: os.Getenv("USER"),
 	}
@@ -12,9 +12,15 @@ func Test_DefaultConfig(t *testing.T) {
 	c := DefaultConfig()
 	assert := assert.New(t)
 	assert.Equal(c.AWSRegion, "eu-west-1")
+	assert.Equal(c.PageSize, 1000)
+	assert.Equal(c.Regions(), []string{"eu-west-1"})
 	assert.Equal(c.Address, "0.0.0.0")
 	assert.Equal(c.Port, 7654)
 	assert.Equal(c.CrawlInterval, 600)
+	assert.Equal(c.HistoryRetention, 168)
+	assert.Equal(c.Storage, "file")
+	assert.Equal(c.StoragePath, "/var/lib/melkor")
+	assert.Equal(c.WebhookRetries, 5)
 	assert.Equal(c.LogFormat, "text")
 	assert.Equal(c.LogLevel, "debug")
 	assert.Equal(c.Owner, os.Getenv("USER"))
@@ -30,7 +36,10 @@ func Test_ReadEnvironment(t *testing.T) {
 	os.Setenv("MELKOR_LOGLEVEL", "error")
 	os.Setenv("MELKOR_LOGFORMAT", "json")
 	os.Setenv("MELKOR_CRAWLINTERVAL", "500")
+	os.Setenv("MELKOR_HISTORYRETENTION", "48")
 	os.Setenv("MELKOR_AWSREGION", "eu-east-2")
+	os.Setenv("MELKOR_AWSREGIONS", "eu-west-1,us-east-1")
+	os.Setenv("MELKOR_PAGESIZE", "50")
 	os.Setenv("MELKOR_OWNER", "the_boss")
 
 	ReadEnvironment(c)
@@ -40,13 +49,19 @@ func Test_ReadEnvironment(t *testing.T) {
 	os.Unsetenv("MELKOR_LOGLEVEL")
 	os.Unsetenv("MELKOR_LOGFORMAT")
 	os.Unsetenv("MELKOR_CRAWLINTERVAL")
+	os.Unsetenv("MELKOR_HISTORYRETENTION")
 	os.Unsetenv("MELKOR_AWSREGION")
+	os.Unsetenv("MELKOR_AWSREGIONS")
+	os.Unsetenv("MELKOR_PAGESIZE")
 	os.Unsetenv("MELKOR_OWNER")
 
 	assert.Equal(c.AWSRegion, "eu-east-2")
+	assert.Equal(c.Regions(), []string{"eu-west-1", "us-east-1"})
+	assert.Equal(c.PageSize, 50)
 	assert.Equal(c.Address, "10.0.0.0")
 	assert.Equal(c.Port, 9090)
 	assert.Equal(c.CrawlInterval, 500)
+	assert.Equal(c.HistoryRetention, 48)
 	assert.Equal(c.LogFormat, "json")
 	assert.Equal(c.LogLevel, "error")
 	assert.Equal(c.Owner, "the_boss")
@@ -59,9 +74,39 @@ func Test_ReadConfigFile(t *testing.T) {
 
 	ReadConfigFile(c, fmt.Sprintf("%s/config_test.yml", wd))
 	assert.Equal(c.AWSRegion, "us-east-1")
+	assert.Equal(c.PageSize, 100)
+	assert.Equal(c.Regions(), []string{"us-east-1", "us-west-2"})
+	assert.Equal(c.Accounts, []Account{
+		{
+			RoleARN:     "arn:aws:iam::111111111111:role/melkor",
+			ExternalID:  "secret",
+			SessionName: "melkor-test",
+		},
+		{
+			RoleARN: "arn:aws:iam::222222222222:role/melkor",
+		},
+	})
 	assert.Equal(c.Address, "127.0.0.1")
 	assert.Equal(c.Port, 8080)
 	assert.Equal(c.CrawlInterval, 3600)
+	assert.Equal(c.HistoryRetention, 24)
+	assert.Equal(c.Storage, "none")
+	assert.Equal(c.StoragePath, "/tmp/melkor")
+	assert.Equal(c.Webhooks, []Webhook{
+		{
+			Name:      "chatops",
+			URL:       "https://chat.example.com/hooks/melkor",
+			Secret:    "s3cret",
+			Resources: []string{"instances"},
+			Events:    []string{"added", "removed"},
+			Filter:    "(Tags.Environment:production)",
+		},
+		{
+			URL: "https://audit.example.com/melkor",
+		},
+	})
+	assert.Equal(c.WebhookRetries, 3)
+	assert.Equal(c.WebhookDeadLetter, "/var/log/melkor/dead-letter.log")
 	assert.Equal(c.LogFormat, "json")
 	assert.Equal(c.LogLevel, "info")
 	assert.Equal(c.Owner, "the_team")
@@ -87,3 +132,41 @@ func Test_Initialize(t *testing.T) {
 
 	assert.Equal(t, c, d)
 }
+
+func Test_Validate(t *testing.T) {
+	assert.Nil(t, DefaultConfig().Validate())
+
+	for size, valid := range map[int]bool{0: true, 4: false, 5: true, 1000: true, 1001: false, -1: false} {
+		c := DefaultConfig()
+		c.PageSize = size
+		assert.Equal(t, valid, c.Validate() == nil, "page_size %d", size)
+	}
+
+	for arn, valid := range map[string]bool{
+		"arn:aws:iam::111111111111:role/melkor":        true,
+		"arn:aws:iam::111111111111:role/path/melkor":   true,
+		"arn:aws-us-gov:iam::111111111111:role/melkor": true,
+		"not-an-arn":                                       false,
+		"arn:aws:iam::1111:role/melkor":                    false,
+		"arn:aws:iam::111111111111:user/melkor":            false,
+		"arn:aws:sts::111111111111:assumed-role/melkor/me": false,
+	} {
+		c := DefaultConfig()
+		c.Accounts = []Account{{RoleARN: arn}}
+		assert.Equal(t, valid, c.Validate() == nil, arn)
+	}
+}
+
+func Test_Account_ID(t *testing.T) {
+	a := Account{RoleARN: "arn:aws:iam::111111111111:role/melkor"}
+	assert.Equal(t, "111111111111", a.ID())
+
+	b := Account{RoleARN: "not-an-arn"}
+	assert.Equal(t, "", b.ID())
+}
+
+func Test_Webhook_ID(t *testing.T) {
+	assert.Equal(t, "chatops", Webhook{Name: "chatops", URL: "https://chat.example.com/x"}.ID())
+	assert.Equal(t, "chat.example.com", Webhook{URL: "https://chat.example.com/hooks/secret"}.ID())
+	assert.Equal(t, "webhook", Webhook{URL: "not a url"}.ID())
+}
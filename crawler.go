@@ -5,10 +5,29 @@ import (
 	"time"
 )
 
-// The Crawler interface sets up the contract for a crawler
+// The Crawler interface sets up the contract for a crawler.
+//
+// DoCrawl is called from a single goroutine while the other methods are called
+// concurrently from any number of HTTP handlers. Implementations must never
+// mutate state visible to readers in place; instead every completed crawl
+// swaps in a new immutable Snapshot. List, ListExpanded, Get, LastCrawled and
+// Count are shorthands for the same methods on the current Snapshot, callers
+// needing several of them to agree should fetch the Snapshot once instead.
+//
+// Every completed crawl is also recorded in the History of the crawler, and the
+// changes it found are handed to the functions registered with OnChange. These
+// are called from the crawling goroutine, in order, and must not block.
+//
+// Restore warm-starts a crawler from its History as marshalled to JSON, before
+// it is first crawled.
 type Crawler interface {
 	DoCrawl() error
 	Resource() string
+	Snapshot() *Snapshot
+	History() *History
+	OnChange(fn func(Changeset))
+	Restore(data []byte) error
+	Status() []CrawlStatus
 	List() []string
 	ListExpanded() []map[string]interface{}
 	Get(id string) map[string]interface{}
@@ -16,13 +35,30 @@ type Crawler interface {
 	Count() int
 }
 
+// CrawlStatus describes the most recent crawl of a single account and region.
+// It is Restored while the resources served are the ones restored at startup.
+type CrawlStatus struct {
+	Scope
+	LastCrawled time.Time `json:"last_crawled"`
+	LastAttempt time.Time `json:"last_attempt"`
+	Count       int       `json:"count"`
+	Restored    bool      `json:"restored,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
 // The Crawlers struct holds all the creepy crawlies
 type Crawlers map[string]Crawler
 
-// Get fetches a Crawler case-insensitively.
+// Get fetches a Crawler case-insensitively, so that SecurityGroups is found
+// as securitygroups.
 func (c Crawlers) Get(r string) Crawler {
-	if val, ok := c[strings.Title(strings.ToLower(r))]; ok {
+	if val, ok := c[r]; ok {
 		return val
 	}
+	for name, val := range c {
+		if strings.EqualFold(name, r) {
+			return val
+		}
+	}
 	return nil
 }
@@ -0,0 +1,24 @@
+package melkor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// named is a Crawler telling crawlers apart by name only
+type named struct {
+	Crawler
+	name string
+}
+
+func Test_Crawlers_Get(t *testing.T) {
+	instances, groups := &named{name: "Instances"}, &named{name: "SecurityGroups"}
+	c := Crawlers{"Instances": instances, "SecurityGroups": groups}
+
+	assert.Equal(t, instances, c.Get("instances"))
+	assert.Equal(t, instances, c.Get("Instances"))
+	assert.Equal(t, groups, c.Get("securitygroups"))
+	assert.Equal(t, groups, c.Get("SecurityGroups"))
+	assert.Nil(t, c.Get("volumes"))
+}
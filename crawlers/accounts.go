@@ -0,0 +1,83 @@
+package crawlers
+
+import (
+	"time"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	defaultSessionName = "melkor"
+	// expiryWindow renews assumed credentials this long before they expire
+	expiryWindow = time.Minute
+)
+
+// scopes lists every account and region to crawl, in order
+func scopes(c *config.Config) []melkor.Scope {
+	var data []melkor.Scope
+	for _, region := range c.Regions() {
+		if len(c.Accounts) == 0 {
+			data = append(data, melkor.Scope{Region: region})
+			continue
+		}
+		for _, a := range c.Accounts {
+			data = append(data, melkor.Scope{Account: a.ID(), Region: region})
+		}
+	}
+	melkor.SortScopes(data)
+	return data
+}
+
+// newSessions creates a session for every account and region to crawl
+func newSessions(c *config.Config) map[melkor.Scope]*session.Session {
+	sess := session.Must(session.NewSession())
+	return sessions(c, sess, sts.New(sess, stsConfig(c)))
+}
+
+// stsConfig configures the client assuming the roles of crawled accounts. STS
+// needs a region like any other API, the first one crawled is used rather
+// than relying on AWS_REGION being set.
+func stsConfig(c *config.Config) *aws.Config {
+	return aws.NewConfig().WithRegion(c.Regions()[0])
+}
+
+// sessions derives a session for every account and region to crawl from sess.
+// Configured accounts are crawled with the credentials of their role, assumed
+// through client, otherwise the credentials of sess are used.
+func sessions(c *config.Config, sess *session.Session, client stscreds.AssumeRoler) map[melkor.Scope]*session.Session {
+	creds := make(map[string]*credentials.Credentials)
+	for _, a := range c.Accounts {
+		creds[a.ID()] = stscreds.NewCredentialsWithClient(client, a.RoleARN, assumeRoleOptions(a))
+	}
+
+	data := make(map[melkor.Scope]*session.Session)
+	for _, sc := range scopes(c) {
+		cfg := &aws.Config{Region: aws.String(sc.Region)}
+		if cr, ok := creds[sc.Account]; ok {
+			cfg.Credentials = cr
+		}
+		data[sc] = sess.Copy(cfg)
+	}
+	return data
+}
+
+// assumeRoleOptions sets up the provider assuming the role of an account
+func assumeRoleOptions(a config.Account) func(*stscreds.AssumeRoleProvider) {
+	return func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = a.SessionName
+		if p.RoleSessionName == "" {
+			p.RoleSessionName = defaultSessionName
+		}
+		if a.ExternalID != "" {
+			p.ExternalID = aws.String(a.ExternalID)
+		}
+		p.ExpiryWindow = expiryWindow
+	}
+}
@@ -0,0 +1,108 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+var testAccounts = []config.Account{
+	{
+		RoleARN:     "arn:aws:iam::222222222222:role/melkor",
+		ExternalID:  "secret",
+		SessionName: "melkor-test",
+	},
+	{
+		RoleARN: "arn:aws:iam::111111111111:role/melkor",
+	},
+}
+
+func Test_scopes_DefaultAccount(t *testing.T) {
+	c := &config.Config{AWSRegions: []string{"us-east-1", "eu-west-1"}}
+
+	assert.Equal(t, []melkor.Scope{
+		{Region: "eu-west-1"},
+		{Region: "us-east-1"},
+	}, scopes(c))
+}
+
+func Test_scopes_Accounts(t *testing.T) {
+	c := &config.Config{
+		AWSRegions: []string{"us-east-1", "eu-west-1"},
+		Accounts:   testAccounts,
+	}
+
+	assert.Equal(t, []melkor.Scope{
+		{Account: "111111111111", Region: "eu-west-1"},
+		{Account: "111111111111", Region: "us-east-1"},
+		{Account: "222222222222", Region: "eu-west-1"},
+		{Account: "222222222222", Region: "us-east-1"},
+	}, scopes(c))
+}
+
+func Test_sessions_AssumeRole(t *testing.T) {
+	c := &config.Config{
+		AWSRegions: []string{"eu-west-1"},
+		Accounts:   testAccounts,
+	}
+	mc := &mock.STSClient{}
+	sess := session.Must(session.NewSession())
+
+	actual := sessions(c, sess, mc)
+	assert.Len(t, actual, 2)
+	assert.False(t, mc.AssumeRoleFnInvoked, "roles are assumed lazily")
+
+	s := actual[melkor.Scope{Account: "222222222222", Region: "eu-west-1"}]
+	assert.Equal(t, "eu-west-1", aws.StringValue(s.Config.Region))
+	v, err := s.Config.Credentials.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, "AKIAMOCK", v.AccessKeyID)
+	assert.Equal(t, "mock-token", v.SessionToken)
+
+	assert.Len(t, mc.AssumeRoleInputs, 1)
+	in := mc.AssumeRoleInputs[0]
+	assert.Equal(t, "arn:aws:iam::222222222222:role/melkor", aws.StringValue(in.RoleArn))
+	assert.Equal(t, "secret", aws.StringValue(in.ExternalId))
+	assert.Equal(t, "melkor-test", aws.StringValue(in.RoleSessionName))
+}
+
+func Test_sessions_AssumeRole_Defaults(t *testing.T) {
+	c := &config.Config{AWSRegion: "eu-west-1", Accounts: testAccounts[1:]}
+	mc := &mock.STSClient{}
+
+	s := sessions(c, session.Must(session.NewSession()), mc)[melkor.Scope{Account: "111111111111", Region: "eu-west-1"}]
+	_, err := s.Config.Credentials.Get()
+	assert.Nil(t, err)
+
+	in := mc.AssumeRoleInputs[0]
+	assert.Nil(t, in.ExternalId)
+	assert.Equal(t, defaultSessionName, aws.StringValue(in.RoleSessionName))
+}
+
+func Test_sessions_AssumeRole_Fail(t *testing.T) {
+	c := &config.Config{AWSRegion: "eu-west-1", Accounts: testAccounts[1:]}
+	mc := &mock.STSClient{
+		AssumeRoleFn: func(*sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+			return nil, errors.New("access denied")
+		},
+	}
+
+	s := sessions(c, session.Must(session.NewSession()), mc)[melkor.Scope{Account: "111111111111", Region: "eu-west-1"}]
+	_, err := s.Config.Credentials.Get()
+	assert.NotNil(t, err)
+}
+
+func Test_stsConfig(t *testing.T) {
+	c := &config.Config{AWSRegion: "eu-west-1", AWSRegions: []string{"us-east-1", "eu-west-1"}}
+
+	assert.Equal(t, "us-east-1", aws.StringValue(stsConfig(c).Region))
+	assert.Equal(t, "eu-west-1", aws.StringValue(stsConfig(&config.Config{AWSRegion: "eu-west-1"}).Region))
+}
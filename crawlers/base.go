@@ -0,0 +1,266 @@
+package crawlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+)
+
+// base holds what all crawlers have in common: one snapshot per account and
+// region, merged into the snapshot served to readers, and the crawl status of
+// each of them. Crawlers embed it and only implement the fetching of items.
+//
+// The zero value is ready to use, keeping history forever and restoring every
+// scope persisted.
+type base struct {
+	// snapshot holds the *melkor.Snapshot merging all scopes. It is only
+	// ever replaced, never modified, so readers need no locking.
+	snapshot atomic.Value
+
+	mu     sync.RWMutex
+	parts  map[melkor.Scope]*melkor.Snapshot
+	status map[melkor.Scope]melkor.CrawlStatus
+	// history records every committed snapshot
+	history *melkor.History
+	// listeners are told about the changes of every committed snapshot
+	listeners []func(melkor.Changeset)
+	// generation counts the merged snapshots swapped in
+	generation uint64
+	// configured lists the scopes to crawl, only these are restored. Nil
+	// restores all of them.
+	configured []melkor.Scope
+}
+
+// newBase creates a base for crawling the accounts and regions configured
+func newBase(c *config.Config) base {
+	return base{
+		history:    melkor.NewHistory(retention(c)),
+		configured: scopes(c),
+	}
+}
+
+// fetchFunc fetches all items of a single account and region
+type fetchFunc func(scope melkor.Scope) ([]melkor.Item, error)
+
+// crawl fetches every scope in turn, then swaps in the result of all of them
+// at once, so that readers never see a crawl half done. A scope failing to be
+// crawled keeps its previous snapshot, the errors of all failed scopes are
+// returned together. The unrecorded fields of expanded items are kept out of
+// the history, see melkor.Snapshot.WithUnrecorded.
+func (b *base) crawl(scopes []melkor.Scope, expand melkor.ExpandFunc, fetch fetchFunc, unrecorded ...string) error {
+	attempt := time.Now()
+	fetched := make(map[melkor.Scope][]melkor.Item, len(scopes))
+	var failures []string
+	for _, scope := range scopes {
+		items, err := fetch(scope)
+		if err != nil {
+			b.fail(scope, attempt, err)
+			failures = append(failures, fmt.Sprintf("%s: %s", scope, err))
+			continue
+		}
+		for idx := range items {
+			items[idx].Account = scope.Account
+			items[idx].Region = scope.Region
+		}
+		fetched[scope] = items
+	}
+
+	crawled := time.Now()
+	parts := make(map[melkor.Scope]*melkor.Snapshot, len(fetched))
+	for scope, items := range fetched {
+		parts[scope] = melkor.NewSnapshot(crawled, items, expand).WithUnrecorded(unrecorded...)
+	}
+	b.commit(attempt, parts)
+
+	if len(failures) > 0 {
+		return fmt.Errorf("crawling failed in %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// commit swaps in new snapshots for the scopes crawled, records them in the
+// history and tells the listeners about the changes found
+func (b *base) commit(attempt time.Time, parts map[melkor.Scope]*melkor.Snapshot) {
+	if len(parts) == 0 {
+		return
+	}
+	changesets, listeners := b.swap(attempt, parts)
+	for _, cs := range changesets {
+		for _, fn := range listeners {
+			fn(cs)
+		}
+	}
+}
+
+func (b *base) swap(attempt time.Time, crawled map[melkor.Scope]*melkor.Snapshot) ([]melkor.Changeset, []func(melkor.Changeset)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changesets := b.loadHistory().RecordAll(crawled)
+
+	parts := make(map[melkor.Scope]*melkor.Snapshot, len(b.parts)+len(crawled))
+	for sc, p := range b.parts {
+		parts[sc] = p
+	}
+	for sc, s := range crawled {
+		parts[sc] = s
+		b.setStatus(melkor.CrawlStatus{
+			Scope:       sc,
+			LastCrawled: s.CrawledAt(),
+			LastAttempt: attempt,
+			Count:       s.Count(),
+		})
+	}
+	b.parts = parts
+	b.store()
+	return changesets, b.listeners
+}
+
+// store swaps in the merge of all scopes as the next generation. Callers must
+// hold the lock.
+func (b *base) store() {
+	b.generation++
+	b.snapshot.Store(melkor.Merge(b.parts).WithGeneration(b.generation))
+}
+
+// fail records a failed crawl of a scope
+func (b *base) fail(scope melkor.Scope, attempt time.Time, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := b.status[scope]
+	st.Scope = scope
+	st.LastAttempt = attempt
+	st.Error = err.Error()
+	b.setStatus(st)
+}
+
+func (b *base) setStatus(st melkor.CrawlStatus) {
+	if b.status == nil {
+		b.status = make(map[melkor.Scope]melkor.CrawlStatus)
+	}
+	b.status[st.Scope] = st
+}
+
+// Snapshot returns the result of the most recent completed crawl, or an empty
+// Snapshot if no crawl has completed yet
+func (b *base) Snapshot() *melkor.Snapshot {
+	if s, ok := b.snapshot.Load().(*melkor.Snapshot); ok {
+		return s
+	}
+	return &melkor.Snapshot{}
+}
+
+// History returns the history of all crawls
+func (b *base) History() *melkor.History {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.loadHistory()
+}
+
+// Restore replaces the history with a persisted one, and swaps in the last
+// crawled snapshot of every scope in it which is still configured. Accounts and
+// regions no longer crawled stay in the history, but are not served as current.
+func (b *base) Restore(data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := b.loadHistory()
+	if err := json.Unmarshal(data, h); err != nil {
+		return err
+	}
+	b.parts = h.Latest()
+	if b.configured != nil {
+		for scope := range b.parts {
+			if !containsScope(b.configured, scope) {
+				delete(b.parts, scope)
+			}
+		}
+	}
+	b.store()
+	for scope, s := range b.parts {
+		b.setStatus(melkor.CrawlStatus{
+			Scope:       scope,
+			LastCrawled: s.CrawledAt(),
+			Count:       s.Count(),
+			Restored:    true,
+		})
+	}
+	return nil
+}
+
+// OnChange registers a function to be told about the changes of every crawl
+func (b *base) OnChange(fn func(melkor.Changeset)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, fn)
+}
+
+// loadHistory returns the history, creating it if needed. Callers must hold
+// the lock.
+func (b *base) loadHistory() *melkor.History {
+	if b.history == nil {
+		b.history = &melkor.History{}
+	}
+	return b.history
+}
+
+// Status reports the most recent crawl of each account and region, in order
+func (b *base) Status() []melkor.CrawlStatus {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	data := make([]melkor.CrawlStatus, 0, len(b.status))
+	for _, st := range b.status {
+		data = append(data, st)
+	}
+	sort.Sort(byStatusScope(data))
+	return data
+}
+
+// LastCrawled is the timestamp of the most recent crawl
+func (b *base) LastCrawled() time.Time {
+	return b.Snapshot().CrawledAt()
+}
+
+// List the ids of all crawled items
+func (b *base) List() []string {
+	return b.Snapshot().List()
+}
+
+// ListExpanded expands the result
+func (b *base) ListExpanded() []map[string]interface{} {
+	return b.Snapshot().ListExpanded()
+}
+
+// Get returns a single item by id
+func (b *base) Get(id string) map[string]interface{} {
+	return b.Snapshot().Get(id)
+}
+
+// Count the number of items crawled
+func (b *base) Count() int {
+	return b.Snapshot().Count()
+}
+
+func containsScope(scopes []melkor.Scope, scope melkor.Scope) bool {
+	for _, sc := range scopes {
+		if sc == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type byStatusScope []melkor.CrawlStatus
+
+func (s byStatusScope) Len() int           { return len(s) }
+func (s byStatusScope) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byStatusScope) Less(i, j int) bool { return s[i].Scope.Less(s[j].Scope) }
@@ -0,0 +1,125 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/fatih/structs"
+	"github.com/sirupsen/logrus"
+)
+
+type classicLoadBalancersClient interface {
+	DescribeLoadBalancers(*elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error)
+	DescribeInstanceHealth(*elb.DescribeInstanceHealthInput) (*elb.DescribeInstanceHealthOutput, error)
+}
+
+// classicInService is the state of instances passing the health check of a
+// classic load balancer
+const classicInService = "InService"
+
+// A classicLoadBalancer is a classic load balancer along with the health of
+// its instances, as of when it was crawled
+type classicLoadBalancer struct {
+	*elb.LoadBalancerDescription
+	health []*elb.InstanceState
+}
+
+// The ClassicLoadBalancersCrawler crawls the classic load balancers of every
+// account and region, along with the health of the instances behind them
+type ClassicLoadBalancersCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]classicLoadBalancersClient
+}
+
+// NewClassicLoadBalancersCrawler is the constructor of this crawler
+func NewClassicLoadBalancersCrawler(c *config.Config) *ClassicLoadBalancersCrawler {
+	clients := make(map[melkor.Scope]classicLoadBalancersClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = elb.New(sess)
+	}
+	return &ClassicLoadBalancersCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (l *ClassicLoadBalancersCrawler) Resource() string {
+	return "ClassicLoadBalancers"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (l *ClassicLoadBalancersCrawler) DoCrawl() error {
+	logrus.WithField("resource", l.Resource()).Info("Crawling")
+
+	err := l.crawl(scopes(l.config), expandClassicLoadBalancer, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return l.describeLoadBalancers(l.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": l.Resource(),
+		"count":    l.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeLoadBalancers walks all pages of classic load balancers, asking for
+// the health of the instances of each. A failing call fails the whole crawl,
+// leaving the previous snapshot in place.
+func (l *ClassicLoadBalancersCrawler) describeLoadBalancers(client classicLoadBalancersClient) ([]melkor.Item, error) {
+	params := &elb.DescribeLoadBalancersInput{
+		PageSize: elbPageSize(l.config),
+	}
+	var items []melkor.Item
+	for page := 1; ; page++ {
+		resp, err := client.DescribeLoadBalancers(params)
+		if err != nil {
+			return nil, fmt.Errorf("describing classic load balancers, page %d: %s", page, err)
+		}
+
+		for _, lb := range resp.LoadBalancerDescriptions {
+			name := aws.StringValue(lb.LoadBalancerName)
+			health, err := client.DescribeInstanceHealth(&elb.DescribeInstanceHealthInput{
+				LoadBalancerName: lb.LoadBalancerName,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("describing instance health of %s: %s", name, err)
+			}
+			items = append(items, melkor.Item{
+				ID:    name,
+				Value: &classicLoadBalancer{lb, health.InstanceStates},
+			})
+		}
+
+		if aws.StringValue(resp.NextMarker) == "" {
+			return items, nil
+		}
+		params.Marker = resp.NextMarker
+	}
+}
+
+// expandClassicLoadBalancer expands a classic load balancer, adding the
+// HealthyInstanceIds of the instances in service behind it
+func expandClassicLoadBalancer(item interface{}) map[string]interface{} {
+	lb := item.(*classicLoadBalancer)
+	data := structs.Map(lb.LoadBalancerDescription)
+	states := []interface{}{}
+	healthy := []string{}
+	for _, s := range lb.health {
+		states = append(states, structs.Map(s))
+		if aws.StringValue(s.State) == classicInService {
+			healthy = append(healthy, aws.StringValue(s.InstanceId))
+		}
+	}
+	data["InstanceStates"] = states
+	data["HealthyInstanceIds"] = healthy
+	return data
+}
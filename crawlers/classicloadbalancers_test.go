@@ -0,0 +1,96 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/fixtures"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClassicLoadBalancersCrawler(client classicLoadBalancersClient) *ClassicLoadBalancersCrawler {
+	return &ClassicLoadBalancersCrawler{
+		config:  &config.Config{AWSRegion: testRegion, PageSize: 1000},
+		clients: map[melkor.Scope]classicLoadBalancersClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_ClassicLoadBalancers_Resource(t *testing.T) {
+	lc := NewClassicLoadBalancersCrawler(&config.Config{})
+
+	assert.Equal(t, "ClassicLoadBalancers", lc.Resource())
+}
+
+func Test_ClassicLoadBalancers_DoCrawl(t *testing.T) {
+	mc := &mock.ELBClient{}
+	lc := newTestClassicLoadBalancersCrawler(mc)
+
+	err := lc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeLoadBalancersFnInvoked)
+	assert.True(t, mc.DescribeInstanceHealthFnInvoked)
+	assert.Equal(t, []string{"elb-0", "elb-1"}, lc.List())
+}
+
+func Test_ClassicLoadBalancers_DoCrawl_Pages(t *testing.T) {
+	lbs := fixtures.ClassicLoadBalancers(3)
+	mc := &mock.ELBClient{
+		DescribeLoadBalancersPages: []*elb.DescribeLoadBalancersOutput{
+			{LoadBalancerDescriptions: lbs[:2]},
+			{LoadBalancerDescriptions: lbs[2:]},
+		},
+	}
+	lc := newTestClassicLoadBalancersCrawler(mc)
+
+	err := lc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"elb-0", "elb-1", "elb-2"}, lc.List())
+	for _, in := range mc.DescribeLoadBalancersInputs {
+		assert.Equal(t, int64(maxELBPageSize), aws.Int64Value(in.PageSize))
+	}
+}
+
+func Test_ClassicLoadBalancers_Expand(t *testing.T) {
+	lc := newTestClassicLoadBalancersCrawler(&mock.ELBClient{})
+	assert.Nil(t, lc.DoCrawl())
+
+	data := lc.Get("elb-1")
+	assert.Equal(t, []interface{}{"i-1"}, data["HealthyInstanceIds"])
+	assert.Len(t, data["InstanceStates"], 2)
+	assert.Equal(t, []string{"elb-0"}, matching(t, lc, "InstanceStates.(InstanceId:i-1 AND State:OutOfService)"))
+	assert.Equal(t, []string{"elb-0", "elb-1"}, matching(t, lc, "ListenerDescriptions.Listener.InstancePort:8080"))
+}
+
+func Test_ClassicLoadBalancers_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.ELBClient{
+		DescribeLoadBalancersFn: func(*elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	lc := newTestClassicLoadBalancersCrawler(mc)
+
+	err := lc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, lc.Count())
+}
+
+func Test_ClassicLoadBalancers_DoCrawl_HealthFail(t *testing.T) {
+	mc := &mock.ELBClient{
+		DescribeInstanceHealthFn: func(*elb.DescribeInstanceHealthInput) (*elb.DescribeInstanceHealthOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	lc := newTestClassicLoadBalancersCrawler(mc)
+
+	err := lc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, lc.Count())
+}
@@ -0,0 +1,49 @@
+package crawlers
+
+import (
+	"time"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/fatih/structs"
+)
+
+// pageSize returns the MaxResults to request per page, or nil to let AWS
+// decide
+func pageSize(c *config.Config) *int64 {
+	if c.PageSize <= 0 {
+		return nil
+	}
+	return aws.Int64(int64(c.PageSize))
+}
+
+// maxELBPageSize is the largest page either version of the ELB API hands out
+const maxELBPageSize = 400
+
+// elbPageSize returns the PageSize to request from the ELB APIs, which allow
+// smaller pages than EC2, or nil to let AWS decide
+func elbPageSize(c *config.Config) *int64 {
+	size := pageSize(c)
+	if size != nil && *size > maxELBPageSize {
+		return aws.Int64(maxELBPageSize)
+	}
+	return size
+}
+
+// retention returns how long to keep history for, zero meaning forever
+func retention(c *config.Config) time.Duration {
+	if c.HistoryRetention <= 0 {
+		return 0
+	}
+	return time.Duration(c.HistoryRetention) * time.Hour
+}
+
+// expandTagged expands an item holding its tags as a list of ec2.Tag, making
+// them easier to filter on with melkor.ModifyTags
+func expandTagged(item interface{}) map[string]interface{} {
+	data := structs.Map(item)
+	melkor.ModifyTags(data["Tags"])
+	return data
+}
@@ -0,0 +1,27 @@
+package crawlers
+
+import (
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/filter"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testRegion is the region test crawlers crawl
+const testRegion = "eu-west-1"
+
+// matching lists the ids of the items of a crawler matching a filter
+func matching(t *testing.T, c melkor.Crawler, expr string) []string {
+	f, err := filter.Parse(expr)
+	assert.Nil(t, err, expr)
+	s := c.Snapshot()
+	var ids []string
+	for idx := 0; idx < s.Count(); idx++ {
+		if f.Match(s.Expand(idx)) {
+			ids = append(ids, s.Item(idx).ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,119 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/sirupsen/logrus"
+)
+
+type imagesClient interface {
+	DescribeImages(*ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error)
+}
+
+// The ImagesCrawler crawls the AMIs owned by or shared with every account, in
+// every region. Each image is annotated with the InstanceCount of cached
+// instances launched from it, across all accounts, as of when it was crawled.
+// It is left out until the instances have been crawled, and is not recorded in
+// the history, so that launching an instance does not modify its image.
+type ImagesCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]imagesClient
+	// instances is the crawler of the instances to count per image
+	instances melkor.Crawler
+}
+
+// NewImagesCrawler is the constructor of this crawler, counting the instances
+// cached by another crawler
+func NewImagesCrawler(c *config.Config, instances melkor.Crawler) *ImagesCrawler {
+	clients := make(map[melkor.Scope]imagesClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
+	return &ImagesCrawler{
+		base:      newBase(c),
+		config:    c,
+		clients:   clients,
+		instances: instances,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (i *ImagesCrawler) Resource() string {
+	return "Images"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (i *ImagesCrawler) DoCrawl() error {
+	logrus.WithField("resource", i.Resource()).Info("Crawling")
+
+	expand := expandTagged
+	if instances := i.instances.Snapshot(); !instances.CrawledAt().IsZero() {
+		counts := launches(instances)
+		expand = func(item interface{}) map[string]interface{} {
+			data := expandTagged(item)
+			data["InstanceCount"] = counts[aws.StringValue(item.(*ec2.Image).ImageId)]
+			return data
+		}
+	} else {
+		logrus.WithField("resource", i.Resource()).Warn("Instances not crawled yet, leaving out InstanceCount")
+	}
+	err := i.crawl(scopes(i.config), expand, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return i.describeImages(i.clients[scope])
+	}, "InstanceCount")
+
+	logrus.WithFields(logrus.Fields{
+		"resource": i.Resource(),
+		"count":    i.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeImages fetches the images owned by the account, then those it has
+// been given launch permissions for. Images found by both are listed once.
+func (i *ImagesCrawler) describeImages(client imagesClient) ([]melkor.Item, error) {
+	inputs := []*ec2.DescribeImagesInput{
+		{Owners: aws.StringSlice([]string{ownerSelf})},
+		{ExecutableUsers: aws.StringSlice([]string{ownerSelf})},
+	}
+	var items []melkor.Item
+	seen := make(map[string]bool)
+	for _, params := range inputs {
+		resp, err := client.DescribeImages(params)
+		if err != nil {
+			return nil, fmt.Errorf("describing images: %s", err)
+		}
+		for _, img := range resp.Images {
+			id := aws.StringValue(img.ImageId)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			items = append(items, melkor.Item{
+				ID:    id,
+				Value: img,
+			})
+		}
+	}
+	return items, nil
+}
+
+// launches counts the instances of a snapshot by the ImageId they were
+// launched from. AMI ids are unique across regions, so instances of all
+// accounts and regions are counted together.
+func launches(s *melkor.Snapshot) map[string]int {
+	counts := make(map[string]int)
+	for idx := 0; idx < s.Count(); idx++ {
+		if id, ok := s.Expand(idx)["ImageId"].(string); ok {
+			counts[id]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,133 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestImagesCrawler(client imagesClient, instances melkor.Crawler) *ImagesCrawler {
+	return &ImagesCrawler{
+		config:    &config.Config{AWSRegion: testRegion},
+		clients:   map[melkor.Scope]imagesClient{{Region: testRegion}: client},
+		instances: instances,
+	}
+}
+
+func Test_Images_Resource(t *testing.T) {
+	imc := NewImagesCrawler(&config.Config{}, &InstancesCrawler{})
+
+	assert.Equal(t, "Images", imc.Resource())
+}
+
+func Test_Images_DoCrawl(t *testing.T) {
+	mc := &mock.EC2Client{
+		DescribeImagesFn: func(params *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+			if len(params.Owners) > 0 {
+				return &ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{ImageId: aws.String("ami-own")},
+					{ImageId: aws.String("ami-unused")},
+				}}, nil
+			}
+			return &ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{ImageId: aws.String("ami-shared")},
+				{ImageId: aws.String("ami-own")},
+			}}, nil
+		},
+	}
+	ic := &InstancesCrawler{}
+	commitInstances(ic, time.Now(),
+		&ec2.Instance{InstanceId: aws.String("i-0"), ImageId: aws.String("ami-own")},
+		&ec2.Instance{InstanceId: aws.String("i-1"), ImageId: aws.String("ami-own")},
+		&ec2.Instance{InstanceId: aws.String("i-2"), ImageId: aws.String("ami-shared")},
+		&ec2.Instance{InstanceId: aws.String("i-3")},
+	)
+	imc := newTestImagesCrawler(mc, ic)
+
+	err := imc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Len(t, mc.DescribeImagesInputs, 2)
+	assert.Equal(t, []string{"self"}, aws.StringValueSlice(mc.DescribeImagesInputs[0].Owners))
+	assert.Equal(t, []string{"self"}, aws.StringValueSlice(mc.DescribeImagesInputs[1].ExecutableUsers))
+	assert.Equal(t, []string{"ami-own", "ami-unused", "ami-shared"}, imc.List())
+	assert.Equal(t, float64(2), imc.Get("ami-own")["InstanceCount"])
+	assert.Equal(t, float64(1), imc.Get("ami-shared")["InstanceCount"])
+	assert.Equal(t, float64(0), imc.Get("ami-unused")["InstanceCount"])
+}
+
+func Test_Images_DoCrawl_NoInstances(t *testing.T) {
+	ic := &InstancesCrawler{}
+	commitInstances(ic, time.Now())
+	imc := newTestImagesCrawler(&mock.EC2Client{}, ic)
+
+	err := imc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"ami-0", "ami-1"}, imc.List())
+	assert.Equal(t, float64(0), imc.Get("ami-0")["InstanceCount"])
+}
+
+func Test_Images_DoCrawl_InstancesNotCrawled(t *testing.T) {
+	imc := newTestImagesCrawler(&mock.EC2Client{}, &InstancesCrawler{})
+
+	err := imc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"ami-0", "ami-1"}, imc.List())
+	assert.NotContains(t, imc.Get("ami-0"), "InstanceCount", "the count is unknown, not zero")
+}
+
+func Test_Images_DoCrawl_CountNotRecorded(t *testing.T) {
+	mc := &mock.EC2Client{
+		DescribeImagesFn: func(params *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+			if len(params.Owners) > 0 {
+				return &ec2.DescribeImagesOutput{Images: []*ec2.Image{{ImageId: aws.String("ami-own")}}}, nil
+			}
+			return &ec2.DescribeImagesOutput{}, nil
+		},
+	}
+	ic := &InstancesCrawler{}
+	imc := newTestImagesCrawler(mc, ic)
+	var changesets []melkor.Changeset
+	imc.OnChange(func(cs melkor.Changeset) {
+		changesets = append(changesets, cs)
+	})
+
+	commitInstances(ic, time.Now(), &ec2.Instance{InstanceId: aws.String("i-0"), ImageId: aws.String("ami-own")})
+	assert.Nil(t, imc.DoCrawl())
+	commitInstances(ic, time.Now(),
+		&ec2.Instance{InstanceId: aws.String("i-0"), ImageId: aws.String("ami-own")},
+		&ec2.Instance{InstanceId: aws.String("i-1"), ImageId: aws.String("ami-own")},
+	)
+	assert.Nil(t, imc.DoCrawl())
+
+	assert.Equal(t, float64(2), imc.Get("ami-own")["InstanceCount"])
+	assert.Len(t, changesets, 2)
+	assert.Empty(t, changesets[1].Changes, "launching an instance does not modify its image")
+	records := imc.History().Get("ami-own")
+	if assert.Len(t, records, 1) && assert.Len(t, records[0].Versions, 1) {
+		assert.NotContains(t, records[0].Versions[0].Data, "InstanceCount")
+	}
+}
+
+func Test_Images_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.EC2Client{
+		DescribeImagesFn: func(*ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	imc := newTestImagesCrawler(mc, &InstancesCrawler{})
+
+	err := imc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, imc.Count())
+}
@@ -1,15 +1,13 @@
 package crawlers
 
 import (
-	"time"
+	"fmt"
 
 	"github.com/alde/melkor"
 	"github.com/alde/melkor/config"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/fatih/structs"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,21 +17,22 @@ type ec2Client interface {
 
 // The InstancesCrawler struct holds the implementation for the interface
 type InstancesCrawler struct {
-	instances   []*ec2.Instance
-	lastCrawled time.Time
-	count       int
-	config      *config.Config
-	client      ec2Client
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]ec2Client
 }
 
 // NewInstancesCrawler is the constructor of this crawler
 func NewInstancesCrawler(c *config.Config) *InstancesCrawler {
-	sess := session.Must(session.NewSession())
-
-	client := ec2.New(sess, &aws.Config{Region: aws.String(c.AWSRegion)})
+	clients := make(map[melkor.Scope]ec2Client)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
 	return &InstancesCrawler{
-		config: c,
-		client: client,
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
 	}
 }
 
@@ -42,70 +41,51 @@ func (i *InstancesCrawler) Resource() string {
 	return "Instances"
 }
 
-// LastCrawled is the timestamp of the most recent crawl
-func (i *InstancesCrawler) LastCrawled() time.Time {
-	return i.lastCrawled
-}
-
-// DoCrawl handles the crawling of AWS
+// DoCrawl handles the crawling of AWS, once per account and region
 func (i *InstancesCrawler) DoCrawl() error {
 	logrus.WithField("resource", i.Resource()).Info("Crawling")
 
-	params := &ec2.DescribeInstancesInput{}
-	resp, err := i.client.DescribeInstances(params)
-
-	if err != nil {
-		return err
-	}
-
-	for _, r := range resp.Reservations {
-		for _, ins := range r.Instances {
-			i.instances = append(i.instances, ins)
-		}
-	}
-	i.count = len(i.instances)
-	i.lastCrawled = time.Now()
+	err := i.crawl(scopes(i.config), expandInstance, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return i.describeInstances(i.clients[scope])
+	})
 
 	logrus.WithFields(logrus.Fields{
 		"resource": i.Resource(),
 		"count":    i.Count(),
 	}).Info("Done crawling")
 
-	return nil
+	return err
 }
 
-// List instances
-func (i *InstancesCrawler) List() []string {
-	var data []string
-	for _, ins := range i.instances {
-		data = append(data, aws.StringValue(ins.InstanceId))
+// describeInstances walks all pages of instances. A failing page fails the
+// whole crawl, leaving the previous snapshot in place.
+func (i *InstancesCrawler) describeInstances(client ec2Client) ([]melkor.Item, error) {
+	params := &ec2.DescribeInstancesInput{
+		MaxResults: pageSize(i.config),
 	}
-	return data
-}
-
-// ListExpanded expands the result
-func (i *InstancesCrawler) ListExpanded() []map[string]interface{} {
-	var data []map[string]interface{}
-	for _, ins := range i.instances {
-		iStr := structs.Map(ins)
-		melkor.ModifyTags(iStr["Tags"])
+	var items []melkor.Item
+	for page := 1; ; page++ {
+		resp, err := client.DescribeInstances(params)
+		if err != nil {
+			return nil, fmt.Errorf("describing instances, page %d: %s", page, err)
+		}
 
-		data = append(data, iStr)
-	}
-	return data
-}
+		for _, r := range resp.Reservations {
+			for _, ins := range r.Instances {
+				items = append(items, melkor.Item{
+					ID:    aws.StringValue(ins.InstanceId),
+					Value: ins,
+				})
+			}
+		}
 
-// Get returns a single instance by id
-func (i *InstancesCrawler) Get(id string) map[string]interface{} {
-	for _, ins := range i.instances {
-		if *ins.InstanceId == id {
-			return structs.Map(ins)
+		if aws.StringValue(resp.NextToken) == "" {
+			return items, nil
 		}
+		params.NextToken = resp.NextToken
 	}
-	return nil
 }
 
-// Count the number of instances crawled
-func (i *InstancesCrawler) Count() int {
-	return i.count
+func expandInstance(item interface{}) map[string]interface{} {
+	return expandTagged(item)
 }
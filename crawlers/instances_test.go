@@ -1,13 +1,20 @@
 package crawlers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/alde/melkor"
 	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/fixtures"
 	"github.com/alde/melkor/mock"
+	"github.com/alde/melkor/server"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
@@ -21,58 +28,81 @@ func Test_Resource(t *testing.T) {
 	assert.Equal(t, ic.Resource(), "Instances")
 }
 
+func newTestCrawler(client ec2Client) *InstancesCrawler {
+	return &InstancesCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]ec2Client{{Region: testRegion}: client},
+	}
+}
+
+// commitInstances swaps in a snapshot of the instances, as if crawled
+func commitInstances(ic *InstancesCrawler, crawled time.Time, instances ...*ec2.Instance) {
+	var items []melkor.Item
+	for _, ins := range instances {
+		items = append(items, melkor.Item{
+			ID:     aws.StringValue(ins.InstanceId),
+			Region: testRegion,
+			Value:  ins,
+		})
+	}
+	ic.commit(crawled, map[melkor.Scope]*melkor.Snapshot{
+		{Region: testRegion}: melkor.NewSnapshot(crawled, items, expandInstance),
+	})
+}
+
 func Test_Count(t *testing.T) {
-	ic := &InstancesCrawler{
-		count: 5,
+	ic := &InstancesCrawler{}
+	var instances []*ec2.Instance
+	for n := 0; n < 5; n++ {
+		instances = append(instances, &ec2.Instance{InstanceId: aws.String(fmt.Sprintf("i-%d", n))})
 	}
+	commitInstances(ic, time.Now(), instances...)
 
 	assert.Equal(t, ic.Count(), 5)
 }
 
 func Test_LastCrawled(t *testing.T) {
-	ic := &InstancesCrawler{
-		lastCrawled: time.Now(),
-	}
+	ic := &InstancesCrawler{}
+	commitInstances(ic, time.Now())
 
 	assert.False(t, ic.LastCrawled().IsZero())
 }
 
 func setupCrawler() *InstancesCrawler {
-	return &InstancesCrawler{
-		count: 3,
-		instances: []*ec2.Instance{
-			{
-				InstanceId:       aws.String("i-0"),
-				PrivateIpAddress: aws.String("10.0.0.1"),
-				Tags: []*ec2.Tag{
-					&ec2.Tag{
-						Key:   aws.String("Team"),
-						Value: aws.String("Test1"),
-					},
+	ic := &InstancesCrawler{}
+	commitInstances(ic, time.Time{},
+		&ec2.Instance{
+			InstanceId:       aws.String("i-0"),
+			PrivateIpAddress: aws.String("10.0.0.1"),
+			Tags: []*ec2.Tag{
+				&ec2.Tag{
+					Key:   aws.String("Team"),
+					Value: aws.String("Test1"),
 				},
 			},
-			{
-				InstanceId:       aws.String("i-1"),
-				PrivateIpAddress: aws.String("10.0.0.2"),
-				Tags: []*ec2.Tag{
-					&ec2.Tag{
-						Key:   aws.String("Team"),
-						Value: aws.String("Test2"),
-					},
+		},
+		&ec2.Instance{
+			InstanceId:       aws.String("i-1"),
+			PrivateIpAddress: aws.String("10.0.0.2"),
+			Tags: []*ec2.Tag{
+				&ec2.Tag{
+					Key:   aws.String("Team"),
+					Value: aws.String("Test2"),
 				},
 			},
-			{
-				InstanceId:       aws.String("i-2"),
-				PrivateIpAddress: aws.String("10.0.0.3"),
-				Tags: []*ec2.Tag{
-					&ec2.Tag{
-						Key:   aws.String("Team"),
-						Value: aws.String("Test2"),
-					},
+		},
+		&ec2.Instance{
+			InstanceId:       aws.String("i-2"),
+			PrivateIpAddress: aws.String("10.0.0.3"),
+			Tags: []*ec2.Tag{
+				&ec2.Tag{
+					Key:   aws.String("Team"),
+					Value: aws.String("Test2"),
 				},
 			},
 		},
-	}
+	)
+	return ic
 }
 
 func Test_List_Expanded(t *testing.T) {
@@ -82,10 +112,7 @@ func Test_List_Expanded(t *testing.T) {
 	aLen := len(actual)
 	assert.Equal(t, aLen, 3)
 	for i := 0; i < aLen; i++ {
-		assert.Equal(
-			t,
-			aws.StringValue(actual[i]["PrivateIpAddress"].(*string)),
-			fmt.Sprintf("10.0.0.%d", i+1))
+		assert.Equal(t, actual[i]["PrivateIpAddress"], fmt.Sprintf("10.0.0.%d", i+1))
 	}
 }
 
@@ -101,7 +128,7 @@ func Test_List_Unexpanded(t *testing.T) {
 }
 
 func Test_List_Empty(t *testing.T) {
-	ic := &InstancesCrawler{count: 0}
+	ic := &InstancesCrawler{}
 
 	actual := ic.List()
 	assert.Empty(t, actual, "Should be empty")
@@ -112,10 +139,7 @@ func Test_Get(t *testing.T) {
 
 	actual := ic.Get("i-0")
 
-	assert.Equal(
-		t,
-		aws.StringValue(actual["PrivateIpAddress"].(*string)),
-		"10.0.0.1")
+	assert.Equal(t, actual["PrivateIpAddress"], "10.0.0.1")
 }
 
 func Test_Get_NotFound(t *testing.T) {
@@ -126,12 +150,8 @@ func Test_Get_NotFound(t *testing.T) {
 }
 
 func Test_DoCrawl(t *testing.T) {
-	c := &config.Config{}
 	mc := &mock.EC2Client{}
-	ic := &InstancesCrawler{
-		config: c,
-		client: mc,
-	}
+	ic := newTestCrawler(mc)
 
 	err := ic.DoCrawl()
 	assert.Nil(t, err)
@@ -141,17 +161,487 @@ func Test_DoCrawl(t *testing.T) {
 }
 
 func Test_DoCrawl_Fail(t *testing.T) {
-	c := &config.Config{}
 	mc := &mock.EC2Client{
 		DescribeInstancesFn: func(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
 			return nil, errors.New("something went terribly wrong")
 		},
 	}
+	ic := newTestCrawler(mc)
+
+	err := ic.DoCrawl()
+	assert.NotNil(t, err)
+}
+
+func Test_DoCrawl_Replaces(t *testing.T) {
+	mc := &mock.EC2Client{}
+	ic := newTestCrawler(mc)
+
+	for n := 0; n < 3; n++ {
+		err := ic.DoCrawl()
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, 2, ic.Count(), "crawls must not accumulate")
+	assert.Len(t, ic.List(), 2)
+	assert.Len(t, ic.ListExpanded(), 2)
+	assert.Equal(t, uint64(3), ic.Snapshot().Generation())
+}
+
+func Test_DoCrawl_Fail_KeepsSnapshot(t *testing.T) {
+	ic := setupCrawler()
+	ic.config = &config.Config{AWSRegion: testRegion}
+	ic.clients = map[melkor.Scope]ec2Client{{Region: testRegion}: &mock.EC2Client{
+		DescribeInstancesFn: func(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}}
+	before := ic.LastCrawled()
+	generation := ic.Snapshot().Generation()
+
+	err := ic.DoCrawl()
+	assert.NotNil(t, err)
+
+	assert.Equal(t, 3, ic.Count())
+	assert.Equal(t, before, ic.LastCrawled())
+	assert.Equal(t, generation, ic.Snapshot().Generation())
+	assert.NotNil(t, ic.Get("i-0"))
+}
+
+// Test_Concurrent_CrawlAndServe hammers the HTTP handlers while crawls are
+// running. Run with -race to detect unsynchronized access.
+func Test_Concurrent_CrawlAndServe(t *testing.T) {
+	var crawl int
+	mc := &mock.EC2Client{
+		DescribeInstancesFn: func(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+			crawl++
+			var instances []*ec2.Instance
+			for n := 0; n < crawl%5+1; n++ {
+				instances = append(instances, &ec2.Instance{
+					InstanceId: aws.String(fmt.Sprintf("i-%d", n)),
+					KeyName:    aws.String(fmt.Sprintf("crawl-%d", crawl)),
+				})
+			}
+			return &ec2.DescribeInstancesOutput{
+				Reservations: []*ec2.Reservation{{Instances: instances}},
+			}, nil
+		},
+	}
+	ic := newTestCrawler(mc)
+	router := server.NewRouter(&config.Config{}, melkor.Crawlers{ic.Resource(): ic})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 200; n++ {
+			assert.Nil(t, ic.DoCrawl())
+		}
+		close(done)
+	}()
+
+	urls := []string{
+		"/api/v1/aws/instances",
+		"/api/v1/aws/instances?_expand=true",
+		"/api/v1/aws/instances/i-0",
+		"/service-metadata",
+	}
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				wr := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", u, nil)
+				router.ServeHTTP(wr, r)
+				if u != "/api/v1/aws/instances?_expand=true" || wr.Code != http.StatusOK {
+					continue
+				}
+
+				// Every item must stem from the same crawl
+				var actual []map[string]interface{}
+				assert.Nil(t, json.Unmarshal(wr.Body.Bytes(), &actual))
+				for _, a := range actual {
+					assert.Equal(t, actual[0]["KeyName"], a["KeyName"])
+				}
+			}
+		}(u)
+	}
+	wg.Wait()
+}
+
+func reservationPage(ids ...string) *ec2.DescribeInstancesOutput {
+	var instances []*ec2.Instance
+	for _, id := range ids {
+		instances = append(instances, &ec2.Instance{InstanceId: aws.String(id)})
+	}
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{Instances: instances}},
+	}
+}
+
+func Test_DoCrawl_Pages(t *testing.T) {
+	mc := &mock.EC2Client{
+		DescribeInstancesPages: []*ec2.DescribeInstancesOutput{
+			reservationPage("i-0", "i-1"),
+			reservationPage("i-2", "i-3"),
+			reservationPage("i-4"),
+		},
+	}
+	ic := newTestCrawler(mc)
+	ic.config.PageSize = 2
+
+	err := ic.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"i-0", "i-1", "i-2", "i-3", "i-4"}, ic.List())
+	assert.Len(t, mc.DescribeInstancesInputs, 3)
+	for _, in := range mc.DescribeInstancesInputs {
+		assert.Equal(t, int64(2), aws.Int64Value(in.MaxResults))
+	}
+}
+
+func Test_DoCrawl_Pages_Fail(t *testing.T) {
+	ic := setupCrawler()
+	ic.config = &config.Config{AWSRegion: testRegion}
+	ic.clients = map[melkor.Scope]ec2Client{{Region: testRegion}: &mock.EC2Client{
+		DescribeInstancesFn: func(in *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+			if in.NextToken == nil {
+				out := reservationPage("i-7", "i-8")
+				out.NextToken = aws.String("next")
+				return out, nil
+			}
+			return nil, errors.New("throttled")
+		},
+	}}
+
+	err := ic.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "page 2")
+
+	assert.Equal(t, []string{"i-0", "i-1", "i-2"}, ic.List())
+}
+
+func Test_DoCrawl_Regions(t *testing.T) {
+	ic := &InstancesCrawler{
+		config: &config.Config{AWSRegions: []string{"us-east-1", "eu-west-1"}},
+		clients: map[melkor.Scope]ec2Client{
+			{Region: "eu-west-1"}: &mock.EC2Client{
+				DescribeInstancesPages: []*ec2.DescribeInstancesOutput{reservationPage("i-0", "i-1")},
+			},
+			{Region: "us-east-1"}: &mock.EC2Client{
+				DescribeInstancesPages: []*ec2.DescribeInstancesOutput{reservationPage("i-2")},
+			},
+		},
+	}
+
+	err := ic.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"i-0", "i-1", "i-2"}, ic.List())
+	assert.Equal(t, "us-east-1", ic.Get("i-2")["Region"])
+	assert.Equal(t, []string{"i-2"}, ic.Snapshot().Region("us-east-1").List())
+
+	status := ic.Status()
+	assert.Len(t, status, 2)
+	assert.Equal(t, "eu-west-1", status[0].Region)
+	assert.Equal(t, 2, status[0].Count)
+	assert.Equal(t, "us-east-1", status[1].Region)
+	assert.Equal(t, 1, status[1].Count)
+}
+
+func Test_DoCrawl_Regions_Fail(t *testing.T) {
+	ic := &InstancesCrawler{
+		config: &config.Config{AWSRegions: []string{"eu-west-1", "us-east-1"}},
+		clients: map[melkor.Scope]ec2Client{
+			{Region: "eu-west-1"}: &mock.EC2Client{
+				DescribeInstancesFn: func(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+					return nil, errors.New("throttled")
+				},
+			},
+			{Region: "us-east-1"}: &mock.EC2Client{
+				DescribeInstancesPages: []*ec2.DescribeInstancesOutput{reservationPage("i-2")},
+			},
+		},
+	}
+
+	err := ic.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "eu-west-1")
+
+	assert.Equal(t, []string{"i-2"}, ic.List(), "the healthy region is still crawled")
+
+	status := ic.Status()
+	assert.Len(t, status, 2)
+	assert.Contains(t, status[0].Error, "throttled")
+	assert.True(t, status[0].LastCrawled.IsZero())
+	assert.Empty(t, status[1].Error)
+}
+
+func Test_DoCrawl_Regions_SwapsOnce(t *testing.T) {
+	var ic *InstancesCrawler
+	var seen [][]string
+	describe := func(ids ...string) func(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+		return func(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+			seen = append(seen, ic.List())
+			return reservationPage(ids...), nil
+		}
+	}
+	ic = &InstancesCrawler{
+		config: &config.Config{AWSRegions: []string{"eu-west-1", "us-east-1"}},
+		clients: map[melkor.Scope]ec2Client{
+			{Region: "eu-west-1"}: &mock.EC2Client{DescribeInstancesFn: describe("i-0")},
+			{Region: "us-east-1"}: &mock.EC2Client{DescribeInstancesFn: describe("i-1")},
+		},
+	}
+
+	assert.Nil(t, ic.DoCrawl())
+
+	assert.Equal(t, [][]string{{}, {}}, seen, "nothing is swapped in until every scope is crawled")
+	assert.Equal(t, []string{"i-0", "i-1"}, ic.List())
+	assert.Equal(t, uint64(1), ic.Snapshot().Generation())
+	assert.Equal(t, ic.LastCrawled(), ic.Snapshot().Region("eu-west-1").CrawledAt())
+	assert.Equal(t, ic.LastCrawled(), ic.Snapshot().Region("us-east-1").CrawledAt())
+}
+
+func Test_DoCrawl_Accounts(t *testing.T) {
 	ic := &InstancesCrawler{
-		config: c,
-		client: mc,
+		config: &config.Config{
+			AWSRegion: testRegion,
+			Accounts: []config.Account{
+				{RoleARN: "arn:aws:iam::111111111111:role/melkor"},
+				{RoleARN: "arn:aws:iam::222222222222:role/melkor"},
+			},
+		},
+		clients: map[melkor.Scope]ec2Client{
+			{Account: "111111111111", Region: testRegion}: &mock.EC2Client{
+				DescribeInstancesPages: []*ec2.DescribeInstancesOutput{reservationPage("i-0")},
+			},
+			{Account: "222222222222", Region: testRegion}: &mock.EC2Client{
+				DescribeInstancesPages: []*ec2.DescribeInstancesOutput{reservationPage("i-1", "i-2")},
+			},
+		},
 	}
 
 	err := ic.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"i-0", "i-1", "i-2"}, ic.List())
+	assert.Equal(t, "111111111111", ic.Get("i-0")["AccountId"])
+	assert.Equal(t, "222222222222", ic.Get("i-2")["AccountId"])
+	assert.Equal(t, []string{"i-1", "i-2"}, ic.Snapshot().Account("222222222222").List())
+
+	status := ic.Status()
+	assert.Len(t, status, 2)
+	assert.Equal(t, "111111111111", status[0].Account)
+	assert.Equal(t, 2, status[1].Count)
+}
+
+func Test_History(t *testing.T) {
+	ic := &InstancesCrawler{}
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(10 * time.Minute)
+
+	commitInstances(ic, t0, &ec2.Instance{InstanceId: aws.String("i-0"), KeyName: aws.String("old")})
+	commitInstances(ic, t1, &ec2.Instance{InstanceId: aws.String("i-0"), KeyName: aws.String("new")})
+
+	records := ic.History().Get("i-0")
+	assert.Len(t, records, 1)
+	assert.Equal(t, t0, records[0].FirstSeen)
+	assert.Equal(t, t1, records[0].LastSeen)
+	assert.Len(t, records[0].Versions, 2)
+
+	assert.Equal(t, "old", ic.History().At(t0.Add(time.Minute)).Get("i-0")["KeyName"])
+	assert.Equal(t, "new", ic.History().At(t1).Get("i-0")["KeyName"])
+	assert.Equal(t, testRegion, ic.History().At(t1).Get("i-0")["Region"])
+}
+
+func Test_History_Unchanged(t *testing.T) {
+	ic := newTestCrawler(&mock.EC2Client{})
+
+	for n := 0; n < 3; n++ {
+		err := ic.DoCrawl()
+		assert.Nil(t, err)
+	}
+
+	for _, id := range ic.List() {
+		records := ic.History().Get(id)
+		assert.Len(t, records, 1)
+		assert.Len(t, records[0].Versions, 1, "unchanged items must not get new versions")
+		assert.Nil(t, records[0].Versions[0].To)
+	}
+}
+
+func Test_OnChange(t *testing.T) {
+	ic := newTestCrawler(&mock.EC2Client{
+		DescribeInstancesPages: []*ec2.DescribeInstancesOutput{reservationPage("i-0", "i-1")},
+	})
+	var changesets []melkor.Changeset
+	ic.OnChange(func(cs melkor.Changeset) {
+		changesets = append(changesets, cs)
+	})
+
+	for n := 0; n < 2; n++ {
+		err := ic.DoCrawl()
+		assert.Nil(t, err)
+	}
+
+	assert.Len(t, changesets, 2)
+	assert.Equal(t, melkor.Scope{Region: testRegion}, changesets[0].Scope)
+	assert.Len(t, changesets[0].Changes, 2)
+	for _, c := range changesets[0].Changes {
+		assert.Equal(t, melkor.Added, c.Type)
+		assert.Equal(t, testRegion, c.Data["Region"])
+	}
+	assert.Empty(t, changesets[1].Changes, "nothing changed")
+}
+
+func Test_Restore(t *testing.T) {
+	saved := newTestCrawler(&mock.EC2Client{
+		DescribeInstancesPages: []*ec2.DescribeInstancesOutput{reservationPage("i-0", "i-1")},
+	})
+	err := saved.DoCrawl()
+	assert.Nil(t, err)
+	data, err := json.Marshal(saved.History())
+	assert.Nil(t, err)
+
+	mc := &mock.EC2Client{
+		DescribeInstancesPages: []*ec2.DescribeInstancesOutput{reservationPage("i-1", "i-2")},
+	}
+	ic := newTestCrawler(mc)
+	err = ic.Restore(data)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"i-0", "i-1"}, ic.List())
+	assert.Equal(t, testRegion, ic.Get("i-0")["Region"])
+	assert.True(t, saved.LastCrawled().Equal(ic.LastCrawled()))
+	status := ic.Status()
+	assert.Len(t, status, 1)
+	assert.True(t, status[0].Restored)
+	assert.Equal(t, 2, status[0].Count)
+
+	var changesets []melkor.Changeset
+	ic.OnChange(func(cs melkor.Changeset) {
+		changesets = append(changesets, cs)
+	})
+	err = ic.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"i-1", "i-2"}, ic.List())
+	assert.False(t, ic.Status()[0].Restored)
+	assert.False(t, changesets[0].Initial)
+	var changes []string
+	for _, c := range changesets[0].Changes {
+		changes = append(changes, c.Type+" "+c.ID)
+	}
+	assert.Equal(t, []string{"added i-2", "removed i-0"}, changes)
+}
+
+func Test_Restore_DropsUnconfiguredScopes(t *testing.T) {
+	saved := &InstancesCrawler{
+		config: &config.Config{AWSRegions: []string{"eu-west-1", "us-east-1"}},
+		clients: map[melkor.Scope]ec2Client{
+			{Region: "eu-west-1"}: &mock.EC2Client{DescribeInstancesPages: []*ec2.DescribeInstancesOutput{reservationPage("i-0")}},
+			{Region: "us-east-1"}: &mock.EC2Client{DescribeInstancesPages: []*ec2.DescribeInstancesOutput{reservationPage("i-1")}},
+		},
+	}
+	assert.Nil(t, saved.DoCrawl())
+	data, err := json.Marshal(saved.History())
+	assert.Nil(t, err)
+
+	cfg := &config.Config{AWSRegion: "eu-west-1"}
+	ic := &InstancesCrawler{
+		base:    newBase(cfg),
+		config:  cfg,
+		clients: map[melkor.Scope]ec2Client{{Region: "eu-west-1"}: &mock.EC2Client{}},
+	}
+	assert.Nil(t, ic.Restore(data))
+
+	assert.Equal(t, []string{"i-0"}, ic.List())
+	status := ic.Status()
+	if assert.Len(t, status, 1) {
+		assert.Equal(t, melkor.Scope{Region: "eu-west-1"}, status[0].Scope)
+	}
+	assert.Len(t, ic.History().Get("i-1"), 1, "the history of dropped scopes is kept")
+}
+
+func Test_Restore_Invalid(t *testing.T) {
+	ic := newTestCrawler(&mock.EC2Client{})
+
+	err := ic.Restore([]byte("{"))
 	assert.NotNil(t, err)
+	assert.Equal(t, 0, ic.Count())
+}
+
+const benchInstances = 50000
+
+var (
+	benchOnce    sync.Once
+	benchCrawler *InstancesCrawler
+)
+
+// benchmarkCrawler returns a crawler holding a crawl of benchInstances
+// instances, built from the fixtures once for all benchmarks
+func benchmarkCrawler(b *testing.B) *InstancesCrawler {
+	benchOnce.Do(func() {
+		data, err := json.Marshal(fixtures.FullCrawlerData(benchInstances))
+		if err != nil {
+			b.Fatal(err)
+		}
+		var instances []*ec2.Instance
+		if err := json.Unmarshal(data, &instances); err != nil {
+			b.Fatal(err)
+		}
+		benchCrawler = newTestCrawler(&mock.EC2Client{})
+		commitInstances(benchCrawler, time.Now(), instances...)
+	})
+	b.ResetTimer()
+	return benchCrawler
+}
+
+// Benchmark_ExpandInstances expands every instance, as listing them did
+// before the expanded instances were kept with the snapshot
+func Benchmark_ExpandInstances(b *testing.B) {
+	ic := benchmarkCrawler(b)
+	s := ic.Snapshot()
+	for n := 0; n < b.N; n++ {
+		for idx := 0; idx < s.Count(); idx++ {
+			expandInstance(s.Item(idx).Value)
+		}
+	}
+}
+
+func Benchmark_ListExpanded(b *testing.B) {
+	ic := benchmarkCrawler(b)
+	for n := 0; n < b.N; n++ {
+		ic.ListExpanded()
+	}
+}
+
+// Benchmark_Get_Scan looks up an instance by going through all of them, as
+// Get did before the snapshot was indexed
+func Benchmark_Get_Scan(b *testing.B) {
+	ic := benchmarkCrawler(b)
+	s := ic.Snapshot()
+	for n := 0; n < b.N; n++ {
+		id := fmt.Sprintf("i-%d", n*7919%benchInstances)
+		for idx := 0; idx < s.Count(); idx++ {
+			if item := s.Item(idx); item.ID == id {
+				expandInstance(item.Value)
+				break
+			}
+		}
+	}
+}
+
+func Benchmark_Get(b *testing.B) {
+	ic := benchmarkCrawler(b)
+	for n := 0; n < b.N; n++ {
+		ic.Get(fmt.Sprintf("i-%d", n*7919%benchInstances))
+	}
 }
@@ -0,0 +1,76 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/sirupsen/logrus"
+)
+
+type internetGatewaysClient interface {
+	DescribeInternetGateways(*ec2.DescribeInternetGatewaysInput) (*ec2.DescribeInternetGatewaysOutput, error)
+}
+
+// The InternetGatewaysCrawler crawls the internet gateways of every account and
+// region
+type InternetGatewaysCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]internetGatewaysClient
+}
+
+// NewInternetGatewaysCrawler is the constructor of this crawler
+func NewInternetGatewaysCrawler(c *config.Config) *InternetGatewaysCrawler {
+	clients := make(map[melkor.Scope]internetGatewaysClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
+	return &InternetGatewaysCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (g *InternetGatewaysCrawler) Resource() string {
+	return "InternetGateways"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (g *InternetGatewaysCrawler) DoCrawl() error {
+	logrus.WithField("resource", g.Resource()).Info("Crawling")
+
+	err := g.crawl(scopes(g.config), expandTagged, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return g.describeInternetGateways(g.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": g.Resource(),
+		"count":    g.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeInternetGateways fetches all internet gateways, which AWS hands
+// out in a single response
+func (g *InternetGatewaysCrawler) describeInternetGateways(client internetGatewaysClient) ([]melkor.Item, error) {
+	resp, err := client.DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{})
+	if err != nil {
+		return nil, fmt.Errorf("describing internet gateways: %s", err)
+	}
+	var items []melkor.Item
+	for _, igw := range resp.InternetGateways {
+		items = append(items, melkor.Item{
+			ID:    aws.StringValue(igw.InternetGatewayId),
+			Value: igw,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,57 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestInternetGatewaysCrawler(client internetGatewaysClient) *InternetGatewaysCrawler {
+	return &InternetGatewaysCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]internetGatewaysClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_InternetGateways_Resource(t *testing.T) {
+	gc := NewInternetGatewaysCrawler(&config.Config{})
+
+	assert.Equal(t, "InternetGateways", gc.Resource())
+}
+
+func Test_InternetGateways_DoCrawl(t *testing.T) {
+	mc := &mock.NetworkClient{}
+	gc := newTestInternetGatewaysCrawler(mc)
+
+	err := gc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeInternetGatewaysFnInvoked)
+	assert.Equal(t, []string{"igw-0", "igw-1"}, gc.List())
+}
+
+func Test_InternetGateways_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.NetworkClient{
+		DescribeInternetGatewaysFn: func(*ec2.DescribeInternetGatewaysInput) (*ec2.DescribeInternetGatewaysOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	gc := newTestInternetGatewaysCrawler(mc)
+
+	err := gc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, gc.Count())
+}
+
+func Test_InternetGateways_Expand(t *testing.T) {
+	gc := newTestInternetGatewaysCrawler(&mock.NetworkClient{})
+	assert.Nil(t, gc.DoCrawl())
+
+	assert.Equal(t, []string{"igw-1"}, matching(t, gc, "Attachments.VpcId:vpc-1"))
+}
@@ -0,0 +1,146 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/fatih/structs"
+	"github.com/sirupsen/logrus"
+)
+
+type loadBalancersClient interface {
+	DescribeLoadBalancers(*elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error)
+	DescribeListeners(*elbv2.DescribeListenersInput) (*elbv2.DescribeListenersOutput, error)
+}
+
+// A loadBalancer is an application or network load balancer along with its
+// listeners
+type loadBalancer struct {
+	*elbv2.LoadBalancer
+	listeners []*elbv2.Listener
+}
+
+// The LoadBalancersCrawler crawls the application and network load balancers
+// of every account and region, along with their listeners. Classic load
+// balancers are crawled by the ClassicLoadBalancersCrawler.
+type LoadBalancersCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]loadBalancersClient
+}
+
+// NewLoadBalancersCrawler is the constructor of this crawler
+func NewLoadBalancersCrawler(c *config.Config) *LoadBalancersCrawler {
+	clients := make(map[melkor.Scope]loadBalancersClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = elbv2.New(sess)
+	}
+	return &LoadBalancersCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (l *LoadBalancersCrawler) Resource() string {
+	return "LoadBalancers"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (l *LoadBalancersCrawler) DoCrawl() error {
+	logrus.WithField("resource", l.Resource()).Info("Crawling")
+
+	err := l.crawl(scopes(l.config), expandLoadBalancer, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return l.describeLoadBalancers(l.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": l.Resource(),
+		"count":    l.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeLoadBalancers walks all pages of load balancers, then all pages of
+// listeners of each. A failing call fails the whole crawl, leaving the
+// previous snapshot in place.
+func (l *LoadBalancersCrawler) describeLoadBalancers(client loadBalancersClient) ([]melkor.Item, error) {
+	params := &elbv2.DescribeLoadBalancersInput{
+		PageSize: elbPageSize(l.config),
+	}
+	var items []melkor.Item
+	for page := 1; ; page++ {
+		resp, err := client.DescribeLoadBalancers(params)
+		if err != nil {
+			return nil, fmt.Errorf("describing load balancers, page %d: %s", page, err)
+		}
+
+		for _, lb := range resp.LoadBalancers {
+			listeners, err := l.describeListeners(client, lb.LoadBalancerArn)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, melkor.Item{
+				ID:    aws.StringValue(lb.LoadBalancerArn),
+				Value: &loadBalancer{lb, listeners},
+			})
+		}
+
+		if aws.StringValue(resp.NextMarker) == "" {
+			return items, nil
+		}
+		params.Marker = resp.NextMarker
+	}
+}
+
+// describeListeners walks all pages of listeners of a load balancer
+func (l *LoadBalancersCrawler) describeListeners(client loadBalancersClient, arn *string) ([]*elbv2.Listener, error) {
+	params := &elbv2.DescribeListenersInput{
+		LoadBalancerArn: arn,
+		PageSize:        elbPageSize(l.config),
+	}
+	var listeners []*elbv2.Listener
+	for page := 1; ; page++ {
+		resp, err := client.DescribeListeners(params)
+		if err != nil {
+			return nil, fmt.Errorf("describing listeners of %s, page %d: %s", aws.StringValue(arn), page, err)
+		}
+		listeners = append(listeners, resp.Listeners...)
+
+		if aws.StringValue(resp.NextMarker) == "" {
+			return listeners, nil
+		}
+		params.Marker = resp.NextMarker
+	}
+}
+
+// expandLoadBalancer expands a load balancer, adding its Listeners and the
+// TargetGroupArns they forward to by default
+func expandLoadBalancer(item interface{}) map[string]interface{} {
+	lb := item.(*loadBalancer)
+	data := structs.Map(lb.LoadBalancer)
+	listeners := []interface{}{}
+	arns := []string{}
+	seen := make(map[string]bool)
+	for _, ls := range lb.listeners {
+		listeners = append(listeners, structs.Map(ls))
+		for _, action := range ls.DefaultActions {
+			arn := aws.StringValue(action.TargetGroupArn)
+			if arn == "" || seen[arn] {
+				continue
+			}
+			seen[arn] = true
+			arns = append(arns, arn)
+		}
+	}
+	data["Listeners"] = listeners
+	data["TargetGroupArns"] = arns
+	return data
+}
@@ -0,0 +1,82 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/fixtures"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLoadBalancersCrawler(client loadBalancersClient) *LoadBalancersCrawler {
+	return &LoadBalancersCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]loadBalancersClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_LoadBalancers_Resource(t *testing.T) {
+	lc := NewLoadBalancersCrawler(&config.Config{})
+
+	assert.Equal(t, "LoadBalancers", lc.Resource())
+}
+
+func Test_LoadBalancers_DoCrawl(t *testing.T) {
+	mc := &mock.ELBv2Client{}
+	lc := newTestLoadBalancersCrawler(mc)
+
+	err := lc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeLoadBalancersFnInvoked)
+	assert.True(t, mc.DescribeListenersFnInvoked)
+	assert.Equal(t, []string{fixtures.LoadBalancerArn(0), fixtures.LoadBalancerArn(1)}, lc.List())
+}
+
+func Test_LoadBalancers_Expand(t *testing.T) {
+	lc := newTestLoadBalancersCrawler(&mock.ELBv2Client{})
+	assert.Nil(t, lc.DoCrawl())
+
+	data := lc.Get(fixtures.LoadBalancerArn(1))
+	assert.Equal(t, []interface{}{fixtures.TargetGroupArn(1)}, data["TargetGroupArns"])
+	assert.Len(t, data["Listeners"], 1)
+	assert.Equal(t, []string{fixtures.LoadBalancerArn(0), fixtures.LoadBalancerArn(1)}, matching(t, lc, "Listeners.Port:443"))
+}
+
+func Test_LoadBalancers_DoCrawl_Listeners_Pages(t *testing.T) {
+	mc := &mock.ELBv2Client{
+		DescribeListenersFn: func(params *elbv2.DescribeListenersInput) (*elbv2.DescribeListenersOutput, error) {
+			if params.Marker == nil {
+				listeners := fixtures.Listeners(*params.LoadBalancerArn)
+				return &elbv2.DescribeListenersOutput{Listeners: listeners, NextMarker: params.LoadBalancerArn}, nil
+			}
+			return &elbv2.DescribeListenersOutput{Listeners: fixtures.Listeners(fixtures.LoadBalancerArn(9))}, nil
+		},
+	}
+	lc := newTestLoadBalancersCrawler(mc)
+
+	err := lc.DoCrawl()
+	assert.Nil(t, err)
+
+	data := lc.Get(fixtures.LoadBalancerArn(0))
+	assert.Len(t, data["Listeners"], 2)
+	assert.Equal(t, []interface{}{fixtures.TargetGroupArn(0), fixtures.TargetGroupArn(9)}, data["TargetGroupArns"])
+}
+
+func Test_LoadBalancers_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.ELBv2Client{
+		DescribeListenersFn: func(*elbv2.DescribeListenersInput) (*elbv2.DescribeListenersOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	lc := newTestLoadBalancersCrawler(mc)
+
+	err := lc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, lc.Count())
+}
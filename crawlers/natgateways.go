@@ -0,0 +1,85 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/sirupsen/logrus"
+)
+
+type natGatewaysClient interface {
+	DescribeNatGateways(*ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error)
+}
+
+// The NatGatewaysCrawler crawls the NAT gateways of every account and region
+type NatGatewaysCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]natGatewaysClient
+}
+
+// NewNatGatewaysCrawler is the constructor of this crawler
+func NewNatGatewaysCrawler(c *config.Config) *NatGatewaysCrawler {
+	clients := make(map[melkor.Scope]natGatewaysClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
+	return &NatGatewaysCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (g *NatGatewaysCrawler) Resource() string {
+	return "NatGateways"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (g *NatGatewaysCrawler) DoCrawl() error {
+	logrus.WithField("resource", g.Resource()).Info("Crawling")
+
+	err := g.crawl(scopes(g.config), expandTagged, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return g.describeNatGateways(g.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": g.Resource(),
+		"count":    g.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeNatGateways walks all pages of NAT gateways. A failing page fails
+// the whole crawl, leaving the previous snapshot in place.
+func (g *NatGatewaysCrawler) describeNatGateways(client natGatewaysClient) ([]melkor.Item, error) {
+	params := &ec2.DescribeNatGatewaysInput{
+		MaxResults: pageSize(g.config),
+	}
+	var items []melkor.Item
+	for page := 1; ; page++ {
+		resp, err := client.DescribeNatGateways(params)
+		if err != nil {
+			return nil, fmt.Errorf("describing NAT gateways, page %d: %s", page, err)
+		}
+
+		for _, nat := range resp.NatGateways {
+			items = append(items, melkor.Item{
+				ID:    aws.StringValue(nat.NatGatewayId),
+				Value: nat,
+			})
+		}
+
+		if aws.StringValue(resp.NextToken) == "" {
+			return items, nil
+		}
+		params.NextToken = resp.NextToken
+	}
+}
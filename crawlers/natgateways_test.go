@@ -0,0 +1,69 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/fixtures"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestNatGatewaysCrawler(client natGatewaysClient) *NatGatewaysCrawler {
+	return &NatGatewaysCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]natGatewaysClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_NatGateways_Resource(t *testing.T) {
+	gc := NewNatGatewaysCrawler(&config.Config{})
+
+	assert.Equal(t, "NatGateways", gc.Resource())
+}
+
+func Test_NatGateways_DoCrawl(t *testing.T) {
+	mc := &mock.NetworkClient{}
+	gc := newTestNatGatewaysCrawler(mc)
+
+	err := gc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeNatGatewaysFnInvoked)
+	assert.Equal(t, []string{"nat-0", "nat-1"}, gc.List())
+}
+
+func Test_NatGateways_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.NetworkClient{
+		DescribeNatGatewaysFn: func(*ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	gc := newTestNatGatewaysCrawler(mc)
+
+	err := gc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, gc.Count())
+}
+
+func Test_NatGateways_DoCrawl_Pages(t *testing.T) {
+	nats := fixtures.NatGateways(3)
+	mc := &mock.NetworkClient{
+		DescribeNatGatewaysPages: []*ec2.DescribeNatGatewaysOutput{
+			{NatGateways: nats[:2]},
+			{NatGateways: nats[2:]},
+		},
+	}
+	gc := newTestNatGatewaysCrawler(mc)
+
+	err := gc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"nat-0", "nat-1", "nat-2"}, gc.List())
+	assert.Equal(t, []string{"nat-2"}, matching(t, gc, "NatGatewayAddresses.PublicIp:52.0.0.2"))
+	assert.Equal(t, []string{"nat-1"}, matching(t, gc, "Tags.Name:nat-1"))
+}
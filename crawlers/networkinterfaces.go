@@ -0,0 +1,85 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/fatih/structs"
+	"github.com/sirupsen/logrus"
+)
+
+type networkInterfacesClient interface {
+	DescribeNetworkInterfaces(*ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error)
+}
+
+// The NetworkInterfacesCrawler crawls the network interfaces (ENIs) of every
+// account and region
+type NetworkInterfacesCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]networkInterfacesClient
+}
+
+// NewNetworkInterfacesCrawler is the constructor of this crawler
+func NewNetworkInterfacesCrawler(c *config.Config) *NetworkInterfacesCrawler {
+	clients := make(map[melkor.Scope]networkInterfacesClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
+	return &NetworkInterfacesCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (n *NetworkInterfacesCrawler) Resource() string {
+	return "NetworkInterfaces"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (n *NetworkInterfacesCrawler) DoCrawl() error {
+	logrus.WithField("resource", n.Resource()).Info("Crawling")
+
+	err := n.crawl(scopes(n.config), expandNetworkInterface, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return n.describeNetworkInterfaces(n.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": n.Resource(),
+		"count":    n.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeNetworkInterfaces fetches all network interfaces, which AWS hands
+// out in a single response
+func (n *NetworkInterfacesCrawler) describeNetworkInterfaces(client networkInterfacesClient) ([]melkor.Item, error) {
+	resp, err := client.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("describing network interfaces: %s", err)
+	}
+	var items []melkor.Item
+	for _, eni := range resp.NetworkInterfaces {
+		items = append(items, melkor.Item{
+			ID:    aws.StringValue(eni.NetworkInterfaceId),
+			Value: eni,
+		})
+	}
+	return items, nil
+}
+
+// expandNetworkInterface expands a network interface, the tags of which are
+// its TagSet
+func expandNetworkInterface(item interface{}) map[string]interface{} {
+	data := structs.Map(item)
+	melkor.ModifyTags(data["TagSet"])
+	return data
+}
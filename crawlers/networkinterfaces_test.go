@@ -0,0 +1,58 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestNetworkInterfacesCrawler(client networkInterfacesClient) *NetworkInterfacesCrawler {
+	return &NetworkInterfacesCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]networkInterfacesClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_NetworkInterfaces_Resource(t *testing.T) {
+	nc := NewNetworkInterfacesCrawler(&config.Config{})
+
+	assert.Equal(t, "NetworkInterfaces", nc.Resource())
+}
+
+func Test_NetworkInterfaces_DoCrawl(t *testing.T) {
+	mc := &mock.NetworkClient{}
+	nc := newTestNetworkInterfacesCrawler(mc)
+
+	err := nc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeNetworkInterfacesFnInvoked)
+	assert.Equal(t, []string{"eni-0", "eni-1"}, nc.List())
+}
+
+func Test_NetworkInterfaces_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.NetworkClient{
+		DescribeNetworkInterfacesFn: func(*ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	nc := newTestNetworkInterfacesCrawler(mc)
+
+	err := nc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, nc.Count())
+}
+
+func Test_NetworkInterfaces_Expand(t *testing.T) {
+	nc := newTestNetworkInterfacesCrawler(&mock.NetworkClient{})
+	assert.Nil(t, nc.DoCrawl())
+
+	assert.Equal(t, []string{"eni-1"}, matching(t, nc, "Attachment.InstanceId:i-1"))
+	assert.Equal(t, []string{"eni-0"}, matching(t, nc, "TagSet.Name:eni-0"))
+}
@@ -0,0 +1,75 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/sirupsen/logrus"
+)
+
+type routeTablesClient interface {
+	DescribeRouteTables(*ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error)
+}
+
+// The RouteTablesCrawler crawls the route tables of every account and region
+type RouteTablesCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]routeTablesClient
+}
+
+// NewRouteTablesCrawler is the constructor of this crawler
+func NewRouteTablesCrawler(c *config.Config) *RouteTablesCrawler {
+	clients := make(map[melkor.Scope]routeTablesClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
+	return &RouteTablesCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (r *RouteTablesCrawler) Resource() string {
+	return "RouteTables"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (r *RouteTablesCrawler) DoCrawl() error {
+	logrus.WithField("resource", r.Resource()).Info("Crawling")
+
+	err := r.crawl(scopes(r.config), expandTagged, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return r.describeRouteTables(r.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": r.Resource(),
+		"count":    r.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeRouteTables fetches all route tables, which AWS hands out in a
+// single response
+func (r *RouteTablesCrawler) describeRouteTables(client routeTablesClient) ([]melkor.Item, error) {
+	resp, err := client.DescribeRouteTables(&ec2.DescribeRouteTablesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("describing route tables: %s", err)
+	}
+	var items []melkor.Item
+	for _, rt := range resp.RouteTables {
+		items = append(items, melkor.Item{
+			ID:    aws.StringValue(rt.RouteTableId),
+			Value: rt,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,59 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouteTablesCrawler(client routeTablesClient) *RouteTablesCrawler {
+	return &RouteTablesCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]routeTablesClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_RouteTables_Resource(t *testing.T) {
+	rc := NewRouteTablesCrawler(&config.Config{})
+
+	assert.Equal(t, "RouteTables", rc.Resource())
+}
+
+func Test_RouteTables_DoCrawl(t *testing.T) {
+	mc := &mock.NetworkClient{}
+	rc := newTestRouteTablesCrawler(mc)
+
+	err := rc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeRouteTablesFnInvoked)
+	assert.Equal(t, []string{"rtb-0", "rtb-1"}, rc.List())
+}
+
+func Test_RouteTables_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.NetworkClient{
+		DescribeRouteTablesFn: func(*ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	rc := newTestRouteTablesCrawler(mc)
+
+	err := rc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, rc.Count())
+}
+
+func Test_RouteTables_Expand(t *testing.T) {
+	rc := newTestRouteTablesCrawler(&mock.NetworkClient{})
+	assert.Nil(t, rc.DoCrawl())
+
+	assert.Equal(t, []string{"rtb-1"}, matching(t, rc, "Associations.SubnetId:subnet-1"))
+	assert.Equal(t, []string{"rtb-0", "rtb-1"}, matching(t, rc, "Routes.(DestinationCidrBlock:0.0.0.0/0 AND GatewayId^=igw-)"))
+	assert.Empty(t, matching(t, rc, "Routes.(DestinationCidrBlock:0.0.0.0/0 AND GatewayId:local)"))
+}
@@ -0,0 +1,131 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/sirupsen/logrus"
+)
+
+type securityGroupsClient interface {
+	DescribeSecurityGroups(*ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error)
+}
+
+// The SecurityGroupsCrawler crawls the security groups of every account and
+// region
+type SecurityGroupsCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]securityGroupsClient
+}
+
+// NewSecurityGroupsCrawler is the constructor of this crawler
+func NewSecurityGroupsCrawler(c *config.Config) *SecurityGroupsCrawler {
+	clients := make(map[melkor.Scope]securityGroupsClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
+	return &SecurityGroupsCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (s *SecurityGroupsCrawler) Resource() string {
+	return "SecurityGroups"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (s *SecurityGroupsCrawler) DoCrawl() error {
+	logrus.WithField("resource", s.Resource()).Info("Crawling")
+
+	err := s.crawl(scopes(s.config), expandSecurityGroup, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return s.describeSecurityGroups(s.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": s.Resource(),
+		"count":    s.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeSecurityGroups fetches all security groups, which AWS hands out in
+// a single response
+func (s *SecurityGroupsCrawler) describeSecurityGroups(client securityGroupsClient) ([]melkor.Item, error) {
+	resp, err := client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("describing security groups: %s", err)
+	}
+	var items []melkor.Item
+	for _, sg := range resp.SecurityGroups {
+		items = append(items, melkor.Item{
+			ID:    aws.StringValue(sg.GroupId),
+			Value: sg,
+		})
+	}
+	return items, nil
+}
+
+// expandSecurityGroup expands a security group, adding its ingress and egress
+// rules as flattened by rules
+func expandSecurityGroup(item interface{}) map[string]interface{} {
+	sg := item.(*ec2.SecurityGroup)
+	sStr := expandTagged(sg)
+	sStr["Ingress"] = rules(sg.IpPermissions)
+	sStr["Egress"] = rules(sg.IpPermissionsEgress)
+	return sStr
+}
+
+// rules flattens permissions into one rule per source, each holding the
+// Protocol, the FromPort and ToPort of the range it opens, and either the Cidr
+// (IPv4 or IPv6), the PrefixListId or the GroupId it opens it to. Rules of all
+// protocols, given as -1 by AWS, have the protocol "all" and open every port.
+func rules(perms []*ec2.IpPermission) []interface{} {
+	data := []interface{}{}
+	for _, p := range perms {
+		rule := func(source string, value *string) map[string]interface{} {
+			r := map[string]interface{}{
+				"Protocol": aws.StringValue(p.IpProtocol),
+				"FromPort": aws.Int64Value(p.FromPort),
+				"ToPort":   aws.Int64Value(p.ToPort),
+				source:     aws.StringValue(value),
+			}
+			if r["Protocol"] == "-1" {
+				r["Protocol"] = "all"
+			}
+			if r["Protocol"] == "all" || p.FromPort == nil {
+				r["FromPort"], r["ToPort"] = int64(0), int64(65535)
+			}
+			data = append(data, r)
+			return r
+		}
+		for _, ip := range p.IpRanges {
+			rule("Cidr", ip.CidrIp)
+		}
+		for _, ip := range p.Ipv6Ranges {
+			rule("Cidr", ip.CidrIpv6)
+		}
+		for _, pl := range p.PrefixListIds {
+			rule("PrefixListId", pl.PrefixListId)
+		}
+		for _, pair := range p.UserIdGroupPairs {
+			r := rule("GroupId", pair.GroupId)
+			if pair.GroupName != nil {
+				r["GroupName"] = aws.StringValue(pair.GroupName)
+			}
+			if pair.UserId != nil {
+				r["UserId"] = aws.StringValue(pair.UserId)
+			}
+		}
+	}
+	return data
+}
@@ -0,0 +1,148 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/filter"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSecurityGroupsCrawler(client securityGroupsClient) *SecurityGroupsCrawler {
+	return &SecurityGroupsCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]securityGroupsClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_SecurityGroups_Resource(t *testing.T) {
+	sgc := NewSecurityGroupsCrawler(&config.Config{})
+
+	assert.Equal(t, "SecurityGroups", sgc.Resource())
+}
+
+func Test_SecurityGroups_DoCrawl(t *testing.T) {
+	mc := &mock.EC2Client{}
+	sgc := newTestSecurityGroupsCrawler(mc)
+
+	err := sgc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeSecurityGroupsFnInvoked)
+	assert.Equal(t, []string{"sg-0", "sg-1"}, sgc.List())
+	assert.Equal(t, testRegion, sgc.Get("sg-0")["Region"])
+}
+
+func Test_SecurityGroups_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.EC2Client{
+		DescribeSecurityGroupsFn: func(*ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	sgc := newTestSecurityGroupsCrawler(mc)
+
+	err := sgc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, sgc.Count())
+}
+
+func setupSecurityGroupsCrawler(t *testing.T) *SecurityGroupsCrawler {
+	mc := &mock.EC2Client{
+		DescribeSecurityGroupsFn: func(*ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+			return &ec2.DescribeSecurityGroupsOutput{
+				SecurityGroups: []*ec2.SecurityGroup{
+					{
+						GroupId: aws.String("sg-ssh"),
+						IpPermissions: []*ec2.IpPermission{
+							{
+								IpProtocol: aws.String("tcp"),
+								FromPort:   aws.Int64(22),
+								ToPort:     aws.Int64(22),
+								IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+								Ipv6Ranges: []*ec2.Ipv6Range{{CidrIpv6: aws.String("::/0")}},
+							},
+						},
+						IpPermissionsEgress: []*ec2.IpPermission{
+							{
+								IpProtocol: aws.String("-1"),
+								IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+							},
+						},
+						Tags: []*ec2.Tag{{Key: aws.String("Team"), Value: aws.String("infra")}},
+					},
+					{
+						GroupId: aws.String("sg-web"),
+						IpPermissions: []*ec2.IpPermission{
+							{
+								IpProtocol: aws.String("tcp"),
+								FromPort:   aws.Int64(443),
+								ToPort:     aws.Int64(443),
+								IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+							},
+							{
+								IpProtocol: aws.String("tcp"),
+								FromPort:   aws.Int64(0),
+								ToPort:     aws.Int64(1024),
+								UserIdGroupPairs: []*ec2.UserIdGroupPair{
+									{GroupId: aws.String("sg-ssh"), UserId: aws.String("111111111111")},
+								},
+								PrefixListIds: []*ec2.PrefixListId{{PrefixListId: aws.String("pl-1")}},
+							},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+	sgc := newTestSecurityGroupsCrawler(mc)
+	assert.Nil(t, sgc.DoCrawl())
+	return sgc
+}
+
+func Test_SecurityGroups_Rules(t *testing.T) {
+	sgc := setupSecurityGroupsCrawler(t)
+
+	ssh := sgc.Get("sg-ssh")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"Protocol": "tcp", "FromPort": float64(22), "ToPort": float64(22), "Cidr": "0.0.0.0/0"},
+		map[string]interface{}{"Protocol": "tcp", "FromPort": float64(22), "ToPort": float64(22), "Cidr": "::/0"},
+	}, ssh["Ingress"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"Protocol": "all", "FromPort": float64(0), "ToPort": float64(65535), "Cidr": "0.0.0.0/0"},
+	}, ssh["Egress"])
+	assert.Equal(t, "infra", ssh["Tags"].([]interface{})[0].(map[string]interface{})["Team"])
+
+	web := sgc.Get("sg-web")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"Protocol": "tcp", "FromPort": float64(443), "ToPort": float64(443), "Cidr": "0.0.0.0/0"},
+		map[string]interface{}{"Protocol": "tcp", "FromPort": float64(0), "ToPort": float64(1024), "PrefixListId": "pl-1"},
+		map[string]interface{}{"Protocol": "tcp", "FromPort": float64(0), "ToPort": float64(1024), "GroupId": "sg-ssh", "UserId": "111111111111"},
+	}, web["Ingress"])
+	assert.Equal(t, []interface{}{}, web["Egress"])
+}
+
+func Test_SecurityGroups_Filter(t *testing.T) {
+	sgc := setupSecurityGroupsCrawler(t)
+
+	for expr, expected := range map[string][]string{
+		"Ingress.(Cidr:0.0.0.0/0 AND FromPort<=22 AND ToPort>=22)": {"sg-ssh"},
+		"Ingress.(GroupId:sg-ssh AND ToPort>=22)":                  {"sg-web"},
+		"Egress.Protocol:all":                                      {"sg-ssh"},
+	} {
+		f, err := filter.Parse(expr)
+		assert.Nil(t, err)
+		var matched []string
+		for _, doc := range sgc.ListExpanded() {
+			if f.Match(doc) {
+				matched = append(matched, doc["GroupId"].(string))
+			}
+		}
+		assert.Equal(t, expected, matched, expr)
+	}
+}
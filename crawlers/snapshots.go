@@ -0,0 +1,92 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/sirupsen/logrus"
+)
+
+// ownerSelf stands for the account of the credentials used, which is the
+// crawled account
+const ownerSelf = "self"
+
+type snapshotsClient interface {
+	DescribeSnapshots(*ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error)
+}
+
+// The SnapshotsCrawler crawls the EBS snapshots owned by every account, in
+// every region. Public snapshots and those shared by other accounts are left
+// out, as they are not ours to clean up.
+type SnapshotsCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]snapshotsClient
+}
+
+// NewSnapshotsCrawler is the constructor of this crawler
+func NewSnapshotsCrawler(c *config.Config) *SnapshotsCrawler {
+	clients := make(map[melkor.Scope]snapshotsClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
+	return &SnapshotsCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (s *SnapshotsCrawler) Resource() string {
+	return "Snapshots"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (s *SnapshotsCrawler) DoCrawl() error {
+	logrus.WithField("resource", s.Resource()).Info("Crawling")
+
+	err := s.crawl(scopes(s.config), expandTagged, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return s.describeSnapshots(s.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": s.Resource(),
+		"count":    s.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeSnapshots walks all pages of snapshots owned by the account. A
+// failing page fails the whole crawl, leaving the previous snapshot in place.
+func (s *SnapshotsCrawler) describeSnapshots(client snapshotsClient) ([]melkor.Item, error) {
+	params := &ec2.DescribeSnapshotsInput{
+		MaxResults: pageSize(s.config),
+		OwnerIds:   aws.StringSlice([]string{ownerSelf}),
+	}
+	var items []melkor.Item
+	for page := 1; ; page++ {
+		resp, err := client.DescribeSnapshots(params)
+		if err != nil {
+			return nil, fmt.Errorf("describing snapshots, page %d: %s", page, err)
+		}
+
+		for _, snap := range resp.Snapshots {
+			items = append(items, melkor.Item{
+				ID:    aws.StringValue(snap.SnapshotId),
+				Value: snap,
+			})
+		}
+
+		if aws.StringValue(resp.NextToken) == "" {
+			return items, nil
+		}
+		params.NextToken = resp.NextToken
+	}
+}
@@ -0,0 +1,72 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSnapshotsCrawler(client snapshotsClient) *SnapshotsCrawler {
+	return &SnapshotsCrawler{
+		config:  &config.Config{AWSRegion: testRegion, PageSize: 50},
+		clients: map[melkor.Scope]snapshotsClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_Snapshots_Resource(t *testing.T) {
+	sc := NewSnapshotsCrawler(&config.Config{})
+
+	assert.Equal(t, "Snapshots", sc.Resource())
+}
+
+func Test_Snapshots_DoCrawl(t *testing.T) {
+	mc := &mock.EC2Client{}
+	sc := newTestSnapshotsCrawler(mc)
+
+	err := sc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"snap-0", "snap-1"}, sc.List())
+	assert.Equal(t, []string{"self"}, aws.StringValueSlice(mc.DescribeSnapshotsInputs[0].OwnerIds))
+}
+
+func Test_Snapshots_DoCrawl_Pages(t *testing.T) {
+	mc := &mock.EC2Client{
+		DescribeSnapshotsPages: []*ec2.DescribeSnapshotsOutput{
+			{Snapshots: []*ec2.Snapshot{{SnapshotId: aws.String("snap-0"), VolumeId: aws.String("vol-0")}}},
+			{Snapshots: []*ec2.Snapshot{{SnapshotId: aws.String("snap-1"), VolumeId: aws.String("vol-1")}}},
+		},
+	}
+	sc := newTestSnapshotsCrawler(mc)
+
+	err := sc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"snap-0", "snap-1"}, sc.List())
+	assert.Equal(t, "vol-1", sc.Get("snap-1")["VolumeId"])
+	assert.Len(t, mc.DescribeSnapshotsInputs, 2)
+	for _, in := range mc.DescribeSnapshotsInputs {
+		assert.Equal(t, []string{"self"}, aws.StringValueSlice(in.OwnerIds))
+		assert.Equal(t, int64(50), aws.Int64Value(in.MaxResults))
+	}
+}
+
+func Test_Snapshots_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.EC2Client{
+		DescribeSnapshotsFn: func(*ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	sc := newTestSnapshotsCrawler(mc)
+
+	err := sc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, sc.Count())
+}
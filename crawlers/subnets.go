@@ -0,0 +1,100 @@
+package crawlers
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/sirupsen/logrus"
+)
+
+type subnetsClient interface {
+	DescribeSubnets(*ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error)
+}
+
+// The SubnetsCrawler crawls the subnets of every account and region, counting
+// the IP addresses of each, see expandSubnet
+type SubnetsCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]subnetsClient
+}
+
+// NewSubnetsCrawler is the constructor of this crawler
+func NewSubnetsCrawler(c *config.Config) *SubnetsCrawler {
+	clients := make(map[melkor.Scope]subnetsClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
+	return &SubnetsCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (s *SubnetsCrawler) Resource() string {
+	return "Subnets"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (s *SubnetsCrawler) DoCrawl() error {
+	logrus.WithField("resource", s.Resource()).Info("Crawling")
+
+	err := s.crawl(scopes(s.config), expandSubnet, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return s.describeSubnets(s.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": s.Resource(),
+		"count":    s.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeSubnets fetches all subnets, which AWS hands out in a single
+// response
+func (s *SubnetsCrawler) describeSubnets(client subnetsClient) ([]melkor.Item, error) {
+	resp, err := client.DescribeSubnets(&ec2.DescribeSubnetsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("describing subnets: %s", err)
+	}
+	var items []melkor.Item
+	for _, subnet := range resp.Subnets {
+		items = append(items, melkor.Item{
+			ID:    aws.StringValue(subnet.SubnetId),
+			Value: subnet,
+		})
+	}
+	return items, nil
+}
+
+// reservedAddresses is the number of addresses AWS reserves in every subnet:
+// the network address, the next three and the broadcast address
+const reservedAddresses = 5
+
+// expandSubnet expands a subnet, adding the TotalIpAddressCount of addresses
+// usable in its CIDR block, the FreeIpAddressCount of those available and the
+// UsedIpAddressCount of the others
+func expandSubnet(item interface{}) map[string]interface{} {
+	subnet := item.(*ec2.Subnet)
+	data := expandTagged(subnet)
+	_, cidr, err := net.ParseCIDR(aws.StringValue(subnet.CidrBlock))
+	if err != nil {
+		return data
+	}
+	ones, bits := cidr.Mask.Size()
+	total := int64(1)<<uint(bits-ones) - reservedAddresses
+	free := aws.Int64Value(subnet.AvailableIpAddressCount)
+	data["TotalIpAddressCount"] = total
+	data["FreeIpAddressCount"] = free
+	data["UsedIpAddressCount"] = total - free
+	return data
+}
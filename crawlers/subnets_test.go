@@ -0,0 +1,79 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/fixtures"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSubnetsCrawler(client subnetsClient) *SubnetsCrawler {
+	return &SubnetsCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]subnetsClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_Subnets_Resource(t *testing.T) {
+	sc := NewSubnetsCrawler(&config.Config{})
+
+	assert.Equal(t, "Subnets", sc.Resource())
+}
+
+func Test_Subnets_DoCrawl(t *testing.T) {
+	mc := &mock.NetworkClient{}
+	sc := newTestSubnetsCrawler(mc)
+
+	err := sc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeSubnetsFnInvoked)
+	assert.Equal(t, []string{"subnet-0", "subnet-1"}, sc.List())
+}
+
+func Test_Subnets_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.NetworkClient{
+		DescribeSubnetsFn: func(*ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	sc := newTestSubnetsCrawler(mc)
+
+	err := sc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, sc.Count())
+}
+
+func Test_Subnets_IpAddressCounts(t *testing.T) {
+	mc := &mock.NetworkClient{
+		DescribeSubnetsFn: func(*ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+			small := &ec2.Subnet{
+				SubnetId:                aws.String("subnet-small"),
+				CidrBlock:               aws.String("10.0.9.0/28"),
+				AvailableIpAddressCount: aws.Int64(0),
+			}
+			return &ec2.DescribeSubnetsOutput{Subnets: append(fixtures.Subnets(2), small)}, nil
+		},
+	}
+	sc := newTestSubnetsCrawler(mc)
+	assert.Nil(t, sc.DoCrawl())
+
+	subnet := sc.Get("subnet-1")
+	assert.Equal(t, float64(251), subnet["TotalIpAddressCount"])
+	assert.Equal(t, float64(250), subnet["FreeIpAddressCount"])
+	assert.Equal(t, float64(1), subnet["UsedIpAddressCount"])
+
+	small := sc.Get("subnet-small")
+	assert.Equal(t, float64(11), small["TotalIpAddressCount"])
+	assert.Equal(t, float64(0), small["FreeIpAddressCount"])
+	assert.Equal(t, float64(11), small["UsedIpAddressCount"])
+
+	assert.Equal(t, []string{"subnet-small"}, matching(t, sc, "FreeIpAddressCount<10"))
+}
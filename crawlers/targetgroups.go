@@ -0,0 +1,142 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/fatih/structs"
+	"github.com/sirupsen/logrus"
+)
+
+type targetGroupsClient interface {
+	DescribeTargetGroups(*elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error)
+	DescribeTargetHealth(*elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error)
+}
+
+const (
+	// targetHealthy is the state of targets passing the health check of their
+	// target group
+	targetHealthy = "healthy"
+	// targetTypeInstance is the type of target groups whose targets are
+	// instances, rather than IP addresses or Lambda functions
+	targetTypeInstance = "instance"
+)
+
+// A targetGroup is a target group along with the health of its targets, as of
+// when it was crawled
+type targetGroup struct {
+	*elbv2.TargetGroup
+	health []*elbv2.TargetHealthDescription
+}
+
+// The TargetGroupsCrawler crawls the target groups of the application and
+// network load balancers of every account and region, along with the current
+// health of their targets
+type TargetGroupsCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]targetGroupsClient
+}
+
+// NewTargetGroupsCrawler is the constructor of this crawler
+func NewTargetGroupsCrawler(c *config.Config) *TargetGroupsCrawler {
+	clients := make(map[melkor.Scope]targetGroupsClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = elbv2.New(sess)
+	}
+	return &TargetGroupsCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (g *TargetGroupsCrawler) Resource() string {
+	return "TargetGroups"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (g *TargetGroupsCrawler) DoCrawl() error {
+	logrus.WithField("resource", g.Resource()).Info("Crawling")
+
+	err := g.crawl(scopes(g.config), expandTargetGroup, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return g.describeTargetGroups(g.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": g.Resource(),
+		"count":    g.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeTargetGroups walks all pages of target groups, asking for the health
+// of the targets of each. A failing call fails the whole crawl, leaving the
+// previous snapshot in place.
+func (g *TargetGroupsCrawler) describeTargetGroups(client targetGroupsClient) ([]melkor.Item, error) {
+	params := &elbv2.DescribeTargetGroupsInput{
+		PageSize: elbPageSize(g.config),
+	}
+	var items []melkor.Item
+	for page := 1; ; page++ {
+		resp, err := client.DescribeTargetGroups(params)
+		if err != nil {
+			return nil, fmt.Errorf("describing target groups, page %d: %s", page, err)
+		}
+
+		for _, tg := range resp.TargetGroups {
+			arn := aws.StringValue(tg.TargetGroupArn)
+			health, err := client.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
+				TargetGroupArn: tg.TargetGroupArn,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("describing target health of %s: %s", arn, err)
+			}
+			items = append(items, melkor.Item{
+				ID:    arn,
+				Value: &targetGroup{tg, health.TargetHealthDescriptions},
+			})
+		}
+
+		if aws.StringValue(resp.NextMarker) == "" {
+			return items, nil
+		}
+		params.Marker = resp.NextMarker
+	}
+}
+
+// expandTargetGroup expands a target group, adding the health of its targets
+// and, if they are instances, the HealthyInstanceIds of those passing the
+// health check
+func expandTargetGroup(item interface{}) map[string]interface{} {
+	tg := item.(*targetGroup)
+	data := structs.Map(tg.TargetGroup)
+	targets := []interface{}{}
+	healthy := []string{}
+	for _, d := range tg.health {
+		targets = append(targets, structs.Map(d))
+		if d.Target == nil || !instanceTargets(tg.TargetGroup) {
+			continue
+		}
+		if d.TargetHealth != nil && aws.StringValue(d.TargetHealth.State) == targetHealthy {
+			healthy = append(healthy, aws.StringValue(d.Target.Id))
+		}
+	}
+	data["TargetHealthDescriptions"] = targets
+	data["HealthyInstanceIds"] = healthy
+	return data
+}
+
+// instanceTargets checks whether the targets of a group are instances. Groups
+// from before target types were introduced have none, and are of instances.
+func instanceTargets(tg *elbv2.TargetGroup) bool {
+	t := aws.StringValue(tg.TargetType)
+	return t == "" || t == targetTypeInstance
+}
@@ -0,0 +1,105 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/fixtures"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTargetGroupsCrawler(client targetGroupsClient) *TargetGroupsCrawler {
+	return &TargetGroupsCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]targetGroupsClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_TargetGroups_Resource(t *testing.T) {
+	gc := NewTargetGroupsCrawler(&config.Config{})
+
+	assert.Equal(t, "TargetGroups", gc.Resource())
+}
+
+func Test_TargetGroups_DoCrawl(t *testing.T) {
+	mc := &mock.ELBv2Client{}
+	gc := newTestTargetGroupsCrawler(mc)
+
+	err := gc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeTargetGroupsFnInvoked)
+	assert.True(t, mc.DescribeTargetHealthFnInvoked)
+	assert.Equal(t, []string{fixtures.TargetGroupArn(0), fixtures.TargetGroupArn(1)}, gc.List())
+}
+
+func Test_TargetGroups_DoCrawl_Pages(t *testing.T) {
+	tgs := fixtures.TargetGroups(3)
+	mc := &mock.ELBv2Client{
+		DescribeTargetGroupsPages: []*elbv2.DescribeTargetGroupsOutput{
+			{TargetGroups: tgs[:2]},
+			{TargetGroups: tgs[2:]},
+		},
+	}
+	gc := newTestTargetGroupsCrawler(mc)
+
+	err := gc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{fixtures.TargetGroupArn(0), fixtures.TargetGroupArn(1), fixtures.TargetGroupArn(2)}, gc.List())
+}
+
+func Test_TargetGroups_Expand(t *testing.T) {
+	gc := newTestTargetGroupsCrawler(&mock.ELBv2Client{})
+	assert.Nil(t, gc.DoCrawl())
+
+	data := gc.Get(fixtures.TargetGroupArn(1))
+	assert.Equal(t, []interface{}{"i-1"}, data["HealthyInstanceIds"])
+	assert.Len(t, data["TargetHealthDescriptions"], 3)
+	assert.Equal(t, []string{fixtures.TargetGroupArn(0)}, matching(t, gc, "HealthyInstanceIds:i-0"))
+	assert.Equal(t, []string{fixtures.TargetGroupArn(1)},
+		matching(t, gc, "TargetHealthDescriptions.(Target.Id:i-2 AND TargetHealth.State:unhealthy)"))
+}
+
+func Test_expandTargetGroup_Targets(t *testing.T) {
+	health := []*elbv2.TargetHealthDescription{
+		{TargetHealth: &elbv2.TargetHealth{State: aws.String("healthy")}},
+		{
+			Target:       &elbv2.TargetDescription{Id: aws.String("10.0.0.1")},
+			TargetHealth: &elbv2.TargetHealth{State: aws.String("healthy")},
+		},
+	}
+	for targetType, expected := range map[string][]string{
+		"":         {"10.0.0.1"},
+		"instance": {"10.0.0.1"},
+		"ip":       {},
+		"lambda":   {},
+	} {
+		tg := &elbv2.TargetGroup{TargetGroupArn: aws.String(fixtures.TargetGroupArn(0))}
+		if targetType != "" {
+			tg.TargetType = aws.String(targetType)
+		}
+		data := expandTargetGroup(&targetGroup{tg, health})
+		assert.Equal(t, expected, data["HealthyInstanceIds"], targetType)
+		assert.Len(t, data["TargetHealthDescriptions"], 2, targetType)
+	}
+}
+
+func Test_TargetGroups_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.ELBv2Client{
+		DescribeTargetHealthFn: func(*elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	gc := newTestTargetGroupsCrawler(mc)
+
+	err := gc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, gc.Count())
+}
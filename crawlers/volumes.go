@@ -0,0 +1,85 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/sirupsen/logrus"
+)
+
+type volumesClient interface {
+	DescribeVolumes(*ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error)
+}
+
+// The VolumesCrawler crawls the EBS volumes of every account and region
+type VolumesCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]volumesClient
+}
+
+// NewVolumesCrawler is the constructor of this crawler
+func NewVolumesCrawler(c *config.Config) *VolumesCrawler {
+	clients := make(map[melkor.Scope]volumesClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
+	return &VolumesCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (v *VolumesCrawler) Resource() string {
+	return "Volumes"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (v *VolumesCrawler) DoCrawl() error {
+	logrus.WithField("resource", v.Resource()).Info("Crawling")
+
+	err := v.crawl(scopes(v.config), expandTagged, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return v.describeVolumes(v.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": v.Resource(),
+		"count":    v.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeVolumes walks all pages of volumes. A failing page fails the whole
+// crawl, leaving the previous snapshot in place.
+func (v *VolumesCrawler) describeVolumes(client volumesClient) ([]melkor.Item, error) {
+	params := &ec2.DescribeVolumesInput{
+		MaxResults: pageSize(v.config),
+	}
+	var items []melkor.Item
+	for page := 1; ; page++ {
+		resp, err := client.DescribeVolumes(params)
+		if err != nil {
+			return nil, fmt.Errorf("describing volumes, page %d: %s", page, err)
+		}
+
+		for _, vol := range resp.Volumes {
+			items = append(items, melkor.Item{
+				ID:    aws.StringValue(vol.VolumeId),
+				Value: vol,
+			})
+		}
+
+		if aws.StringValue(resp.NextToken) == "" {
+			return items, nil
+		}
+		params.NextToken = resp.NextToken
+	}
+}
@@ -0,0 +1,92 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/filter"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestVolumesCrawler(client volumesClient) *VolumesCrawler {
+	return &VolumesCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]volumesClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_Volumes_Resource(t *testing.T) {
+	vc := NewVolumesCrawler(&config.Config{})
+
+	assert.Equal(t, "Volumes", vc.Resource())
+}
+
+func Test_Volumes_DoCrawl(t *testing.T) {
+	mc := &mock.EC2Client{}
+	vc := newTestVolumesCrawler(mc)
+
+	err := vc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeVolumesFnInvoked)
+	assert.Equal(t, []string{"vol-0", "vol-1"}, vc.List())
+}
+
+func Test_Volumes_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.EC2Client{
+		DescribeVolumesFn: func(*ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	vc := newTestVolumesCrawler(mc)
+
+	err := vc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, vc.Count())
+}
+
+func Test_Volumes_Attachments(t *testing.T) {
+	mc := &mock.EC2Client{
+		DescribeVolumesPages: []*ec2.DescribeVolumesOutput{
+			{Volumes: []*ec2.Volume{
+				{
+					VolumeId: aws.String("vol-attached"),
+					Attachments: []*ec2.VolumeAttachment{
+						{InstanceId: aws.String("i-0"), Device: aws.String("/dev/xvda")},
+					},
+					Tags: []*ec2.Tag{{Key: aws.String("Team"), Value: aws.String("infra")}},
+				},
+			}},
+			{Volumes: []*ec2.Volume{
+				{VolumeId: aws.String("vol-orphan"), State: aws.String("available")},
+			}},
+		},
+	}
+	vc := newTestVolumesCrawler(mc)
+
+	err := vc.DoCrawl()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"vol-attached", "vol-orphan"}, vc.List())
+
+	for expr, expected := range map[string]string{
+		"Attachments.InstanceId:i-0":     "vol-attached",
+		"Attachments.InstanceId missing": "vol-orphan",
+		"Tags.Team:infra":                "vol-attached",
+	} {
+		f, err := filter.Parse(expr)
+		assert.Nil(t, err)
+		var matched []string
+		for _, doc := range vc.ListExpanded() {
+			if f.Match(doc) {
+				matched = append(matched, doc["VolumeId"].(string))
+			}
+		}
+		assert.Equal(t, []string{expected}, matched, expr)
+	}
+}
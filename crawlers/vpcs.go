@@ -0,0 +1,74 @@
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/sirupsen/logrus"
+)
+
+type vpcsClient interface {
+	DescribeVpcs(*ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error)
+}
+
+// The VpcsCrawler crawls the VPCs of every account and region
+type VpcsCrawler struct {
+	base
+	config *config.Config
+	// clients holds one client per crawled account and region
+	clients map[melkor.Scope]vpcsClient
+}
+
+// NewVpcsCrawler is the constructor of this crawler
+func NewVpcsCrawler(c *config.Config) *VpcsCrawler {
+	clients := make(map[melkor.Scope]vpcsClient)
+	for scope, sess := range newSessions(c) {
+		clients[scope] = ec2.New(sess)
+	}
+	return &VpcsCrawler{
+		base:    newBase(c),
+		config:  c,
+		clients: clients,
+	}
+}
+
+// Resource identifies the name of the crawled resource
+func (v *VpcsCrawler) Resource() string {
+	return "Vpcs"
+}
+
+// DoCrawl handles the crawling of AWS, once per account and region
+func (v *VpcsCrawler) DoCrawl() error {
+	logrus.WithField("resource", v.Resource()).Info("Crawling")
+
+	err := v.crawl(scopes(v.config), expandTagged, func(scope melkor.Scope) ([]melkor.Item, error) {
+		return v.describeVpcs(v.clients[scope])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"resource": v.Resource(),
+		"count":    v.Count(),
+	}).Info("Done crawling")
+
+	return err
+}
+
+// describeVpcs fetches all VPCs, which AWS hands out in a single response
+func (v *VpcsCrawler) describeVpcs(client vpcsClient) ([]melkor.Item, error) {
+	resp, err := client.DescribeVpcs(&ec2.DescribeVpcsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("describing VPCs: %s", err)
+	}
+	var items []melkor.Item
+	for _, vpc := range resp.Vpcs {
+		items = append(items, melkor.Item{
+			ID:    aws.StringValue(vpc.VpcId),
+			Value: vpc,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,59 @@
+package crawlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/mock"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestVpcsCrawler(client vpcsClient) *VpcsCrawler {
+	return &VpcsCrawler{
+		config:  &config.Config{AWSRegion: testRegion},
+		clients: map[melkor.Scope]vpcsClient{{Region: testRegion}: client},
+	}
+}
+
+func Test_Vpcs_Resource(t *testing.T) {
+	vc := NewVpcsCrawler(&config.Config{})
+
+	assert.Equal(t, "Vpcs", vc.Resource())
+}
+
+func Test_Vpcs_DoCrawl(t *testing.T) {
+	mc := &mock.NetworkClient{}
+	vc := newTestVpcsCrawler(mc)
+
+	err := vc.DoCrawl()
+	assert.Nil(t, err)
+
+	assert.True(t, mc.DescribeVpcsFnInvoked)
+	assert.Equal(t, []string{"vpc-0", "vpc-1"}, vc.List())
+}
+
+func Test_Vpcs_DoCrawl_Fail(t *testing.T) {
+	mc := &mock.NetworkClient{
+		DescribeVpcsFn: func(*ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error) {
+			return nil, errors.New("something went terribly wrong")
+		},
+	}
+	vc := newTestVpcsCrawler(mc)
+
+	err := vc.DoCrawl()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, vc.Count())
+}
+
+func Test_Vpcs_Expand(t *testing.T) {
+	vc := newTestVpcsCrawler(&mock.NetworkClient{})
+	assert.Nil(t, vc.DoCrawl())
+
+	assert.Equal(t, "10.0.0.0/16", vc.Get("vpc-0")["CidrBlock"])
+	assert.Equal(t, []string{"vpc-0"}, matching(t, vc, "IsDefault:true"))
+	assert.Equal(t, []string{"vpc-1"}, matching(t, vc, "Tags.Name:vpc-1"))
+}
@@ -0,0 +1,141 @@
+package melkor
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// A FieldValue is a field added to or removed from a resource
+type FieldValue struct {
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// A FieldChange is a field whose value changed
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// A Diff lists the fields that differ between two versions of a resource.
+// Paths are dot separated, with list elements addressed by their index, such
+// as Tags.0.Value or SecurityGroups.0.GroupId.
+type Diff struct {
+	Added   []FieldValue  `json:"added"`
+	Removed []FieldValue  `json:"removed"`
+	Changed []FieldChange `json:"changed"`
+}
+
+// DiffDocs compares two expanded versions of a resource. Either may be nil,
+// for a resource which did not exist at the time.
+func DiffDocs(old, new map[string]interface{}) Diff {
+	d := Diff{
+		Added:   []FieldValue{},
+		Removed: []FieldValue{},
+		Changed: []FieldChange{},
+	}
+	d.diffMaps("", old, new)
+	return d
+}
+
+func (d *Diff) diff(path string, old, new interface{}) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			d.diffMaps(path, o, n)
+			return
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok {
+			d.diffLists(path, o, n)
+			return
+		}
+	}
+	if !reflect.DeepEqual(old, new) {
+		d.Changed = append(d.Changed, FieldChange{Path: path, Old: old, New: new})
+	}
+}
+
+func (d *Diff) diffMaps(path string, old, new map[string]interface{}) {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		o, inOld := old[k]
+		n, inNew := new[k]
+		p := join(path, k)
+		switch {
+		case !inOld:
+			d.Added = append(d.Added, FieldValue{Path: p, Value: n})
+		case !inNew:
+			d.Removed = append(d.Removed, FieldValue{Path: p, Value: o})
+		default:
+			d.diff(p, o, n)
+		}
+	}
+}
+
+func (d *Diff) diffLists(path string, old, new []interface{}) {
+	for idx := 0; idx < len(old) || idx < len(new); idx++ {
+		p := join(path, strconv.Itoa(idx))
+		switch {
+		case idx >= len(old):
+			d.Added = append(d.Added, FieldValue{Path: p, Value: new[idx]})
+		case idx >= len(new):
+			d.Removed = append(d.Removed, FieldValue{Path: p, Value: old[idx]})
+		default:
+			d.diff(p, old[idx], new[idx])
+		}
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// A CollectionDiff lists the resources which differ between two snapshots
+type CollectionDiff struct {
+	Added    []Key `json:"added"`
+	Removed  []Key `json:"removed"`
+	Modified []Key `json:"modified"`
+}
+
+// DiffSnapshots compares the resources of two snapshots, matching them by
+// account, region and id
+func DiffSnapshots(old, new *Snapshot) CollectionDiff {
+	d := CollectionDiff{
+		Added:    []Key{},
+		Removed:  []Key{},
+		Modified: []Key{},
+	}
+	for idx := range new.items {
+		k := new.Key(idx)
+		o := old.IndexOfKey(k)
+		if o < 0 {
+			d.Added = append(d.Added, k)
+			continue
+		}
+		if !reflect.DeepEqual(old.Expand(o), new.Expand(idx)) {
+			d.Modified = append(d.Modified, k)
+		}
+	}
+	for idx := range old.items {
+		if k := old.Key(idx); new.IndexOfKey(k) < 0 {
+			d.Removed = append(d.Removed, k)
+		}
+	}
+	return d
+}
@@ -0,0 +1,126 @@
+package melkor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DiffDocs(t *testing.T) {
+	old := map[string]interface{}{
+		"InstanceId": "i-0",
+		"State":      map[string]interface{}{"Name": "pending", "Code": 0.0},
+		"Tags":       map[string]interface{}{"Team": "a", "Env": "test"},
+		"SecurityGroups": []interface{}{
+			map[string]interface{}{"GroupId": "sg-1"},
+		},
+		"KeyName": "old",
+	}
+	new := map[string]interface{}{
+		"InstanceId": "i-0",
+		"State":      map[string]interface{}{"Name": "running", "Code": 16.0},
+		"Tags":       map[string]interface{}{"Team": "b", "Owner": "me"},
+		"SecurityGroups": []interface{}{
+			map[string]interface{}{"GroupId": "sg-2"},
+			map[string]interface{}{"GroupId": "sg-3"},
+		},
+		"PublicIpAddress": "1.2.3.4",
+	}
+
+	d := DiffDocs(old, new)
+
+	assert.Equal(t, []FieldValue{
+		{Path: "PublicIpAddress", Value: "1.2.3.4"},
+		{Path: "SecurityGroups.1", Value: map[string]interface{}{"GroupId": "sg-3"}},
+		{Path: "Tags.Owner", Value: "me"},
+	}, d.Added)
+	assert.Equal(t, []FieldValue{
+		{Path: "KeyName", Value: "old"},
+		{Path: "Tags.Env", Value: "test"},
+	}, d.Removed)
+	assert.Equal(t, []FieldChange{
+		{Path: "SecurityGroups.0.GroupId", Old: "sg-1", New: "sg-2"},
+		{Path: "State.Code", Old: 0.0, New: 16.0},
+		{Path: "State.Name", Old: "pending", New: "running"},
+		{Path: "Tags.Team", Old: "a", New: "b"},
+	}, d.Changed)
+}
+
+func Test_DiffDocs_Tags(t *testing.T) {
+	tag := func(key, value string) map[string]interface{} {
+		return map[string]interface{}{"Key": key, "Value": value, key: value}
+	}
+	old := map[string]interface{}{"Tags": []interface{}{tag("Team", "a")}}
+	new := map[string]interface{}{"Tags": []interface{}{tag("Team", "b"), tag("Env", "prod")}}
+
+	d := DiffDocs(old, new)
+
+	assert.Equal(t, []FieldValue{{Path: "Tags.1", Value: tag("Env", "prod")}}, d.Added)
+	assert.Empty(t, d.Removed)
+	assert.Equal(t, []FieldChange{
+		{Path: "Tags.0.Team", Old: "a", New: "b"},
+		{Path: "Tags.0.Value", Old: "a", New: "b"},
+	}, d.Changed)
+}
+
+func Test_DiffDocs_TypeChange(t *testing.T) {
+	d := DiffDocs(
+		map[string]interface{}{"a": map[string]interface{}{"b": "c"}},
+		map[string]interface{}{"a": "c"},
+	)
+
+	assert.Empty(t, d.Added)
+	assert.Empty(t, d.Removed)
+	assert.Equal(t, []FieldChange{{Path: "a", Old: map[string]interface{}{"b": "c"}, New: "c"}}, d.Changed)
+}
+
+func Test_DiffDocs_Nil(t *testing.T) {
+	doc := map[string]interface{}{"a": "b"}
+
+	assert.Equal(t, []FieldValue{{Path: "a", Value: "b"}}, DiffDocs(nil, doc).Added)
+	assert.Equal(t, []FieldValue{{Path: "a", Value: "b"}}, DiffDocs(doc, nil).Removed)
+	d := DiffDocs(doc, doc)
+	assert.Empty(t, d.Added)
+	assert.Empty(t, d.Removed)
+	assert.Empty(t, d.Changed)
+	assert.NotNil(t, d.Changed)
+}
+
+func Test_DiffSnapshots(t *testing.T) {
+	now := time.Now()
+	old := crawl(now, eu, map[string]string{"a": "running", "b": "running", "c": "pending"})
+	new := crawl(now, eu, map[string]string{"b": "running", "c": "running", "d": "pending"})
+
+	d := DiffSnapshots(old, new)
+
+	assert.Equal(t, []Key{{Scope: eu, ID: "d"}}, d.Added)
+	assert.Equal(t, []Key{{Scope: eu, ID: "a"}}, d.Removed)
+	assert.Equal(t, []Key{{Scope: eu, ID: "c"}}, d.Modified)
+
+	d = DiffSnapshots(&Snapshot{}, &Snapshot{})
+	assert.NotNil(t, d.Added)
+	assert.Empty(t, d.Added)
+}
+
+func Test_DiffSnapshots_SharedID(t *testing.T) {
+	now := time.Now()
+	us := Scope{Region: "us-east-1"}
+	old := Merge(map[Scope]*Snapshot{
+		eu: crawl(now, eu, map[string]string{"a": "running"}),
+		us: crawl(now, us, map[string]string{"a": "running"}),
+	})
+	new := Merge(map[Scope]*Snapshot{
+		eu: crawl(now, eu, map[string]string{"a": "running"}),
+		us: crawl(now, us, map[string]string{"a": "stopped", "b": "running"}),
+	})
+
+	d := DiffSnapshots(old, new)
+
+	assert.Equal(t, []Key{{Scope: us, ID: "b"}}, d.Added)
+	assert.Empty(t, d.Removed)
+	assert.Equal(t, []Key{{Scope: us, ID: "a"}}, d.Modified)
+
+	d = DiffSnapshots(old, Merge(map[Scope]*Snapshot{eu: crawl(now, eu, map[string]string{"a": "running"})}))
+	assert.Equal(t, []Key{{Scope: us, ID: "a"}}, d.Removed)
+}
@@ -0,0 +1,368 @@
+// Package fields projects expanded items onto a selection of their fields,
+// such as InstanceId,Tags.Name,ip=PrivateIpAddress.
+package fields
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A Selection picks fields out of expanded items, keeping the structure they
+// are nested in. Lists along a path are descended into element by element,
+// keeping the elements holding any of the fields. A * selects every key of an
+// object, or every element of a list.
+//
+// An alias, as in name=Tags.Name, puts the value at a path at the top level
+// under a name of its own. A path going through lists or wildcards gives the
+// list of all values found.
+type Selection struct {
+	root    *node
+	aliases []alias
+	// fields are the selected paths and aliases, in the order given
+	fields []string
+}
+
+// A node is a level of the tree of selected paths. All is set if everything
+// below it is selected.
+type node struct {
+	all      bool
+	children map[string]*node
+}
+
+type alias struct {
+	name string
+	path []string
+}
+
+// Parse parses a comma separated list of fields. Fields sharing a prefix can
+// be grouped in parentheses, Edda style: State.(Code,Name) selects both
+// State.Code and State.Name.
+func Parse(expr string) (*Selection, error) {
+	s := &Selection{root: &node{}}
+	p := &parser{expr: expr}
+	p.skipSpace()
+	if p.pos == len(expr) {
+		return nil, errors.New("no fields selected")
+	}
+	if err := p.list(s, nil, true); err != nil {
+		return nil, err
+	}
+	if p.pos < len(expr) {
+		return nil, p.errorf("unexpected %q", expr[p.pos])
+	}
+	return s, nil
+}
+
+// Apply returns the selected fields of an expanded item
+func (s *Selection) Apply(item map[string]interface{}) map[string]interface{} {
+	out, ok := project(item, s.root).(map[string]interface{})
+	if !ok {
+		out = make(map[string]interface{})
+	}
+	for _, a := range s.aliases {
+		if v, ok := extract(item, a.path); ok {
+			out[a.name] = v
+		}
+	}
+	return out
+}
+
+// Fields returns the selected fields as dot separated paths, and the names of
+// the aliases, in the order they were given. Grouped fields are given in full,
+// as in State.Code and State.Name for State.(Code,Name).
+func (s *Selection) Fields() []string {
+	return append([]string(nil), s.fields...)
+}
+
+// add selects everything below a path
+func (n *node) add(path []string) {
+	for _, seg := range path {
+		if n.all {
+			return
+		}
+		if n.children == nil {
+			n.children = make(map[string]*node)
+		}
+		child, ok := n.children[seg]
+		if !ok {
+			child = &node{}
+			n.children[seg] = child
+		}
+		n = child
+	}
+	n.all, n.children = true, nil
+}
+
+// merge returns the union of two nodes, either of which may be nil
+func merge(a, b *node) *node {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.all || b.all:
+		return &node{all: true}
+	}
+	m := &node{children: make(map[string]*node, len(a.children)+len(b.children))}
+	for seg, child := range a.children {
+		m.children[seg] = child
+	}
+	for seg, child := range b.children {
+		m.children[seg] = merge(m.children[seg], child)
+	}
+	return m
+}
+
+// elements returns the node the elements of a list are projected onto. A *
+// stands for the elements themselves.
+func (n *node) elements() *node {
+	star, ok := n.children["*"]
+	if !ok {
+		return n
+	}
+	rest := &node{children: make(map[string]*node, len(n.children))}
+	for seg, child := range n.children {
+		if seg != "*" {
+			rest.children[seg] = child
+		}
+	}
+	return merge(rest, star)
+}
+
+// project returns the part of a value selected by a node, or nil if nothing
+// was selected
+func project(v interface{}, n *node) interface{} {
+	if n.all {
+		return v
+	}
+	rv := indirect(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		en := n.elements()
+		var out []interface{}
+		for idx := 0; idx < rv.Len(); idx++ {
+			if p := project(rv.Index(idx).Interface(), en); p != nil {
+				out = append(out, p)
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		out := make(map[string]interface{})
+		if star, ok := n.children["*"]; ok {
+			for _, k := range rv.MapKeys() {
+				if p := project(rv.MapIndex(k).Interface(), merge(n.children[k.String()], star)); p != nil {
+					out[k.String()] = p
+				}
+			}
+		} else {
+			for seg, child := range n.children {
+				el := rv.MapIndex(reflect.ValueOf(seg).Convert(rv.Type().Key()))
+				if !el.IsValid() {
+					continue
+				}
+				if p := project(el.Interface(), child); p != nil {
+					out[seg] = p
+				}
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	}
+	return nil
+}
+
+// extract returns the value at a path, or the list of values if the path
+// goes through lists or wildcards
+func extract(v interface{}, path []string) (interface{}, bool) {
+	var values []interface{}
+	multi := collect(v, path, &values)
+	if len(values) == 0 {
+		return nil, false
+	}
+	if !multi {
+		return values[0], true
+	}
+	return values, true
+}
+
+// collect appends the values at a path, returning whether it went through
+// lists or wildcards
+func collect(v interface{}, path []string, values *[]interface{}) bool {
+	rv := indirect(v)
+	if !rv.IsValid() {
+		return false
+	}
+	if len(path) == 0 {
+		*values = append(*values, v)
+		return false
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		rest := path
+		if path[0] == "*" {
+			rest = path[1:]
+		}
+		for idx := 0; idx < rv.Len(); idx++ {
+			collect(rv.Index(idx).Interface(), rest, values)
+		}
+		return true
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return false
+		}
+		if path[0] == "*" {
+			keys := rv.MapKeys()
+			sort.Sort(byString(keys))
+			for _, k := range keys {
+				collect(rv.MapIndex(k).Interface(), path[1:], values)
+			}
+			return true
+		}
+		el := rv.MapIndex(reflect.ValueOf(path[0]).Convert(rv.Type().Key()))
+		if el.IsValid() {
+			return collect(el.Interface(), path[1:], values)
+		}
+	}
+	return false
+}
+
+// indirect follows pointers and interfaces, returning the zero Value for nil
+func indirect(v interface{}) reflect.Value {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+type byString []reflect.Value
+
+func (s byString) Len() int           { return len(s) }
+func (s byString) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byString) Less(i, j int) bool { return s[i].String() < s[j].String() }
+
+// A parser reads a list of fields:
+//
+//	list = item { "," item }
+//	item = name "=" path | path [ "." "(" list ")" ]
+//	path = name { "." name }
+//
+// Aliases are only allowed at the top level.
+type parser struct {
+	expr string
+	pos  int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid fields at position %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.expr) && p.expr[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	if p.pos == len(p.expr) {
+		return 0
+	}
+	return p.expr[p.pos]
+}
+
+func (p *parser) list(s *Selection, prefix []string, top bool) error {
+	for {
+		p.skipSpace()
+		if err := p.item(s, prefix, top); err != nil {
+			return err
+		}
+		p.skipSpace()
+		if p.peek() != ',' {
+			return nil
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) item(s *Selection, prefix []string, top bool) error {
+	start := p.pos
+	path, group, err := p.path()
+	if err != nil {
+		return err
+	}
+	full := append(append([]string(nil), prefix...), path...)
+	switch {
+	case group:
+		p.pos++
+		if err := p.list(s, full, false); err != nil {
+			return err
+		}
+		if p.peek() != ')' {
+			return p.errorf("expected \")\"")
+		}
+		p.pos++
+	case p.peek() == '=':
+		if !top || len(path) != 1 || path[0] == "*" {
+			p.pos = start
+			return p.errorf("an alias must be a single name outside of parentheses")
+		}
+		for _, a := range s.aliases {
+			if a.name == path[0] {
+				p.pos = start
+				return p.errorf("duplicate alias %q", a.name)
+			}
+		}
+		p.pos++
+		p.skipSpace()
+		target, group, err := p.path()
+		if err != nil {
+			return err
+		}
+		if group {
+			return p.errorf("an alias must name a single path")
+		}
+		s.aliases = append(s.aliases, alias{name: path[0], path: target})
+		s.fields = append(s.fields, path[0])
+	default:
+		s.root.add(full)
+		s.fields = append(s.fields, strings.Join(full, "."))
+	}
+	return nil
+}
+
+// path reads a dot separated path. If it is followed by a group, as in
+// State.(Code,Name), group is set and the position is left at the
+// parenthesis.
+func (p *parser) path() (path []string, group bool, err error) {
+	for {
+		start := p.pos
+		for p.pos < len(p.expr) && strings.IndexByte(" .,()=", p.expr[p.pos]) < 0 {
+			p.pos++
+		}
+		if p.pos == start {
+			return nil, false, p.errorf("expected a field name")
+		}
+		path = append(path, p.expr[start:p.pos])
+		if p.peek() != '.' {
+			return path, false, nil
+		}
+		p.pos++
+		if p.peek() == '(' {
+			return path, true, nil
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package fields
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	name     = "web-1"
+	instance = map[string]interface{}{
+		"InstanceId":       "i-0",
+		"PrivateIpAddress": "10.0.0.1",
+		"KeyName":          (*string)(nil),
+		"State":            map[string]interface{}{"Code": 16.0, "Name": "running"},
+		"Tags":             map[string]*string{"Name": &name},
+		"SecurityGroups": []interface{}{
+			map[string]interface{}{"GroupId": "sg-0", "GroupName": "web"},
+			map[string]interface{}{"GroupId": "sg-1", "GroupName": "ssh"},
+			"not an object",
+		},
+		"BlockDeviceMappings": []interface{}{
+			map[string]interface{}{"DeviceName": "/dev/sda1", "Ebs": map[string]interface{}{"VolumeId": "vol-0"}},
+			map[string]interface{}{"DeviceName": "/dev/sdb"},
+		},
+	}
+)
+
+var applyTests = []struct {
+	expr     string
+	expected map[string]interface{}
+}{
+	{"InstanceId", map[string]interface{}{"InstanceId": "i-0"}},
+	{"InstanceId, PrivateIpAddress,Tags.Name", map[string]interface{}{
+		"InstanceId":       "i-0",
+		"PrivateIpAddress": "10.0.0.1",
+		"Tags":             map[string]interface{}{"Name": &name},
+	}},
+	{"State.Name,State", map[string]interface{}{
+		"State": map[string]interface{}{"Code": 16.0, "Name": "running"},
+	}},
+	{"State.(Code,Name),Missing,State.Code.Deeper", map[string]interface{}{
+		"State": map[string]interface{}{"Code": 16.0, "Name": "running"},
+	}},
+	{"SecurityGroups.GroupId", map[string]interface{}{
+		"SecurityGroups": []interface{}{
+			map[string]interface{}{"GroupId": "sg-0"},
+			map[string]interface{}{"GroupId": "sg-1"},
+		},
+	}},
+	{"SecurityGroups.*.GroupName", map[string]interface{}{
+		"SecurityGroups": []interface{}{
+			map[string]interface{}{"GroupName": "web"},
+			map[string]interface{}{"GroupName": "ssh"},
+		},
+	}},
+	{"BlockDeviceMappings.Ebs.VolumeId", map[string]interface{}{
+		"BlockDeviceMappings": []interface{}{
+			map[string]interface{}{"Ebs": map[string]interface{}{"VolumeId": "vol-0"}},
+		},
+	}},
+	{"State.*", map[string]interface{}{
+		"State": map[string]interface{}{"Code": 16.0, "Name": "running"},
+	}},
+	{"KeyName", map[string]interface{}{"KeyName": (*string)(nil)}},
+	{"id=InstanceId,name=Tags.Name,groups=SecurityGroups.GroupId,volumes=BlockDeviceMappings.*.Ebs.VolumeId,state=State.*", map[string]interface{}{
+		"id":      "i-0",
+		"name":    &name,
+		"groups":  []interface{}{"sg-0", "sg-1"},
+		"volumes": []interface{}{"vol-0"},
+		"state":   []interface{}{16.0, "running"},
+	}},
+	{"InstanceId=Tags.Name", map[string]interface{}{"InstanceId": &name}},
+	{"Nothing,nothing=Nothing.Here", map[string]interface{}{}},
+}
+
+func Test_Apply(t *testing.T) {
+	for _, tt := range applyTests {
+		s, err := Parse(tt.expr)
+		if assert.Nil(t, err, tt.expr) {
+			assert.Equal(t, tt.expected, s.Apply(instance), tt.expr)
+		}
+	}
+}
+
+func Test_Parse_Fail(t *testing.T) {
+	for expr, msg := range map[string]string{
+		"":                 "no fields selected",
+		"InstanceId,":      "invalid fields at position 12: expected a field name",
+		"Tags..Name":       "invalid fields at position 6: expected a field name",
+		"State.(Code,Name": "invalid fields at position 17: expected \")\"",
+		"State.(Code)Name": "invalid fields at position 13: unexpected 'N'",
+		"State.(a=Code)":   "invalid fields at position 8: an alias must be a single name outside of parentheses",
+		"a.b=InstanceId":   "invalid fields at position 1: an alias must be a single name outside of parentheses",
+		"a=State.(Code)":   "invalid fields at position 9: an alias must name a single path",
+		"a=Tags,a=State":   "invalid fields at position 8: duplicate alias \"a\"",
+		"(InstanceId)":     "invalid fields at position 1: expected a field name",
+		"InstanceId)":      "invalid fields at position 11: unexpected ')'",
+		"id=":              "invalid fields at position 4: expected a field name",
+	} {
+		_, err := Parse(expr)
+		assert.EqualError(t, err, msg, expr)
+	}
+}
+
+func Test_Fields(t *testing.T) {
+	s, err := Parse("InstanceId, State.(Code,Name),ip=PrivateIpAddress,SecurityGroups.*.GroupId")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"InstanceId", "State.Code", "State.Name", "ip", "SecurityGroups.*.GroupId"}, s.Fields())
+}
@@ -0,0 +1,225 @@
+// Package filter matches expanded items against filter expressions, such as
+// Tags.Team:infra AND NOT State.Name in (stopped, terminated).
+package filter
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// A Filter matches expanded items against a boolean expression of
+// comparisons. A comparison looks up the values at a dot separated path,
+// descending into every element of the lists along the way, and holds if any
+// of them compares true. Strings are compared case-insensitively, except by
+// regular expressions. Numbers, booleans and points in time are compared as
+// such, values of any other type never compare true.
+//
+// Comparisons grouped under a path, as in Ingress.(FromPort<=22 AND
+// ToPort>=22), must all hold for the same element of the lists along it.
+type Filter struct {
+	root node
+}
+
+// Parse parses a filter expression. Malformed expressions give a
+// *SyntaxError.
+func Parse(expr string) (f *Filter, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			f, err = nil, e
+		}
+	}()
+	p := &parser{lex: lexer{input: expr}}
+	return &Filter{root: p.parse()}, nil
+}
+
+// Match checks whether an expanded item matches the filter
+func (f *Filter) Match(item map[string]interface{}) bool {
+	return f.root.match(item)
+}
+
+type node interface {
+	match(item map[string]interface{}) bool
+}
+
+type and struct {
+	left, right node
+}
+
+func (n and) match(item map[string]interface{}) bool {
+	return n.left.match(item) && n.right.match(item)
+}
+
+type or struct {
+	left, right node
+}
+
+func (n or) match(item map[string]interface{}) bool {
+	return n.left.match(item) || n.right.match(item)
+}
+
+type not struct {
+	node
+}
+
+func (n not) match(item map[string]interface{}) bool {
+	return !n.node.match(item)
+}
+
+// exists holds if there is any value at the path
+type exists struct {
+	path []string
+}
+
+func (n exists) match(item map[string]interface{}) bool {
+	return lookup(item, n.path, func(interface{}) bool { return true })
+}
+
+// group holds if any object at the path matches the expression on its own,
+// the paths of which are relative to the object. Unlike comparisons combined
+// with AND, which may each hold for another element of a list, the whole
+// expression must hold for a single element.
+type group struct {
+	path []string
+	node
+}
+
+func (n group) match(item map[string]interface{}) bool {
+	return lookup(item, n.path, func(v interface{}) bool {
+		m, ok := v.(map[string]interface{})
+		return ok && n.node.match(m)
+	})
+}
+
+// comparison holds if any value at the path passes the test
+type comparison struct {
+	path []string
+	test func(v interface{}) bool
+}
+
+func (n comparison) match(item map[string]interface{}) bool {
+	return lookup(item, n.path, n.test)
+}
+
+// lookup calls fn with the values at a path until it returns true. Pointers
+// are followed, and lists are descended into element by element. Values of
+// any other type than expected at a path are skipped.
+func lookup(v interface{}, path []string, fn func(v interface{}) bool) bool {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return false
+	case reflect.Slice, reflect.Array:
+		for idx := 0; idx < rv.Len(); idx++ {
+			if lookup(rv.Index(idx).Interface(), path, fn) {
+				return true
+			}
+		}
+		return false
+	case reflect.Map:
+		if len(path) == 0 {
+			break
+		}
+		if rv.Type().Key().Kind() != reflect.String {
+			return false
+		}
+		el := rv.MapIndex(reflect.ValueOf(path[0]).Convert(rv.Type().Key()))
+		if !el.IsValid() {
+			return false
+		}
+		return lookup(el.Interface(), path[1:], fn)
+	}
+	if len(path) > 0 {
+		return false
+	}
+	return fn(rv.Interface())
+}
+
+// str returns a value as a string, if it is one
+func str(v interface{}) (string, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.String {
+		return "", false
+	}
+	return rv.String(), true
+}
+
+// stringTest turns a test of strings into a test of values, failing for any
+// value which is not a string
+func stringTest(test func(s string) bool) func(v interface{}) bool {
+	return func(v interface{}) bool {
+		s, ok := str(v)
+		return ok && test(s)
+	}
+}
+
+func equal(l literal) func(v interface{}) bool {
+	return func(v interface{}) bool {
+		return equals(v, l)
+	}
+}
+
+// order tests values against a literal with one of <, <=, > and >=
+func order(op string, l literal) func(v interface{}) bool {
+	return func(v interface{}) bool {
+		c, ok := compare(v, l)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		}
+		return c >= 0
+	}
+}
+
+func prefix(value string) func(v interface{}) bool {
+	value = strings.ToLower(value)
+	return stringTest(func(s string) bool {
+		return strings.HasPrefix(strings.ToLower(s), value)
+	})
+}
+
+func suffix(value string) func(v interface{}) bool {
+	value = strings.ToLower(value)
+	return stringTest(func(s string) bool {
+		return strings.HasSuffix(strings.ToLower(s), value)
+	})
+}
+
+func contains(value string) func(v interface{}) bool {
+	value = strings.ToLower(value)
+	return stringTest(func(s string) bool {
+		return strings.Contains(strings.ToLower(s), value)
+	})
+}
+
+func matches(re *regexp.Regexp) func(v interface{}) bool {
+	return stringTest(re.MatchString)
+}
+
+func in(values []literal) func(v interface{}) bool {
+	return func(v interface{}) bool {
+		for _, l := range values {
+			if equals(v, l) {
+				return true
+			}
+		}
+		return false
+	}
+}
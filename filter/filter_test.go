@@ -0,0 +1,224 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nest builds an item holding a value at a path
+func nest(keys []string, value interface{}) map[string]interface{} {
+	item := map[string]interface{}{keys[len(keys)-1]: value}
+	for idx := len(keys) - 2; idx >= 0; idx-- {
+		item = map[string]interface{}{keys[idx]: item}
+	}
+	return item
+}
+
+var filterTests = []struct {
+	input string
+	keys  []string
+	value string
+}{
+	{"(foo.bar:baz)", []string{"foo", "bar"}, "baz"},
+	{"(foo:baz)", []string{"foo"}, "baz"},
+	{"(egg.bacon.ham:breakfast)", []string{"egg", "bacon", "ham"}, "breakfast"},
+	{"(breakfast:bacon.ham)", []string{"breakfast"}, "bacon.ham"},
+	{"foo.bar:bib", []string{"foo", "bar"}, "bib"},
+	{"(foo:bar:baz)", []string{"foo"}, "bar:baz"},
+	{"foo.bar = baz", []string{"foo", "bar"}, "baz"},
+	{`Tags."aws:cloudformation:stack-name":"web stack"`, []string{"Tags", "aws:cloudformation:stack-name"}, "web stack"},
+}
+
+func Test_Parse(t *testing.T) {
+	for _, tt := range filterTests {
+		f, err := Parse(tt.input)
+		assert.Nil(t, err, tt.input)
+		assert.Equal(t, tt.keys, f.root.(comparison).path, tt.input)
+		assert.True(t, f.Match(nest(tt.keys, tt.value)), tt.input)
+		assert.False(t, f.Match(nest(tt.keys, tt.value+"x")), tt.input)
+	}
+}
+
+var badFilters = []struct {
+	input string
+	pos   int
+}{
+	{")(", 1},
+	{"(foo.:)", 6},
+	{"(foo.bar.baz)", 13},
+	{"", 1},
+	{"(Tags.Team:infra", 1},
+	{"Tags.Team:infra)", 16},
+	{"(Team::a)", 7},
+	{"Team:", 6},
+	{"Team:a AND", 11},
+	{"Team:a OR OR Team:b", 11},
+	{"Team:a Team:b", 8},
+	{`Team:"a`, 6},
+	{"Team in a", 9},
+	{"Team in (a b)", 12},
+	{"Team in ()", 10},
+	{"Team not a", 10},
+	{"Team ~ [", 8},
+	{"Team ^ a", 6},
+	{".Team:a", 1},
+	{"SecurityGroups.(GroupName:web", 16},
+	{"SecurityGroups.()", 17},
+	{"SecurityGroups.(GroupName:web) AND", 35},
+}
+
+func Test_Parse_Fail(t *testing.T) {
+	for _, tt := range badFilters {
+		_, err := Parse(tt.input)
+		if assert.IsType(t, &SyntaxError{}, err, "Parsing %s", tt.input) {
+			assert.Equal(t, tt.pos, err.(*SyntaxError).Pos, "Parsing %s: %s", tt.input, err)
+		}
+	}
+}
+
+func Test_SyntaxError(t *testing.T) {
+	_, err := Parse("(Tags.Team:infra")
+	assert.EqualError(t, err, "syntax error at position 1: unclosed parenthesis")
+
+	_, err = Parse("Tags.Team infra")
+	assert.EqualError(t, err, `syntax error at position 11: expected an operator after "Tags.Team", got "infra"`)
+}
+
+func Test_Match_List(t *testing.T) {
+	f, _ := Parse("(foo.bar:bingo)")
+
+	assert.True(t, f.Match(map[string]interface{}{
+		"foo": []map[string]interface{}{
+			{"bar": "baz"},
+			{"bar": "bingo"},
+		},
+	}))
+}
+
+func Test_Match_NestedList(t *testing.T) {
+	var tags []interface{}
+	tags = append(tags, map[string]interface{}{
+		"Key":   "Team",
+		"Value": "TestTeam",
+		"Team":  "TestTeam",
+	})
+	f, _ := Parse("(foo.tags.Team:TestTeam)")
+
+	assert.True(t, f.Match(map[string]interface{}{
+		"foo": []map[string]interface{}{
+			{"bar": "baz"},
+			{"bar": "bingo"},
+			{"tags": tags},
+		},
+	}))
+}
+
+func Test_Match_Pointers(t *testing.T) {
+	id := "i-0"
+	group := "sg-0"
+	f, _ := Parse("InstanceId:i-0 AND SecurityGroups.GroupId:sg-0")
+
+	assert.True(t, f.Match(map[string]interface{}{
+		"InstanceId":     &id,
+		"SecurityGroups": []interface{}{map[string]interface{}{"GroupId": &group}},
+	}))
+	assert.False(t, f.Match(map[string]interface{}{
+		"InstanceId":     (*string)(nil),
+		"SecurityGroups": []interface{}{map[string]interface{}{"GroupId": &group}},
+	}))
+
+	f, _ = Parse("Tags.Team:i-0")
+	assert.True(t, f.Match(map[string]interface{}{
+		"Tags": map[string]*string{"Team": &id},
+	}))
+}
+
+func Test_Match_NotString(t *testing.T) {
+	f, _ := Parse("(State.Code:running)")
+
+	assert.False(t, f.Match(map[string]interface{}{
+		"State": map[string]interface{}{"Code": 16.0},
+	}))
+	assert.False(t, f.Match(map[string]interface{}{
+		"State": []interface{}{"running"},
+	}))
+	assert.False(t, f.Match(map[string]interface{}{
+		"State": map[int]interface{}{1: "running"},
+	}))
+	assert.False(t, f.Match(map[string]interface{}{
+		"State": nil,
+	}))
+}
+
+var instance = map[string]interface{}{
+	"InstanceId":   "i-0123",
+	"InstanceType": "m4.large",
+	"KeyName":      "",
+	"State":        map[string]interface{}{"Name": "running"},
+	"Tags": map[string]interface{}{
+		"Team": "Infra",
+		"Name": "web-12.example.com",
+	},
+	"SecurityGroups": []interface{}{
+		map[string]interface{}{"GroupName": "web", "GroupId": "sg-1"},
+		map[string]interface{}{"GroupName": "ssh", "GroupId": "sg-2"},
+	},
+}
+
+var matchTests = []struct {
+	input string
+	match bool
+}{
+	{"Tags.Team:infra", true},
+	{"Tags.Team=INFRA", true},
+	{"Tags.Team!=infra", false},
+	{"Tags.Team!=web", true},
+	{"Tags.Owner!=web", true},
+	{"Tags.Name^=WEB-", true},
+	{"Tags.Name^=db-", false},
+	{"Tags.Name$=.example.com", true},
+	{"Tags.Name$=.example.org", false},
+	{"Tags.Name*=12.example", true},
+	{"Tags.Name*=13", false},
+	{`Tags.Name~"^web-\d+\."`, true},
+	{`Tags.Name~^WEB`, false},
+	{`Tags.Name~"(?i)^WEB"`, true},
+	{`Tags.Name!~^db`, true},
+	{"Tags.Team exists", true},
+	{"Tags.Owner exists", false},
+	{"KeyName EXISTS", true},
+	{"Tags.Owner missing", true},
+	{"Tags missing", false},
+	{"InstanceType in (t2.micro, m4.large)", true},
+	{"InstanceType IN ('t2.micro')", false},
+	{"InstanceType not in (t2.micro, m4.xlarge)", true},
+	{"SecurityGroups.GroupName:ssh", true},
+	{"SecurityGroups.GroupName!=ssh", false},
+	{"SecurityGroups.GroupName:web AND SecurityGroups.GroupId:sg-2", true},
+	{"SecurityGroups.(GroupName:web AND GroupId:sg-2)", false},
+	{"SecurityGroups.(GroupName:web AND GroupId:sg-1)", true},
+	{"SecurityGroups.(GroupName:db OR GroupId:sg-2)", true},
+	{"NOT SecurityGroups.(GroupName:db)", true},
+	{"State.(Name:running)", true},
+	{"Tags.Team.(Name:infra)", false},
+	{"Tags.Team:infra AND State.Name:running", true},
+	{"Tags.Team:infra && State.Name:stopped", false},
+	{"Tags.Team:web OR State.Name:running", true},
+	{"Tags.Team:web || State.Name:stopped", false},
+	{"NOT Tags.Team:web", true},
+	{"!(Tags.Team:infra)", false},
+	{"not not Tags.Team:infra", true},
+	{"Tags.Team:web OR Tags.Team:infra AND State.Name:stopped", false},
+	{"(Tags.Team:web OR Tags.Team:infra) AND State.Name:running", true},
+	{"Tags.Team:infra and (State.Name:stopped or not InstanceId^=i-1)", true},
+}
+
+func Test_Match(t *testing.T) {
+	for _, tt := range matchTests {
+		f, err := Parse(tt.input)
+		if assert.Nil(t, err, tt.input) {
+			assert.Equal(t, tt.match, f.Match(instance), tt.input)
+		}
+	}
+}
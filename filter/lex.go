@@ -0,0 +1,193 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokComma
+	tokAnd
+	tokOr
+	tokNot
+	tokOp
+	tokPath
+	tokValue
+)
+
+// A token is a single lexical element of a filter expression. Pos is the byte
+// offset of its first character.
+type token struct {
+	kind tokenKind
+	pos  int
+	text string
+	// path holds the segments of a tokPath
+	path []string
+	// quoted is set if the token, or any segment of it, was quoted
+	quoted bool
+	// group is set if the path is followed by a group, as in
+	// Ingress.(FromPort<=22 AND ToPort>=22)
+	group bool
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of filter"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators lists the comparison operators, longest first so that the lexer
+// picks "!=" over "!"
+var operators = []string{"!=", "!~", "^=", "$=", "*=", "<=", ">=", ":", "=", "~", "<", ">"}
+
+// A lexer splits a filter expression into tokens. Paths and values are lexed
+// differently, so the parser asks for a value whenever it expects one: a
+// value runs up to the next whitespace or parenthesis, and may hold
+// characters which are operators elsewhere, such as (Name:web:1).
+type lexer struct {
+	input string
+	pos   int
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.input) && isSpace(l.input[l.pos]) {
+		l.pos++
+	}
+}
+
+// next returns the next token in between values
+func (l *lexer) next() (token, error) {
+	l.skipSpace()
+	start := l.pos
+	if start == len(l.input) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	rest := l.input[start:]
+	switch {
+	case rest[0] == '(':
+		return l.emit(tokLParen, 1), nil
+	case rest[0] == ')':
+		return l.emit(tokRParen, 1), nil
+	case rest[0] == ',':
+		return l.emit(tokComma, 1), nil
+	case strings.HasPrefix(rest, "&&"):
+		return l.emit(tokAnd, 2), nil
+	case strings.HasPrefix(rest, "||"):
+		return l.emit(tokOr, 2), nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			return l.emit(tokOp, len(op)), nil
+		}
+	}
+	if rest[0] == '!' {
+		return l.emit(tokNot, 1), nil
+	}
+	if isQuote(rest[0]) || isPathChar(rest[0]) {
+		return l.path()
+	}
+	return token{}, &SyntaxError{Pos: start + 1, Msg: fmt.Sprintf("unexpected character %q", rest[0])}
+}
+
+// value returns the value following an operator
+func (l *lexer) value() (token, error) {
+	l.skipSpace()
+	start := l.pos
+	if start == len(l.input) || strings.IndexByte("(),:=!~<>&|", l.input[start]) >= 0 {
+		return token{}, &SyntaxError{Pos: start + 1, Msg: "expected a value"}
+	}
+	if isQuote(l.input[start]) {
+		s, err := l.quoted()
+		if err != nil {
+			return token{}, err
+		}
+		return token{kind: tokValue, pos: start, text: s, quoted: true}, nil
+	}
+	for l.pos < len(l.input) && !isSpace(l.input[l.pos]) && strings.IndexByte("(),", l.input[l.pos]) < 0 {
+		l.pos++
+	}
+	return token{kind: tokValue, pos: start, text: l.input[start:l.pos]}, nil
+}
+
+// path lexes a dot separated path. Segments holding whitespace, dots or
+// operator characters must be quoted, as in Tags."aws:cloudformation:stack-name".
+// A path followed by a group, as in Ingress.(FromPort<=22), ends at the dot.
+func (l *lexer) path() (token, error) {
+	t := token{kind: tokPath, pos: l.pos}
+	for {
+		start := l.pos
+		if start < len(l.input) && isQuote(l.input[start]) {
+			s, err := l.quoted()
+			if err != nil {
+				return token{}, err
+			}
+			t.path = append(t.path, s)
+			t.quoted = true
+		} else {
+			for l.pos < len(l.input) && isPathChar(l.input[l.pos]) {
+				l.pos++
+			}
+			if l.pos == start {
+				return token{}, &SyntaxError{Pos: start + 1, Msg: "empty path segment"}
+			}
+			t.path = append(t.path, l.input[start:l.pos])
+		}
+		if l.pos == len(l.input) || l.input[l.pos] != '.' {
+			break
+		}
+		l.pos++
+		if l.pos < len(l.input) && l.input[l.pos] == '(' {
+			t.group = true
+			break
+		}
+	}
+	t.text = l.input[t.pos:l.pos]
+	return t, nil
+}
+
+// quoted lexes a string in single or double quotes, in which a backslash
+// escapes the quote or another backslash. Other backslashes are kept, so that
+// regular expressions such as "^web-\d+$" need no double escaping.
+func (l *lexer) quoted() (string, error) {
+	start := l.pos
+	quote := l.input[start]
+	var s []byte
+	for l.pos++; l.pos < len(l.input); l.pos++ {
+		c := l.input[l.pos]
+		switch {
+		case c == '\\' && l.pos+1 < len(l.input) && (l.input[l.pos+1] == quote || l.input[l.pos+1] == '\\'):
+			l.pos++
+			s = append(s, l.input[l.pos])
+		case c == quote:
+			l.pos++
+			return string(s), nil
+		default:
+			s = append(s, c)
+		}
+	}
+	return "", &SyntaxError{Pos: start + 1, Msg: "unterminated string"}
+}
+
+func (l *lexer) emit(kind tokenKind, n int) token {
+	t := token{kind: kind, pos: l.pos, text: l.input[l.pos : l.pos+n]}
+	l.pos += n
+	return t
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isQuote(c byte) bool {
+	return c == '"' || c == '\''
+}
+
+func isPathChar(c byte) bool {
+	return !isSpace(c) && !isQuote(c) && strings.IndexByte(".(),:=!~^$*<>&|", c) < 0
+}
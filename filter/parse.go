@@ -0,0 +1,222 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A SyntaxError reports a malformed filter expression, at the position of the
+// offending character counting from 1
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// A parser builds the tree of a filter expression by recursive descent:
+//
+//	expr       = and { ("OR" | "||") and }
+//	and        = unary { ("AND" | "&&") unary }
+//	unary      = ("NOT" | "!") unary | "(" expr ")" | comparison
+//	comparison = path op value
+//	           | path ("EXISTS" | "MISSING")
+//	           | path ["NOT"] "IN" "(" value { "," value } ")"
+//	           | path "." "(" expr ")"
+//
+// Keywords are case-insensitive. Errors are raised by panicking with a
+// *SyntaxError, recovered by Parse.
+type parser struct {
+	lex lexer
+	tok token
+}
+
+// advance moves on to the next token
+func (p *parser) advance() {
+	t, err := p.lex.next()
+	if err != nil {
+		panic(err)
+	}
+	p.tok = t
+}
+
+// value reads the value following the current token
+func (p *parser) value() token {
+	t, err := p.lex.value()
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) {
+	panic(&SyntaxError{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) unexpected() {
+	p.errorf(p.tok.pos, "unexpected %s", p.tok)
+}
+
+// keyword checks whether the current token is the given keyword
+func (p *parser) keyword(kw string) bool {
+	return p.tok.kind == tokPath && !p.tok.quoted && len(p.tok.path) == 1 && strings.EqualFold(p.tok.text, kw)
+}
+
+func (p *parser) parse() node {
+	p.advance()
+	if p.tok.kind == tokEOF {
+		p.errorf(p.tok.pos, "empty filter")
+	}
+	n := p.parseOr()
+	if p.tok.kind != tokEOF {
+		p.unexpected()
+	}
+	return n
+}
+
+func (p *parser) parseOr() node {
+	n := p.parseAnd()
+	for p.tok.kind == tokOr || p.keyword("or") {
+		p.advance()
+		n = or{n, p.parseAnd()}
+	}
+	return n
+}
+
+func (p *parser) parseAnd() node {
+	n := p.parseUnary()
+	for p.tok.kind == tokAnd || p.keyword("and") {
+		p.advance()
+		n = and{n, p.parseUnary()}
+	}
+	return n
+}
+
+func (p *parser) parseUnary() node {
+	switch {
+	case p.tok.kind == tokNot || p.keyword("not"):
+		p.advance()
+		return not{p.parseUnary()}
+	case p.tok.kind == tokLParen:
+		open := p.tok.pos
+		p.advance()
+		n := p.parseOr()
+		if p.tok.kind == tokEOF {
+			p.errorf(open, "unclosed parenthesis")
+		}
+		if p.tok.kind != tokRParen {
+			p.unexpected()
+		}
+		p.advance()
+		return n
+	case p.tok.kind == tokPath && !p.keyword("and") && !p.keyword("or"):
+		return p.parseComparison()
+	}
+	p.unexpected()
+	return nil
+}
+
+func (p *parser) parseComparison() node {
+	path := p.tok
+	p.advance()
+	if path.group {
+		return p.parseGroup(path)
+	}
+	switch {
+	case p.tok.kind == tokOp:
+		op := p.tok
+		v := p.value()
+		p.advance()
+		return p.compare(path, op, v)
+	case p.keyword("exists"):
+		p.advance()
+		return exists{path.path}
+	case p.keyword("missing"):
+		p.advance()
+		return not{exists{path.path}}
+	case p.keyword("in"):
+		return p.parseIn(path)
+	case p.keyword("not"):
+		p.advance()
+		if !p.keyword("in") {
+			p.errorf(p.tok.pos, "expected IN after NOT, got %s", p.tok)
+		}
+		return not{p.parseIn(path)}
+	}
+	p.errorf(p.tok.pos, "expected an operator after %s, got %s", path, p.tok)
+	return nil
+}
+
+// parseGroup parses the expression grouped under a path, matched against each
+// value at the path on its own
+func (p *parser) parseGroup(path token) node {
+	open := p.tok.pos
+	p.advance()
+	n := p.parseOr()
+	if p.tok.kind == tokEOF {
+		p.errorf(open, "unclosed parenthesis")
+	}
+	if p.tok.kind != tokRParen {
+		p.unexpected()
+	}
+	p.advance()
+	return group{path.path, n}
+}
+
+// parseIn parses the list of values following IN
+func (p *parser) parseIn(path token) node {
+	p.advance()
+	if p.tok.kind != tokLParen {
+		p.errorf(p.tok.pos, "expected \"(\" after IN, got %s", p.tok)
+	}
+	var values []literal
+	for {
+		values = append(values, p.literal(p.value()))
+		p.advance()
+		if p.tok.kind == tokRParen {
+			p.advance()
+			return comparison{path.path, in(values)}
+		}
+		if p.tok.kind != tokComma {
+			p.errorf(p.tok.pos, "expected \",\" or \")\", got %s", p.tok)
+		}
+	}
+}
+
+// literal reads a value as a literal
+func (p *parser) literal(v token) literal {
+	l, err := parseLiteral(v.text)
+	if err != nil {
+		p.errorf(v.pos, "%s", err)
+	}
+	return l
+}
+
+// compare builds the comparison of the values at a path with an operator
+func (p *parser) compare(path, op, v token) node {
+	switch op.text {
+	case ":", "=":
+		return comparison{path.path, equal(p.literal(v))}
+	case "!=":
+		return not{comparison{path.path, equal(p.literal(v))}}
+	case "<", "<=", ">", ">=":
+		return comparison{path.path, order(op.text, p.literal(v))}
+	case "^=":
+		return comparison{path.path, prefix(v.text)}
+	case "$=":
+		return comparison{path.path, suffix(v.text)}
+	case "*=":
+		return comparison{path.path, contains(v.text)}
+	}
+	re, err := regexp.Compile(v.text)
+	if err != nil {
+		p.errorf(v.pos, "invalid regular expression: %s", err)
+	}
+	if op.text == "!~" {
+		return not{comparison{path.path, matches(re)}}
+	}
+	return comparison{path.path, matches(re)}
+}
@@ -0,0 +1,216 @@
+package filter
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now is the time relative times are resolved against, replaced in tests
+var now = time.Now
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// A literal is a value of a filter expression, read as every type it can be,
+// so that it can be compared to values of any type
+type literal struct {
+	text   string
+	num    float64
+	isNum  bool
+	b      bool
+	isBool bool
+	t      time.Time
+	isTime bool
+	// rel is set for times relative to now, such as now-30d
+	rel *time.Duration
+}
+
+// parseLiteral reads a value as a number, a boolean and a point in time, as
+// far as it is one. Only relative times can be malformed, as anything else is
+// at least a string.
+func parseLiteral(text string) (literal, error) {
+	l := literal{text: text}
+	if f, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		l.num, l.isNum = f, true
+	}
+	if b, err := strconv.ParseBool(text); err == nil && !l.isNum {
+		l.b, l.isBool = b, true
+	}
+	lower := strings.ToLower(text)
+	switch {
+	case lower == "now":
+		l.rel, l.isTime = new(time.Duration), true
+	case strings.HasPrefix(lower, "now-"), strings.HasPrefix(lower, "now+"):
+		d, err := parseDuration(lower[4:])
+		if err != nil {
+			return l, err
+		}
+		if lower[3] == '-' {
+			d = -d
+		}
+		l.rel, l.isTime = &d, true
+	default:
+		l.t, l.isTime = parseTime(text)
+	}
+	return l, nil
+}
+
+// time returns the point in time of a literal, resolving relative times
+func (l literal) time() time.Time {
+	if l.rel != nil {
+		return now().Add(*l.rel)
+	}
+	return l.t
+}
+
+// parseTime reads a point in time as RFC 3339, or as a date in UTC
+func parseTime(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// parseDuration reads a duration like time.ParseDuration does, also accepting
+// days (d) and weeks (w), as in 30d or 1w2d12h
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, errors.New("missing duration")
+	}
+	var d time.Duration
+	for s != "" {
+		n := 0
+		for n < len(s) && (s[n] == '.' || s[n] >= '0' && s[n] <= '9') {
+			n++
+		}
+		u := n
+		for u < len(s) && s[u] != '.' && (s[u] < '0' || s[u] > '9') {
+			u++
+		}
+		if n == 0 || u == n {
+			return 0, errors.New("invalid duration " + strconv.Quote(s))
+		}
+		var unit time.Duration
+		switch s[n:u] {
+		case "d":
+			unit = 24 * time.Hour
+		case "w":
+			unit = 7 * 24 * time.Hour
+		default:
+			part, err := time.ParseDuration(s[:u])
+			if err != nil {
+				return 0, errors.New("invalid duration " + strconv.Quote(s))
+			}
+			d += part
+			s = s[u:]
+			continue
+		}
+		f, err := strconv.ParseFloat(s[:n], 64)
+		if err != nil {
+			return 0, errors.New("invalid duration " + strconv.Quote(s))
+		}
+		d += time.Duration(f * float64(unit))
+		s = s[u:]
+	}
+	return d, nil
+}
+
+// scalar returns a value found at a path as a string, float64, bool or
+// time.Time, or nil if it is neither
+func scalar(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Struct:
+		if rv.Type() == timeType {
+			return rv.Interface()
+		}
+	}
+	return nil
+}
+
+// equals compares a value to a literal. Strings are also compared as points
+// in time, as that is how times are stored once recorded in the history.
+func equals(v interface{}, l literal) bool {
+	switch x := scalar(v).(type) {
+	case string:
+		if strings.EqualFold(x, l.text) {
+			return true
+		}
+		if l.isTime {
+			t, ok := parseTime(x)
+			return ok && t.Equal(l.time())
+		}
+	case float64:
+		return l.isNum && x == l.num
+	case bool:
+		return l.isBool && x == l.b
+	case time.Time:
+		return l.isTime && x.Equal(l.time())
+	}
+	return false
+}
+
+// compare orders a value and a literal, returning false if they cannot be
+// ordered. Strings holding a point in time or a number are ordered as such if
+// the literal is one too, and alphabetically otherwise.
+func compare(v interface{}, l literal) (int, bool) {
+	switch x := scalar(v).(type) {
+	case string:
+		if l.isTime {
+			if t, ok := parseTime(x); ok {
+				return compareTimes(t, l.time()), true
+			}
+		}
+		if l.isNum {
+			if f, err := strconv.ParseFloat(x, 64); err == nil {
+				return compareNumbers(f, l.num), true
+			}
+		}
+		return strings.Compare(strings.ToLower(x), strings.ToLower(l.text)), true
+	case float64:
+		if l.isNum {
+			return compareNumbers(x, l.num), true
+		}
+	case time.Time:
+		if l.isTime {
+			return compareTimes(x, l.time()), true
+		}
+	}
+	return 0, false
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
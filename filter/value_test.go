@@ -0,0 +1,130 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseDuration(t *testing.T) {
+	for in, expected := range map[string]time.Duration{
+		"30d":     30 * 24 * time.Hour,
+		"1w2d12h": 9*24*time.Hour + 12*time.Hour,
+		"1.5d":    36 * time.Hour,
+		"90m":     90 * time.Minute,
+		"1h30m5s": time.Hour + 30*time.Minute + 5*time.Second,
+	} {
+		d, err := parseDuration(in)
+		assert.Nil(t, err, in)
+		assert.Equal(t, expected, d, in)
+	}
+	for _, in := range []string{"", "30", "d", "30x", "1..5d", "3d-1h"} {
+		_, err := parseDuration(in)
+		assert.NotNil(t, err, in)
+	}
+}
+
+func Test_parseLiteral(t *testing.T) {
+	l, _ := parseLiteral("16")
+	assert.True(t, l.isNum)
+	assert.False(t, l.isBool)
+	assert.Equal(t, 16.0, l.num)
+
+	l, _ = parseLiteral("TRUE")
+	assert.True(t, l.isBool)
+	assert.True(t, l.b)
+
+	l, _ = parseLiteral("2017-03-01")
+	assert.True(t, l.isTime)
+	assert.Equal(t, time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC), l.time())
+
+	l, _ = parseLiteral("NaN")
+	assert.False(t, l.isNum)
+
+	_, err := parseLiteral("now-30x")
+	assert.NotNil(t, err)
+}
+
+// launched is an instance as crawled, launched a year before t0, and as read
+// back from the history
+var (
+	t0       = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	launched = t0.AddDate(-1, 0, 0)
+	index    = int64(0)
+	ebs      = true
+	live     = map[string]interface{}{
+		"AmiLaunchIndex": &index,
+		"EbsOptimized":   &ebs,
+		"LaunchTime":     &launched,
+		"State":          map[string]interface{}{"Code": int64(16), "Name": "running"},
+		"Tags":           map[string]interface{}{"Port": "8080"},
+		"Weird":          []interface{}{struct{}{}, func() {}, make(chan int), map[bool]bool{true: true}},
+	}
+	recorded = map[string]interface{}{
+		"AmiLaunchIndex": 0.0,
+		"EbsOptimized":   true,
+		"LaunchTime":     "2016-03-01T12:00:00Z",
+		"State":          map[string]interface{}{"Code": 16.0, "Name": "running"},
+		"Tags":           map[string]interface{}{"Port": "8080"},
+		"Weird":          nil,
+	}
+)
+
+var typedTests = []struct {
+	input string
+	match bool
+}{
+	{"State.Code:16", true},
+	{"State.Code=16.0", true},
+	{"State.Code!=16", false},
+	{"State.Code in (0, 16)", true},
+	{"State.Code>15", true},
+	{"State.Code>=16", true},
+	{"State.Code<16", false},
+	{"State.Code<=16", true},
+	{"State.Code<running", false},
+	{"State.Name>r", true},
+	{"State.Name<=RUNNING", true},
+	{"AmiLaunchIndex:0", true},
+	{"AmiLaunchIndex<1", true},
+	{"EbsOptimized:true", true},
+	{"EbsOptimized=FALSE", false},
+	{"EbsOptimized!=false", true},
+	{"EbsOptimized>false", false},
+	{"Tags.Port>1000", true},
+	{"Tags.Port<900", false},
+	{"LaunchTime<now-90d", true},
+	{"LaunchTime<now-2w AND LaunchTime>now-53w", true},
+	{"LaunchTime>=now-30d", false},
+	{"LaunchTime<2016-03-02", true},
+	{"LaunchTime>2016-03-01T12:00:00Z", false},
+	{"LaunchTime=2016-03-01T13:00:00+01:00", true},
+	{"LaunchTime<now", true},
+	{"LaunchTime<16", false},
+	{"Weird:x", false},
+	{"Weird<now", false},
+	{"Weird>0", false},
+	{"Weird exists", true},
+}
+
+func Test_Match_Typed(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return t0 }
+
+	for _, tt := range typedTests {
+		f, err := Parse(tt.input)
+		if !assert.Nil(t, err, tt.input) {
+			continue
+		}
+		assert.Equal(t, tt.match, f.Match(live), "live: %s", tt.input)
+		if tt.input != "Weird exists" {
+			assert.Equal(t, tt.match, f.Match(recorded), "recorded: %s", tt.input)
+		}
+	}
+}
+
+func Test_Parse_RelativeTime(t *testing.T) {
+	_, err := Parse("LaunchTime < now-30x")
+	assert.EqualError(t, err, `syntax error at position 14: invalid duration "30x"`)
+}
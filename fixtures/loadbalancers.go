@@ -0,0 +1,168 @@
+package fixtures
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+const arnPrefix = "arn:aws:elasticloadbalancing:eu-west-1:123456789012:"
+
+// LoadBalancerArn returns the ARN of the application load balancer lb-N
+func LoadBalancerArn(i int) string {
+	return fmt.Sprintf("%sloadbalancer/app/lb-%d/50dc6c495c0c918%d", arnPrefix, i, i)
+}
+
+// TargetGroupArn returns the ARN of the target group tg-N
+func TargetGroupArn(i int) string {
+	return fmt.Sprintf("%stargetgroup/tg-%d/73e2d6bc24d8a06%d", arnPrefix, i, i)
+}
+
+// ClassicLoadBalancers returns `count` number of classic load balancers,
+// elb-N balancing HTTP over i-N and i-N+1
+func ClassicLoadBalancers(count int) []*elb.LoadBalancerDescription {
+	var data []*elb.LoadBalancerDescription
+	for i := 0; i < count; i++ {
+		data = append(data, &elb.LoadBalancerDescription{
+			AvailabilityZones: aws.StringSlice([]string{"eu-west-1a", "eu-west-1b"}),
+			CreatedTime:       aws.Time(t),
+			DNSName:           aws.String(fmt.Sprintf("elb-%d-1234567890.eu-west-1.elb.amazonaws.com", i)),
+			HealthCheck: &elb.HealthCheck{
+				HealthyThreshold:   aws.Int64(2),
+				Interval:           aws.Int64(30),
+				Target:             aws.String("HTTP:8080/health"),
+				Timeout:            aws.Int64(5),
+				UnhealthyThreshold: aws.Int64(2),
+			},
+			Instances: []*elb.Instance{
+				{InstanceId: aws.String(fmt.Sprintf("i-%d", i))},
+				{InstanceId: aws.String(fmt.Sprintf("i-%d", i+1))},
+			},
+			ListenerDescriptions: []*elb.ListenerDescription{
+				{Listener: &elb.Listener{
+					InstancePort:     aws.Int64(8080),
+					InstanceProtocol: aws.String("HTTP"),
+					LoadBalancerPort: aws.Int64(80),
+					Protocol:         aws.String("HTTP"),
+				}},
+			},
+			LoadBalancerName: aws.String(fmt.Sprintf("elb-%d", i)),
+			Scheme:           aws.String("internet-facing"),
+			SecurityGroups:   aws.StringSlice([]string{fmt.Sprintf("sg-%d", i)}),
+			Subnets:          aws.StringSlice([]string{"subnet-0", "subnet-1"}),
+			VPCId:            aws.String("vpc-0"),
+		})
+	}
+	return data
+}
+
+// InstanceHealth returns the health of the instances of the classic load
+// balancer elb-N: i-N is in service and i-N+1 out of service
+func InstanceHealth(name string) []*elb.InstanceState {
+	var i int
+	fmt.Sscanf(name, "elb-%d", &i)
+	return []*elb.InstanceState{
+		{
+			Description: aws.String("N/A"),
+			InstanceId:  aws.String(fmt.Sprintf("i-%d", i)),
+			ReasonCode:  aws.String("N/A"),
+			State:       aws.String("InService"),
+		},
+		{
+			Description: aws.String("Instance has failed at least the UnhealthyThreshold number of health checks consecutively."),
+			InstanceId:  aws.String(fmt.Sprintf("i-%d", i+1)),
+			ReasonCode:  aws.String("Instance"),
+			State:       aws.String("OutOfService"),
+		},
+	}
+}
+
+// LoadBalancers returns `count` number of application load balancers, lb-N
+func LoadBalancers(count int) []*elbv2.LoadBalancer {
+	var data []*elbv2.LoadBalancer
+	for i := 0; i < count; i++ {
+		data = append(data, &elbv2.LoadBalancer{
+			AvailabilityZones: []*elbv2.AvailabilityZone{
+				{SubnetId: aws.String("subnet-0"), ZoneName: aws.String("eu-west-1a")},
+				{SubnetId: aws.String("subnet-1"), ZoneName: aws.String("eu-west-1b")},
+			},
+			CreatedTime:      aws.Time(t),
+			DNSName:          aws.String(fmt.Sprintf("lb-%d-1234567890.eu-west-1.elb.amazonaws.com", i)),
+			IpAddressType:    aws.String("ipv4"),
+			LoadBalancerArn:  aws.String(LoadBalancerArn(i)),
+			LoadBalancerName: aws.String(fmt.Sprintf("lb-%d", i)),
+			Scheme:           aws.String("internet-facing"),
+			SecurityGroups:   aws.StringSlice([]string{fmt.Sprintf("sg-%d", i)}),
+			State:            &elbv2.LoadBalancerState{Code: aws.String("active")},
+			Type:             aws.String("application"),
+			VpcId:            aws.String("vpc-0"),
+		})
+	}
+	return data
+}
+
+// Listeners returns the listeners of a load balancer: HTTPS on port 443,
+// forwarding to the target group of the same number
+func Listeners(lbArn string) []*elbv2.Listener {
+	var i int
+	fmt.Sscanf(lbArn, arnPrefix+"loadbalancer/app/lb-%d/", &i)
+	return []*elbv2.Listener{
+		{
+			Certificates: []*elbv2.Certificate{
+				{CertificateArn: aws.String("arn:aws:acm:eu-west-1:123456789012:certificate/12345678")},
+			},
+			DefaultActions: []*elbv2.Action{
+				{TargetGroupArn: aws.String(TargetGroupArn(i)), Type: aws.String("forward")},
+			},
+			ListenerArn:     aws.String(fmt.Sprintf("%slistener/app/lb-%d/50dc6c495c0c918%d/f2f7dc8efc522ab%d", arnPrefix, i, i, i)),
+			LoadBalancerArn: aws.String(lbArn),
+			Port:            aws.Int64(443),
+			Protocol:        aws.String("HTTPS"),
+			SslPolicy:       aws.String("ELBSecurityPolicy-2016-08"),
+		},
+	}
+}
+
+// TargetGroups returns `count` number of target groups, tg-N belonging to
+// lb-N
+func TargetGroups(count int) []*elbv2.TargetGroup {
+	var data []*elbv2.TargetGroup
+	for i := 0; i < count; i++ {
+		data = append(data, &elbv2.TargetGroup{
+			HealthCheckIntervalSeconds: aws.Int64(30),
+			HealthCheckPath:            aws.String("/health"),
+			HealthCheckPort:            aws.String("traffic-port"),
+			HealthCheckProtocol:        aws.String("HTTP"),
+			HealthCheckTimeoutSeconds:  aws.Int64(5),
+			HealthyThresholdCount:      aws.Int64(5),
+			LoadBalancerArns:           aws.StringSlice([]string{LoadBalancerArn(i)}),
+			Matcher:                    &elbv2.Matcher{HttpCode: aws.String("200")},
+			Port:                       aws.Int64(8080),
+			Protocol:                   aws.String("HTTP"),
+			TargetGroupArn:             aws.String(TargetGroupArn(i)),
+			TargetGroupName:            aws.String(fmt.Sprintf("tg-%d", i)),
+			TargetType:                 aws.String("instance"),
+			UnhealthyThresholdCount:    aws.Int64(2),
+			VpcId:                      aws.String("vpc-0"),
+		})
+	}
+	return data
+}
+
+// TargetHealth returns the health of the targets of a target group: i-N is
+// healthy, i-N+1 unhealthy and i-N+2 draining
+func TargetHealth(tgArn string) []*elbv2.TargetHealthDescription {
+	var i int
+	fmt.Sscanf(tgArn, arnPrefix+"targetgroup/tg-%d/", &i)
+	var data []*elbv2.TargetHealthDescription
+	for n, state := range []string{"healthy", "unhealthy", "draining"} {
+		data = append(data, &elbv2.TargetHealthDescription{
+			HealthCheckPort: aws.String("8080"),
+			Target:          &elbv2.TargetDescription{Id: aws.String(fmt.Sprintf("i-%d", i+n)), Port: aws.Int64(8080)},
+			TargetHealth:    &elbv2.TargetHealth{State: aws.String(state)},
+		})
+	}
+	return data
+}
@@ -0,0 +1,163 @@
+package fixtures
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func ec2Tags(name string) []*ec2.Tag {
+	return []*ec2.Tag{
+		{Key: aws.String("Name"), Value: aws.String(name)},
+		{Key: aws.String("Environment"), Value: aws.String("staging")},
+	}
+}
+
+// Vpcs returns `count` number of VPCs, vpc-N holding 10.N.0.0/16
+func Vpcs(count int) []*ec2.Vpc {
+	var data []*ec2.Vpc
+	for i := 0; i < count; i++ {
+		data = append(data, &ec2.Vpc{
+			CidrBlock:       aws.String(fmt.Sprintf("10.%d.0.0/16", i)),
+			DhcpOptionsId:   aws.String("dopt-1a2b3c4d"),
+			InstanceTenancy: aws.String("default"),
+			IsDefault:       aws.Bool(i == 0),
+			State:           aws.String("available"),
+			Tags:            ec2Tags(fmt.Sprintf("vpc-%d", i)),
+			VpcId:           aws.String(fmt.Sprintf("vpc-%d", i)),
+		})
+	}
+	return data
+}
+
+// Subnets returns `count` number of subnets of vpc-0, subnet-N holding
+// 10.0.N.0/24 with N addresses in use
+func Subnets(count int) []*ec2.Subnet {
+	var data []*ec2.Subnet
+	for i := 0; i < count; i++ {
+		data = append(data, &ec2.Subnet{
+			AvailabilityZone:        aws.String("eu-west-1b"),
+			AvailableIpAddressCount: aws.Int64(int64(251 - i)),
+			CidrBlock:               aws.String(fmt.Sprintf("10.0.%d.0/24", i)),
+			DefaultForAz:            aws.Bool(false),
+			MapPublicIpOnLaunch:     aws.Bool(false),
+			State:                   aws.String("available"),
+			SubnetId:                aws.String(fmt.Sprintf("subnet-%d", i)),
+			Tags:                    ec2Tags(fmt.Sprintf("subnet-%d", i)),
+			VpcId:                   aws.String("vpc-0"),
+		})
+	}
+	return data
+}
+
+// RouteTables returns `count` number of route tables of vpc-0, rtb-N being
+// associated with subnet-N and routing to the internet through igw-0
+func RouteTables(count int) []*ec2.RouteTable {
+	var data []*ec2.RouteTable
+	for i := 0; i < count; i++ {
+		data = append(data, &ec2.RouteTable{
+			Associations: []*ec2.RouteTableAssociation{
+				{
+					Main:                    aws.Bool(false),
+					RouteTableAssociationId: aws.String(fmt.Sprintf("rtbassoc-%d", i)),
+					RouteTableId:            aws.String(fmt.Sprintf("rtb-%d", i)),
+					SubnetId:                aws.String(fmt.Sprintf("subnet-%d", i)),
+				},
+			},
+			RouteTableId: aws.String(fmt.Sprintf("rtb-%d", i)),
+			Routes: []*ec2.Route{
+				{
+					DestinationCidrBlock: aws.String("10.0.0.0/16"),
+					GatewayId:            aws.String("local"),
+					Origin:               aws.String("CreateRouteTable"),
+					State:                aws.String("active"),
+				},
+				{
+					DestinationCidrBlock: aws.String("0.0.0.0/0"),
+					GatewayId:            aws.String("igw-0"),
+					Origin:               aws.String("CreateRoute"),
+					State:                aws.String("active"),
+				},
+			},
+			Tags:  ec2Tags(fmt.Sprintf("rtb-%d", i)),
+			VpcId: aws.String("vpc-0"),
+		})
+	}
+	return data
+}
+
+// InternetGateways returns `count` number of internet gateways, igw-N being
+// attached to vpc-N
+func InternetGateways(count int) []*ec2.InternetGateway {
+	var data []*ec2.InternetGateway
+	for i := 0; i < count; i++ {
+		data = append(data, &ec2.InternetGateway{
+			Attachments: []*ec2.InternetGatewayAttachment{
+				{State: aws.String("available"), VpcId: aws.String(fmt.Sprintf("vpc-%d", i))},
+			},
+			InternetGatewayId: aws.String(fmt.Sprintf("igw-%d", i)),
+			Tags:              ec2Tags(fmt.Sprintf("igw-%d", i)),
+		})
+	}
+	return data
+}
+
+// NatGateways returns `count` number of NAT gateways of vpc-0, nat-N being in
+// subnet-N
+func NatGateways(count int) []*ec2.NatGateway {
+	var data []*ec2.NatGateway
+	for i := 0; i < count; i++ {
+		data = append(data, &ec2.NatGateway{
+			CreateTime: aws.Time(t),
+			NatGatewayAddresses: []*ec2.NatGatewayAddress{
+				{
+					AllocationId:       aws.String(fmt.Sprintf("eipalloc-%d", i)),
+					NetworkInterfaceId: aws.String(fmt.Sprintf("eni-nat-%d", i)),
+					PrivateIp:          aws.String(fmt.Sprintf("10.0.%d.10", i)),
+					PublicIp:           aws.String(fmt.Sprintf("52.0.0.%d", i)),
+				},
+			},
+			NatGatewayId: aws.String(fmt.Sprintf("nat-%d", i)),
+			State:        aws.String("available"),
+			SubnetId:     aws.String(fmt.Sprintf("subnet-%d", i)),
+			Tags:         ec2Tags(fmt.Sprintf("nat-%d", i)),
+			VpcId:        aws.String("vpc-0"),
+		})
+	}
+	return data
+}
+
+// NetworkInterfaces returns `count` number of network interfaces of vpc-0,
+// eni-N being attached to i-N
+func NetworkInterfaces(count int) []*ec2.NetworkInterface {
+	var data []*ec2.NetworkInterface
+	for i := 0; i < count; i++ {
+		data = append(data, &ec2.NetworkInterface{
+			Attachment: &ec2.NetworkInterfaceAttachment{
+				AttachTime:          aws.Time(t),
+				AttachmentId:        aws.String(fmt.Sprintf("eni-attach-%d", i)),
+				DeleteOnTermination: aws.Bool(true),
+				DeviceIndex:         aws.Int64(0),
+				InstanceId:          aws.String(fmt.Sprintf("i-%d", i)),
+				Status:              aws.String("attached"),
+			},
+			AvailabilityZone: aws.String("eu-west-1b"),
+			Description:      aws.String(""),
+			Groups: []*ec2.GroupIdentifier{
+				{GroupId: aws.String(fmt.Sprintf("sg-%d", i))},
+			},
+			InterfaceType:      aws.String("interface"),
+			MacAddress:         aws.String("02:a6:fd:3a:b7:4f"),
+			NetworkInterfaceId: aws.String(fmt.Sprintf("eni-%d", i)),
+			PrivateDnsName:     aws.String(fmt.Sprintf("ip-10-20-30-%d.eu-west-1.compute.internal", i)),
+			PrivateIpAddress:   aws.String(fmt.Sprintf("10.20.30.%d", i)),
+			SourceDestCheck:    aws.Bool(true),
+			Status:             aws.String("in-use"),
+			SubnetId:           aws.String("subnet-0"),
+			TagSet:             ec2Tags(fmt.Sprintf("eni-%d", i)),
+			VpcId:              aws.String("vpc-0"),
+		})
+	}
+	return data
+}
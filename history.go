@@ -0,0 +1,411 @@
+package melkor
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Version is the state of a resource from the crawl it was first seen in
+// until the crawl it changed or disappeared in. To is nil for the current
+// version of a resource still present.
+type Version struct {
+	From time.Time              `json:"from"`
+	To   *time.Time             `json:"to,omitempty"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// A Record holds all known versions of a single resource, oldest first
+type Record struct {
+	Scope
+	ID        string    `json:"id"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Versions  []Version `json:"versions"`
+}
+
+// current returns the version of a resource still present, if any
+func (r *Record) current() *Version {
+	if len(r.Versions) == 0 {
+		return nil
+	}
+	v := &r.Versions[len(r.Versions)-1]
+	if v.To != nil {
+		return nil
+	}
+	return v
+}
+
+// at returns the version valid at a point in time, if any
+func (r *Record) at(t time.Time) *Version {
+	for idx := range r.Versions {
+		v := &r.Versions[idx]
+		if !v.From.After(t) && (v.To == nil || v.To.After(t)) {
+			return v
+		}
+	}
+	return nil
+}
+
+// The types of Change
+const (
+	Added    = "added"
+	Modified = "modified"
+	Removed  = "removed"
+)
+
+// A Change is a single resource added, modified or removed by a crawl. Data is
+// the expanded resource, or its last known version if it was removed.
+type Change struct {
+	Scope
+	Type string
+	ID   string
+	Data map[string]interface{}
+}
+
+// A Changeset holds all changes found by a single crawl of a scope. The first
+// crawl of a scope is Initial, finding every resource to be added.
+type Changeset struct {
+	Scope
+	CrawledAt time.Time
+	Initial   bool
+	Changes   []Change
+}
+
+// A Key identifies a single resource. Ids are only unique within the account
+// and region a resource was crawled from.
+type Key struct {
+	Scope
+	ID string `json:"id"`
+}
+
+// A History records every change seen between crawls, so that the state of
+// the resources can be looked up at any point in time, Edda style. Versions
+// which ended longer than the retention period ago are forgotten.
+//
+// The zero value is an empty History keeping everything forever. A History is
+// safe for concurrent use.
+type History struct {
+	retention time.Duration
+
+	mu      sync.RWMutex
+	records map[Key]*Record
+	// crawls holds the time of every crawl per scope, oldest first
+	crawls map[Scope][]time.Time
+}
+
+// NewHistory creates a History forgetting versions which ended longer than
+// retention ago. A retention of zero keeps everything forever.
+func NewHistory(retention time.Duration) *History {
+	return &History{retention: retention}
+}
+
+// Record compares a freshly crawled Snapshot of a scope to the current
+// versions of its resources. Resources which are new or changed get a new
+// version, resources no longer present have their current version ended. The
+// changes found are returned, in the order of the Snapshot followed by the
+// removed resources.
+func (h *History) Record(scope Scope, s *Snapshot) Changeset {
+	return h.RecordAll(map[Scope]*Snapshot{scope: s})[0]
+}
+
+// RecordAll records a crawl of several scopes at once, so that no reader of
+// the History sees some of them recorded and others not. A Changeset is
+// returned per scope, in order of account and region.
+func (h *History) RecordAll(parts map[Scope]*Snapshot) []Changeset {
+	var scopes []Scope
+	for scope := range parts {
+		scopes = append(scopes, scope)
+	}
+	SortScopes(scopes)
+	docs := make([]map[string]map[string]interface{}, len(scopes))
+	for n, scope := range scopes {
+		s := parts[scope]
+		docs[n] = make(map[string]map[string]interface{}, len(s.items))
+		for idx, item := range s.items {
+			docs[n][item.ID] = s.recorded(idx)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.records == nil {
+		h.records = make(map[Key]*Record)
+		h.crawls = make(map[Scope][]time.Time)
+	}
+	changesets := make([]Changeset, len(scopes))
+	var latest time.Time
+	for n, scope := range scopes {
+		s := parts[scope]
+		changesets[n] = h.record(scope, s, docs[n])
+		if s.CrawledAt().After(latest) {
+			latest = s.CrawledAt()
+		}
+	}
+	h.prune(latest)
+	return changesets
+}
+
+// record records the crawl of a single scope, given its items as recorded.
+// Callers must hold the lock.
+func (h *History) record(scope Scope, s *Snapshot, docs map[string]map[string]interface{}) Changeset {
+	crawled := s.CrawledAt()
+	cs := Changeset{Scope: scope, CrawledAt: crawled}
+	cs.Initial = len(h.crawls[scope]) == 0
+	for _, item := range s.items {
+		doc := docs[item.ID]
+		key := Key{Scope: scope, ID: item.ID}
+		r, ok := h.records[key]
+		if !ok {
+			r = &Record{Scope: scope, ID: item.ID, FirstSeen: crawled}
+			h.records[key] = r
+		}
+		r.LastSeen = crawled
+		cur := r.current()
+		if cur != nil && reflect.DeepEqual(cur.Data, doc) {
+			continue
+		}
+		change := Added
+		if cur != nil {
+			cur.To = timePtr(crawled)
+			change = Modified
+		}
+		r.Versions = append(r.Versions, Version{From: crawled, Data: doc})
+		cs.Changes = append(cs.Changes, newChange(change, key, doc))
+	}
+	var removed []Change
+	for key, r := range h.records {
+		if key.Scope != scope {
+			continue
+		}
+		if _, ok := docs[key.ID]; ok {
+			continue
+		}
+		if cur := r.current(); cur != nil {
+			cur.To = timePtr(crawled)
+			removed = append(removed, newChange(Removed, key, cur.Data))
+		}
+	}
+	sort.Sort(byChangeID(removed))
+	cs.Changes = append(cs.Changes, removed...)
+	h.crawls[scope] = append(h.crawls[scope], crawled)
+	return cs
+}
+
+// newChange creates a Change, tagging a copy of the data with the account and
+// region like an expanded item
+func newChange(typ string, key Key, doc map[string]interface{}) Change {
+	data := copyDoc(doc)
+	if key.Account != "" {
+		data["AccountId"] = key.Account
+	}
+	if key.Region != "" {
+		data["Region"] = key.Region
+	}
+	return Change{Scope: key.Scope, Type: typ, ID: key.ID, Data: data}
+}
+
+// prune forgets what is older than the retention period. The most recent
+// crawl before the cutoff is kept, as it is needed to answer for any point in
+// time after it.
+func (h *History) prune(now time.Time) {
+	if h.retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-h.retention)
+	for key, r := range h.records {
+		var versions []Version
+		for _, v := range r.Versions {
+			if v.To == nil || !v.To.Before(cutoff) {
+				versions = append(versions, v)
+			}
+		}
+		if len(versions) == 0 {
+			delete(h.records, key)
+			continue
+		}
+		r.Versions = versions
+	}
+	for scope, crawls := range h.crawls {
+		first := 0
+		for idx, c := range crawls {
+			if !c.After(cutoff) {
+				first = idx
+			}
+		}
+		h.crawls[scope] = crawls[first:]
+	}
+}
+
+// At returns a Snapshot of the resources as they were at a point in time, as
+// seen by the most recent crawl before it. It returns nil if nothing had been
+// crawled yet at that time, or if it is beyond the retention period.
+func (h *History) At(t time.Time) *Snapshot {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	crawled := make(map[Scope]time.Time)
+	for scope, crawls := range h.crawls {
+		for _, c := range crawls {
+			if c.After(t) {
+				break
+			}
+			crawled[scope] = c
+		}
+	}
+	if len(crawled) == 0 {
+		return nil
+	}
+
+	items := make(map[Scope][]Item)
+	for key, r := range h.records {
+		if _, ok := crawled[key.Scope]; !ok {
+			continue
+		}
+		if v := r.at(t); v != nil {
+			items[key.Scope] = append(items[key.Scope], Item{
+				ID:      key.ID,
+				Account: key.Account,
+				Region:  key.Region,
+				Value:   v.Data,
+			})
+		}
+	}
+	parts := make(map[Scope]*Snapshot, len(crawled))
+	for scope, c := range crawled {
+		sort.Sort(byItemID(items[scope]))
+		parts[scope] = NewSnapshot(c, items[scope], copyDoc)
+	}
+	return Merge(parts)
+}
+
+// Latest returns a Snapshot per scope of the resources as last crawled
+func (h *History) Latest() map[Scope]*Snapshot {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	items := make(map[Scope][]Item)
+	for key, r := range h.records {
+		if v := r.current(); v != nil {
+			items[key.Scope] = append(items[key.Scope], Item{
+				ID:      key.ID,
+				Account: key.Account,
+				Region:  key.Region,
+				Value:   v.Data,
+			})
+		}
+	}
+	parts := make(map[Scope]*Snapshot, len(h.crawls))
+	for scope, crawls := range h.crawls {
+		sort.Sort(byItemID(items[scope]))
+		parts[scope] = NewSnapshot(crawls[len(crawls)-1], items[scope], copyDoc)
+	}
+	return parts
+}
+
+// historyState is a History as marshalled to JSON
+type historyState struct {
+	Crawls  []scopeCrawls `json:"crawls"`
+	Records []*Record     `json:"records"`
+}
+
+type scopeCrawls struct {
+	Scope
+	Times []time.Time `json:"times"`
+}
+
+// MarshalJSON encodes all crawls and records, so that the History can be
+// persisted
+func (h *History) MarshalJSON() ([]byte, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	state := historyState{
+		Crawls:  make([]scopeCrawls, 0, len(h.crawls)),
+		Records: make([]*Record, 0, len(h.records)),
+	}
+	for scope, times := range h.crawls {
+		state.Crawls = append(state.Crawls, scopeCrawls{Scope: scope, Times: times})
+	}
+	for _, r := range h.records {
+		state.Records = append(state.Records, r)
+	}
+	return json.Marshal(state)
+}
+
+// UnmarshalJSON replaces the crawls and records with persisted ones. The
+// retention period is kept.
+func (h *History) UnmarshalJSON(data []byte) error {
+	var state historyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.crawls = make(map[Scope][]time.Time, len(state.Crawls))
+	for _, sc := range state.Crawls {
+		if len(sc.Times) > 0 {
+			h.crawls[sc.Scope] = sc.Times
+		}
+	}
+	h.records = make(map[Key]*Record, len(state.Records))
+	for _, r := range state.Records {
+		h.records[Key{Scope: r.Scope, ID: r.ID}] = r
+	}
+	return nil
+}
+
+// Get returns the records of all resources with the given id, in order of
+// account and region
+func (h *History) Get(id string) []Record {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var records []Record
+	for key, r := range h.records {
+		if key.ID != id {
+			continue
+		}
+		rec := *r
+		rec.Versions = append([]Version(nil), r.Versions...)
+		records = append(records, rec)
+	}
+	sort.Sort(byRecordScope(records))
+	return records
+}
+
+// copyDoc expands a version recorded in a History, which is already expanded
+func copyDoc(item interface{}) map[string]interface{} {
+	data := make(map[string]interface{})
+	for k, v := range item.(map[string]interface{}) {
+		data[k] = v
+	}
+	return data
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+type byItemID []Item
+
+func (s byItemID) Len() int           { return len(s) }
+func (s byItemID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byItemID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+type byChangeID []Change
+
+func (s byChangeID) Len() int           { return len(s) }
+func (s byChangeID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byChangeID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+type byRecordScope []Record
+
+func (s byRecordScope) Len() int           { return len(s) }
+func (s byRecordScope) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byRecordScope) Less(i, j int) bool { return s[i].Scope.Less(s[j].Scope) }
@@ -0,0 +1,256 @@
+package melkor
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var eu = Scope{Region: "eu-west-1"}
+
+// crawl builds the snapshot of a crawl, each item carrying a state
+func crawl(crawled time.Time, sc Scope, states map[string]string) *Snapshot {
+	var data []Item
+	for id, state := range states {
+		data = append(data, Item{
+			ID:      id,
+			Account: sc.Account,
+			Region:  sc.Region,
+			Value:   map[string]interface{}{"id": id, "state": state},
+		})
+	}
+	return NewSnapshot(crawled, data, identity)
+}
+
+func Test_History_Record(t *testing.T) {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(10 * time.Minute)
+	t2 := t1.Add(10 * time.Minute)
+	t3 := t2.Add(10 * time.Minute)
+	h := NewHistory(0)
+
+	h.Record(eu, crawl(t0, eu, map[string]string{"a": "pending", "b": "running"}))
+	h.Record(eu, crawl(t1, eu, map[string]string{"a": "running", "b": "running"}))
+	h.Record(eu, crawl(t2, eu, map[string]string{"a": "running"}))
+	h.Record(eu, crawl(t3, eu, map[string]string{"a": "running", "b": "running"}))
+
+	a := h.Get("a")
+	assert.Len(t, a, 1)
+	assert.Equal(t, t0, a[0].FirstSeen)
+	assert.Equal(t, t3, a[0].LastSeen)
+	assert.Equal(t, "eu-west-1", a[0].Region)
+	assert.Len(t, a[0].Versions, 2)
+	assert.Equal(t, "pending", a[0].Versions[0].Data["state"])
+	assert.Equal(t, t1, *a[0].Versions[0].To)
+	assert.Equal(t, "running", a[0].Versions[1].Data["state"])
+	assert.Nil(t, a[0].Versions[1].To)
+
+	b := h.Get("b")
+	assert.Len(t, b, 1)
+	assert.Equal(t, t0, b[0].FirstSeen)
+	assert.Equal(t, t3, b[0].LastSeen)
+	assert.Len(t, b[0].Versions, 2)
+	assert.Equal(t, t2, *b[0].Versions[0].To)
+	assert.Equal(t, t3, b[0].Versions[1].From)
+
+	assert.Empty(t, h.Get("c"))
+}
+
+func Test_History_At(t *testing.T) {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(10 * time.Minute)
+	t2 := t1.Add(10 * time.Minute)
+	h := NewHistory(0)
+
+	h.Record(eu, crawl(t0, eu, map[string]string{"b": "pending", "a": "running"}))
+	h.Record(eu, crawl(t1, eu, map[string]string{"a": "running", "b": "running", "c": "pending"}))
+	h.Record(eu, crawl(t2, eu, map[string]string{"c": "running"}))
+
+	assert.Nil(t, h.At(t0.Add(-time.Second)))
+
+	s := h.At(t0.Add(5 * time.Minute))
+	assert.Equal(t, []string{"a", "b"}, s.List())
+	assert.Equal(t, "pending", s.Get("b")["state"])
+	assert.Equal(t, "eu-west-1", s.Get("b")["Region"])
+	assert.Equal(t, t0, s.CrawledAt())
+
+	s = h.At(t1)
+	assert.Equal(t, []string{"a", "b", "c"}, s.List())
+	assert.Equal(t, "running", s.Get("b")["state"])
+	assert.Equal(t, t1, s.CrawledAt())
+
+	s = h.At(t2.Add(time.Hour))
+	assert.Equal(t, []string{"c"}, s.List())
+	assert.Equal(t, []string{"c"}, s.Region("eu-west-1").List())
+	assert.Nil(t, s.Region("us-east-1"))
+}
+
+func Test_History_At_Scopes(t *testing.T) {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	us := Scope{Region: "us-east-1"}
+	h := NewHistory(0)
+
+	h.Record(eu, crawl(t0, eu, map[string]string{"a": "running"}))
+	h.Record(us, crawl(t0.Add(time.Minute), us, map[string]string{"b": "running"}))
+
+	assert.Equal(t, []string{"a"}, h.At(t0).List())
+	assert.Equal(t, []string{"a", "b"}, h.At(t0.Add(time.Minute)).List())
+	assert.Equal(t, []string{"b"}, h.At(t0.Add(time.Minute)).Region("us-east-1").List())
+}
+
+func Test_History_Retention(t *testing.T) {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHistory(time.Hour)
+
+	h.Record(eu, crawl(t0, eu, map[string]string{"a": "pending", "b": "running"}))
+	h.Record(eu, crawl(t0.Add(30*time.Minute), eu, map[string]string{"a": "running"}))
+	h.Record(eu, crawl(t0.Add(2*time.Hour), eu, map[string]string{"a": "running"}))
+
+	assert.Empty(t, h.Get("b"))
+	assert.Len(t, h.Get("a")[0].Versions, 1)
+	assert.Nil(t, h.At(t0.Add(10*time.Minute)))
+	assert.Equal(t, []string{"a"}, h.At(t0.Add(90*time.Minute)).List())
+}
+
+func Test_History_Get_IsCopy(t *testing.T) {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHistory(0)
+
+	h.Record(eu, crawl(t0, eu, map[string]string{"a": "pending"}))
+	a := h.Get("a")
+	h.Record(eu, crawl(t0.Add(time.Minute), eu, map[string]string{"a": "running"}))
+
+	assert.Len(t, a[0].Versions, 1)
+	assert.Nil(t, a[0].Versions[0].To)
+}
+
+func Test_History_Zero(t *testing.T) {
+	h := &History{}
+
+	assert.Nil(t, h.At(time.Now()))
+	assert.Empty(t, h.Get("a"))
+
+	h.Record(eu, crawl(time.Now(), eu, map[string]string{"a": "running"}))
+	assert.Len(t, h.Get("a"), 1)
+}
+
+func Test_History_Record_Changes(t *testing.T) {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHistory(0)
+
+	cs := h.Record(eu, NewSnapshot(t0, append(items(eu, "a"), items(eu, "b")...), identity))
+	assert.Equal(t, eu, cs.Scope)
+	assert.Equal(t, t0, cs.CrawledAt)
+	assert.True(t, cs.Initial)
+	assert.Len(t, cs.Changes, 2)
+	assert.Equal(t, Added, cs.Changes[0].Type)
+	assert.Equal(t, "a", cs.Changes[0].ID)
+	assert.Equal(t, "eu-west-1", cs.Changes[0].Data["Region"])
+
+	cs = h.Record(eu, crawl(t0.Add(time.Minute), eu, map[string]string{"a": "running", "c": "pending"}))
+	assert.False(t, cs.Initial)
+	assert.Equal(t, []Change{
+		{Scope: eu, Type: Modified, ID: "a", Data: map[string]interface{}{"id": "a", "state": "running", "Region": "eu-west-1"}},
+		{Scope: eu, Type: Added, ID: "c", Data: map[string]interface{}{"id": "c", "state": "pending", "Region": "eu-west-1"}},
+		{Scope: eu, Type: Removed, ID: "b", Data: map[string]interface{}{"id": "b", "Region": "eu-west-1"}},
+	}, sortAddedModified(cs.Changes))
+
+	cs = h.Record(eu, crawl(t0.Add(2*time.Minute), eu, map[string]string{"a": "running", "c": "pending"}))
+	assert.Empty(t, cs.Changes)
+}
+
+// sortAddedModified orders the changes of a crawl built from a map, leaving the
+// removed ones last
+func sortAddedModified(changes []Change) []Change {
+	var n int
+	for n < len(changes) && changes[n].Type != Removed {
+		n++
+	}
+	sort.Sort(byChangeID(changes[:n]))
+	return changes
+}
+
+func Test_History_RecordAll(t *testing.T) {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	us := Scope{Region: "us-east-1"}
+	h := NewHistory(0)
+
+	changesets := h.RecordAll(map[Scope]*Snapshot{
+		us: crawl(t0, us, map[string]string{"b": "running"}),
+		eu: crawl(t0, eu, map[string]string{"a": "running"}),
+	})
+
+	assert.Len(t, changesets, 2)
+	assert.Equal(t, eu, changesets[0].Scope)
+	assert.Equal(t, "a", changesets[0].Changes[0].ID)
+	assert.Equal(t, us, changesets[1].Scope)
+	assert.True(t, changesets[1].Initial)
+	assert.Equal(t, []string{"a", "b"}, h.At(t0).List())
+}
+
+func Test_History_Record_Unrecorded(t *testing.T) {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHistory(0)
+
+	h.Record(eu, crawl(t0, eu, map[string]string{"a": "pending"}).WithUnrecorded("state"))
+	cs := h.Record(eu, crawl(t0.Add(time.Minute), eu, map[string]string{"a": "running"}).WithUnrecorded("state"))
+
+	assert.Empty(t, cs.Changes)
+	a := h.Get("a")
+	assert.Len(t, a[0].Versions, 1)
+	assert.Equal(t, map[string]interface{}{"id": "a"}, a[0].Versions[0].Data)
+}
+
+func Test_History_Latest(t *testing.T) {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	us := Scope{Region: "us-east-1"}
+	h := NewHistory(0)
+
+	h.Record(eu, crawl(t0, eu, map[string]string{"a": "pending", "b": "running"}))
+	h.Record(us, crawl(t0, us, map[string]string{"c": "running"}))
+	h.Record(eu, crawl(t0.Add(time.Minute), eu, map[string]string{"a": "running"}))
+	h.Record(us, crawl(t0.Add(2*time.Minute), us, map[string]string{}))
+
+	latest := h.Latest()
+	assert.Len(t, latest, 2)
+	assert.Equal(t, []string{"a"}, latest[eu].List())
+	assert.Equal(t, "running", latest[eu].Get("a")["state"])
+	assert.Equal(t, t0.Add(time.Minute), latest[eu].CrawledAt())
+	assert.Equal(t, 0, latest[us].Count())
+	assert.Equal(t, t0.Add(2*time.Minute), latest[us].CrawledAt())
+
+	assert.Empty(t, (&History{}).Latest())
+}
+
+func Test_History_JSON(t *testing.T) {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	a1 := Scope{Account: "111111111111", Region: "eu-west-1"}
+	h := NewHistory(0)
+	h.Record(a1, crawl(t0, a1, map[string]string{"a": "pending", "b": "running"}))
+	h.Record(a1, crawl(t0.Add(time.Minute), a1, map[string]string{"a": "running"}))
+
+	data, err := json.Marshal(h)
+	assert.Nil(t, err)
+
+	restored := NewHistory(time.Hour)
+	err = json.Unmarshal(data, restored)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, restored.retention)
+
+	a := restored.Get("a")
+	assert.Len(t, a, 1)
+	assert.Equal(t, a1, a[0].Scope)
+	assert.True(t, t0.Equal(a[0].FirstSeen))
+	assert.Len(t, a[0].Versions, 2)
+	assert.Equal(t, []string{"a", "b"}, restored.At(t0).List())
+	assert.Equal(t, "111111111111", restored.At(t0).Get("a")["AccountId"])
+
+	cs := restored.Record(a1, crawl(t0.Add(2*time.Minute), a1, map[string]string{"a": "running"}))
+	assert.False(t, cs.Initial)
+	assert.Empty(t, cs.Changes, "restored versions compare equal to crawled ones")
+
+	assert.NotNil(t, json.Unmarshal([]byte("{"), restored))
+}
@@ -1,17 +1,51 @@
 package mock
 
-import "github.com/aws/aws-sdk-go/service/ec2"
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
 
 // The EC2Client struct holds the mock implementation of the EC2Client, to
 // facilitate testing
 type EC2Client struct {
 	DescribeInstancesFn        func(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error)
 	DescribeInstancesFnInvoked bool
+	// DescribeInstancesPages, if set, are served one at a time by the default
+	// DescribeInstancesFn, linked together by NextToken.
+	DescribeInstancesPages []*ec2.DescribeInstancesOutput
+	// DescribeInstancesInputs records the input of every call
+	DescribeInstancesInputs []*ec2.DescribeInstancesInput
+
+	DescribeSecurityGroupsFn        func(*ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error)
+	DescribeSecurityGroupsFnInvoked bool
+
+	DescribeVolumesFn        func(*ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error)
+	DescribeVolumesFnInvoked bool
+	// DescribeVolumesPages, if set, are served one at a time by the default
+	// DescribeVolumesFn, linked together by NextToken.
+	DescribeVolumesPages []*ec2.DescribeVolumesOutput
+
+	DescribeSnapshotsFn        func(*ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error)
+	DescribeSnapshotsFnInvoked bool
+	// DescribeSnapshotsPages, if set, are served one at a time by the default
+	// DescribeSnapshotsFn, linked together by NextToken.
+	DescribeSnapshotsPages []*ec2.DescribeSnapshotsOutput
+	// DescribeSnapshotsInputs records the input of every call
+	DescribeSnapshotsInputs []*ec2.DescribeSnapshotsInput
+
+	DescribeImagesFn        func(*ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error)
+	DescribeImagesFnInvoked bool
+	// DescribeImagesInputs records the input of every call
+	DescribeImagesInputs []*ec2.DescribeImagesInput
 }
 
 // DescribeInstances is a mock implementation of ec2.DescribeInstances
 func (m *EC2Client) DescribeInstances(params *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
 	m.DescribeInstancesFnInvoked = true
+	m.DescribeInstancesInputs = append(m.DescribeInstancesInputs, params)
 	if m.DescribeInstancesFn == nil {
 		return m.defaultDescribeInstancesFn(params)
 	}
@@ -19,6 +53,15 @@ func (m *EC2Client) DescribeInstances(params *ec2.DescribeInstancesInput) (*ec2.
 }
 
 func (m *EC2Client) defaultDescribeInstancesFn(params *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	if m.DescribeInstancesPages != nil {
+		page, next, err := pageFor(params.NextToken, len(m.DescribeInstancesPages))
+		if err != nil {
+			return nil, err
+		}
+		out := *m.DescribeInstancesPages[page]
+		out.NextToken = next
+		return &out, nil
+	}
 	instances := []*ec2.Instance{
 		{},
 		{},
@@ -31,3 +74,104 @@ func (m *EC2Client) defaultDescribeInstancesFn(params *ec2.DescribeInstancesInpu
 		Reservations: reservations,
 	}, nil
 }
+
+// DescribeSecurityGroups is a mock implementation of ec2.DescribeSecurityGroups
+func (m *EC2Client) DescribeSecurityGroups(params *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	m.DescribeSecurityGroupsFnInvoked = true
+	if m.DescribeSecurityGroupsFn == nil {
+		return &ec2.DescribeSecurityGroupsOutput{
+			SecurityGroups: []*ec2.SecurityGroup{
+				{GroupId: aws.String("sg-0")},
+				{GroupId: aws.String("sg-1")},
+			},
+		}, nil
+	}
+	return m.DescribeSecurityGroupsFn(params)
+}
+
+// DescribeVolumes is a mock implementation of ec2.DescribeVolumes
+func (m *EC2Client) DescribeVolumes(params *ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+	m.DescribeVolumesFnInvoked = true
+	if m.DescribeVolumesFn == nil {
+		return m.defaultDescribeVolumesFn(params)
+	}
+	return m.DescribeVolumesFn(params)
+}
+
+func (m *EC2Client) defaultDescribeVolumesFn(params *ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+	if m.DescribeVolumesPages != nil {
+		page, next, err := pageFor(params.NextToken, len(m.DescribeVolumesPages))
+		if err != nil {
+			return nil, err
+		}
+		out := *m.DescribeVolumesPages[page]
+		out.NextToken = next
+		return &out, nil
+	}
+	return &ec2.DescribeVolumesOutput{
+		Volumes: []*ec2.Volume{
+			{VolumeId: aws.String("vol-0")},
+			{VolumeId: aws.String("vol-1")},
+		},
+	}, nil
+}
+
+// DescribeSnapshots is a mock implementation of ec2.DescribeSnapshots
+func (m *EC2Client) DescribeSnapshots(params *ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error) {
+	m.DescribeSnapshotsFnInvoked = true
+	m.DescribeSnapshotsInputs = append(m.DescribeSnapshotsInputs, params)
+	if m.DescribeSnapshotsFn == nil {
+		return m.defaultDescribeSnapshotsFn(params)
+	}
+	return m.DescribeSnapshotsFn(params)
+}
+
+func (m *EC2Client) defaultDescribeSnapshotsFn(params *ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error) {
+	if m.DescribeSnapshotsPages != nil {
+		page, next, err := pageFor(params.NextToken, len(m.DescribeSnapshotsPages))
+		if err != nil {
+			return nil, err
+		}
+		out := *m.DescribeSnapshotsPages[page]
+		out.NextToken = next
+		return &out, nil
+	}
+	return &ec2.DescribeSnapshotsOutput{
+		Snapshots: []*ec2.Snapshot{
+			{SnapshotId: aws.String("snap-0")},
+			{SnapshotId: aws.String("snap-1")},
+		},
+	}, nil
+}
+
+// DescribeImages is a mock implementation of ec2.DescribeImages
+func (m *EC2Client) DescribeImages(params *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	m.DescribeImagesFnInvoked = true
+	m.DescribeImagesInputs = append(m.DescribeImagesInputs, params)
+	if m.DescribeImagesFn == nil {
+		return &ec2.DescribeImagesOutput{
+			Images: []*ec2.Image{
+				{ImageId: aws.String("ami-0")},
+				{ImageId: aws.String("ami-1")},
+			},
+		}, nil
+	}
+	return m.DescribeImagesFn(params)
+}
+
+// pageFor resolves a NextToken handed out by the mock into a page index, and
+// returns the token leading to the page after it, if any
+func pageFor(token *string, pages int) (int, *string, error) {
+	page := 0
+	if token != nil {
+		p, err := strconv.Atoi(aws.StringValue(token))
+		if err != nil || p <= 0 || p >= pages {
+			return 0, nil, fmt.Errorf("invalid NextToken %q", aws.StringValue(token))
+		}
+		page = p
+	}
+	if page+1 == pages {
+		return page, nil, nil
+	}
+	return page, aws.String(strconv.Itoa(page + 1)), nil
+}
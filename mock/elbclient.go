@@ -0,0 +1,59 @@
+package mock
+
+import (
+	"github.com/alde/melkor/fixtures"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elb"
+)
+
+// The ELBClient struct holds the mock implementation of the classic ELB
+// client, to facilitate testing. By default it describes two classic load
+// balancers, as built by the fixtures, along with the health of their
+// instances.
+type ELBClient struct {
+	DescribeLoadBalancersFn        func(*elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error)
+	DescribeLoadBalancersFnInvoked bool
+	// DescribeLoadBalancersPages, if set, are served one at a time by the
+	// default DescribeLoadBalancersFn, linked together by NextMarker.
+	DescribeLoadBalancersPages []*elb.DescribeLoadBalancersOutput
+	// DescribeLoadBalancersInputs records the input of every call
+	DescribeLoadBalancersInputs []*elb.DescribeLoadBalancersInput
+
+	DescribeInstanceHealthFn        func(*elb.DescribeInstanceHealthInput) (*elb.DescribeInstanceHealthOutput, error)
+	DescribeInstanceHealthFnInvoked bool
+}
+
+// DescribeLoadBalancers is a mock implementation of elb.DescribeLoadBalancers
+func (m *ELBClient) DescribeLoadBalancers(params *elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error) {
+	m.DescribeLoadBalancersFnInvoked = true
+	m.DescribeLoadBalancersInputs = append(m.DescribeLoadBalancersInputs, params)
+	if m.DescribeLoadBalancersFn == nil {
+		return m.defaultDescribeLoadBalancersFn(params)
+	}
+	return m.DescribeLoadBalancersFn(params)
+}
+
+func (m *ELBClient) defaultDescribeLoadBalancersFn(params *elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error) {
+	if m.DescribeLoadBalancersPages != nil {
+		page, next, err := pageFor(params.Marker, len(m.DescribeLoadBalancersPages))
+		if err != nil {
+			return nil, err
+		}
+		out := *m.DescribeLoadBalancersPages[page]
+		out.NextMarker = next
+		return &out, nil
+	}
+	return &elb.DescribeLoadBalancersOutput{LoadBalancerDescriptions: fixtures.ClassicLoadBalancers(2)}, nil
+}
+
+// DescribeInstanceHealth is a mock implementation of elb.DescribeInstanceHealth
+func (m *ELBClient) DescribeInstanceHealth(params *elb.DescribeInstanceHealthInput) (*elb.DescribeInstanceHealthOutput, error) {
+	m.DescribeInstanceHealthFnInvoked = true
+	if m.DescribeInstanceHealthFn == nil {
+		return &elb.DescribeInstanceHealthOutput{
+			InstanceStates: fixtures.InstanceHealth(aws.StringValue(params.LoadBalancerName)),
+		}, nil
+	}
+	return m.DescribeInstanceHealthFn(params)
+}
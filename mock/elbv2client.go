@@ -0,0 +1,79 @@
+package mock
+
+import (
+	"github.com/alde/melkor/fixtures"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// The ELBv2Client struct holds the mock implementation of the ELBv2 client,
+// describing application and network load balancers, to facilitate testing.
+// By default it describes two of each, as built by the fixtures.
+type ELBv2Client struct {
+	DescribeLoadBalancersFn        func(*elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error)
+	DescribeLoadBalancersFnInvoked bool
+
+	DescribeListenersFn        func(*elbv2.DescribeListenersInput) (*elbv2.DescribeListenersOutput, error)
+	DescribeListenersFnInvoked bool
+
+	DescribeTargetGroupsFn        func(*elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error)
+	DescribeTargetGroupsFnInvoked bool
+	// DescribeTargetGroupsPages, if set, are served one at a time by the
+	// default DescribeTargetGroupsFn, linked together by NextMarker.
+	DescribeTargetGroupsPages []*elbv2.DescribeTargetGroupsOutput
+
+	DescribeTargetHealthFn        func(*elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error)
+	DescribeTargetHealthFnInvoked bool
+}
+
+// DescribeLoadBalancers is a mock implementation of elbv2.DescribeLoadBalancers
+func (m *ELBv2Client) DescribeLoadBalancers(params *elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error) {
+	m.DescribeLoadBalancersFnInvoked = true
+	if m.DescribeLoadBalancersFn == nil {
+		return &elbv2.DescribeLoadBalancersOutput{LoadBalancers: fixtures.LoadBalancers(2)}, nil
+	}
+	return m.DescribeLoadBalancersFn(params)
+}
+
+// DescribeListeners is a mock implementation of elbv2.DescribeListeners
+func (m *ELBv2Client) DescribeListeners(params *elbv2.DescribeListenersInput) (*elbv2.DescribeListenersOutput, error) {
+	m.DescribeListenersFnInvoked = true
+	if m.DescribeListenersFn == nil {
+		return &elbv2.DescribeListenersOutput{Listeners: fixtures.Listeners(aws.StringValue(params.LoadBalancerArn))}, nil
+	}
+	return m.DescribeListenersFn(params)
+}
+
+// DescribeTargetGroups is a mock implementation of elbv2.DescribeTargetGroups
+func (m *ELBv2Client) DescribeTargetGroups(params *elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error) {
+	m.DescribeTargetGroupsFnInvoked = true
+	if m.DescribeTargetGroupsFn == nil {
+		return m.defaultDescribeTargetGroupsFn(params)
+	}
+	return m.DescribeTargetGroupsFn(params)
+}
+
+func (m *ELBv2Client) defaultDescribeTargetGroupsFn(params *elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error) {
+	if m.DescribeTargetGroupsPages != nil {
+		page, next, err := pageFor(params.Marker, len(m.DescribeTargetGroupsPages))
+		if err != nil {
+			return nil, err
+		}
+		out := *m.DescribeTargetGroupsPages[page]
+		out.NextMarker = next
+		return &out, nil
+	}
+	return &elbv2.DescribeTargetGroupsOutput{TargetGroups: fixtures.TargetGroups(2)}, nil
+}
+
+// DescribeTargetHealth is a mock implementation of elbv2.DescribeTargetHealth
+func (m *ELBv2Client) DescribeTargetHealth(params *elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error) {
+	m.DescribeTargetHealthFnInvoked = true
+	if m.DescribeTargetHealthFn == nil {
+		return &elbv2.DescribeTargetHealthOutput{
+			TargetHealthDescriptions: fixtures.TargetHealth(aws.StringValue(params.TargetGroupArn)),
+		}, nil
+	}
+	return m.DescribeTargetHealthFn(params)
+}
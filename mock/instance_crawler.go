@@ -1,6 +1,10 @@
 package mock
 
-import "time"
+import (
+	"time"
+
+	"github.com/alde/melkor"
+)
 
 // The InstanceCrawler mock struct holds the mocked implementation for the crawler interface
 type InstanceCrawler struct {
@@ -13,6 +17,21 @@ type InstanceCrawler struct {
 	DoCrawlFn        func() error
 	DoCrawlFnInvoked bool
 
+	SnapshotFn        func() *melkor.Snapshot
+	SnapshotFnInvoked bool
+
+	HistoryFn        func() *melkor.History
+	HistoryFnInvoked bool
+
+	// Listeners registered with OnChange, see Notify
+	Listeners []func(melkor.Changeset)
+
+	RestoreFn        func([]byte) error
+	RestoreFnInvoked bool
+
+	StatusFn        func() []melkor.CrawlStatus
+	StatusFnInvoked bool
+
 	ListFn        func() []string
 	ListFnInvoked bool
 
@@ -45,6 +64,9 @@ func (mc *InstanceCrawler) Resource() string {
 // LastCrawled is the timestamp of the most recent crawl
 func (mc *InstanceCrawler) LastCrawled() time.Time {
 	mc.LastCrawledFnInvoked = true
+	if mc.LastCrawledFn == nil {
+		return mc.Snapshot().CrawledAt()
+	}
 	return mc.LastCrawledFn()
 }
 
@@ -54,6 +76,71 @@ func (mc *InstanceCrawler) DoCrawl() error {
 	return mc.DoCrawl()
 }
 
+// Snapshot returns the current snapshot
+func (mc *InstanceCrawler) Snapshot() *melkor.Snapshot {
+	mc.SnapshotFnInvoked = true
+	if mc.SnapshotFn == nil {
+		return mc.defaultSnapshotFn()
+	}
+	return mc.SnapshotFn()
+}
+
+func (mc *InstanceCrawler) defaultSnapshotFn() *melkor.Snapshot {
+	items := make([]melkor.Item, len(mc.Data))
+	for idx, d := range mc.Data {
+		items[idx] = melkor.Item{ID: d["InstanceId"].(string), Value: d}
+	}
+	return melkor.NewSnapshot(time.Time{}, items, ExpandData)
+}
+
+// ExpandData expands an item of mocked data, which is already expanded
+func ExpandData(item interface{}) map[string]interface{} {
+	data := make(map[string]interface{})
+	for k, v := range item.(map[string]interface{}) {
+		data[k] = v
+	}
+	return data
+}
+
+// History returns the history of all crawls
+func (mc *InstanceCrawler) History() *melkor.History {
+	mc.HistoryFnInvoked = true
+	if mc.HistoryFn == nil {
+		return &melkor.History{}
+	}
+	return mc.HistoryFn()
+}
+
+// OnChange registers a listener
+func (mc *InstanceCrawler) OnChange(fn func(melkor.Changeset)) {
+	mc.Listeners = append(mc.Listeners, fn)
+}
+
+// Notify tells all listeners about a changeset, as if it was crawled
+func (mc *InstanceCrawler) Notify(cs melkor.Changeset) {
+	for _, fn := range mc.Listeners {
+		fn(cs)
+	}
+}
+
+// Restore warm-starts the crawler
+func (mc *InstanceCrawler) Restore(data []byte) error {
+	mc.RestoreFnInvoked = true
+	if mc.RestoreFn == nil {
+		return nil
+	}
+	return mc.RestoreFn(data)
+}
+
+// Status reports the crawl status per region
+func (mc *InstanceCrawler) Status() []melkor.CrawlStatus {
+	mc.StatusFnInvoked = true
+	if mc.StatusFn == nil {
+		return []melkor.CrawlStatus{}
+	}
+	return mc.StatusFn()
+}
+
 // List resources
 func (mc *InstanceCrawler) List() []string {
 	mc.ListFnInvoked = true
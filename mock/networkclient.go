@@ -0,0 +1,100 @@
+package mock
+
+import (
+	"github.com/alde/melkor/fixtures"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// The NetworkClient struct holds the mock implementation of the parts of the
+// EC2Client describing VPCs and their networking, to facilitate testing. By
+// default every call describes two of each, as built by the fixtures.
+type NetworkClient struct {
+	DescribeVpcsFn        func(*ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error)
+	DescribeVpcsFnInvoked bool
+
+	DescribeSubnetsFn        func(*ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error)
+	DescribeSubnetsFnInvoked bool
+
+	DescribeRouteTablesFn        func(*ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error)
+	DescribeRouteTablesFnInvoked bool
+
+	DescribeInternetGatewaysFn        func(*ec2.DescribeInternetGatewaysInput) (*ec2.DescribeInternetGatewaysOutput, error)
+	DescribeInternetGatewaysFnInvoked bool
+
+	DescribeNatGatewaysFn        func(*ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error)
+	DescribeNatGatewaysFnInvoked bool
+	// DescribeNatGatewaysPages, if set, are served one at a time by the default
+	// DescribeNatGatewaysFn, linked together by NextToken.
+	DescribeNatGatewaysPages []*ec2.DescribeNatGatewaysOutput
+
+	DescribeNetworkInterfacesFn        func(*ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error)
+	DescribeNetworkInterfacesFnInvoked bool
+}
+
+// DescribeVpcs is a mock implementation of ec2.DescribeVpcs
+func (m *NetworkClient) DescribeVpcs(params *ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error) {
+	m.DescribeVpcsFnInvoked = true
+	if m.DescribeVpcsFn == nil {
+		return &ec2.DescribeVpcsOutput{Vpcs: fixtures.Vpcs(2)}, nil
+	}
+	return m.DescribeVpcsFn(params)
+}
+
+// DescribeSubnets is a mock implementation of ec2.DescribeSubnets
+func (m *NetworkClient) DescribeSubnets(params *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	m.DescribeSubnetsFnInvoked = true
+	if m.DescribeSubnetsFn == nil {
+		return &ec2.DescribeSubnetsOutput{Subnets: fixtures.Subnets(2)}, nil
+	}
+	return m.DescribeSubnetsFn(params)
+}
+
+// DescribeRouteTables is a mock implementation of ec2.DescribeRouteTables
+func (m *NetworkClient) DescribeRouteTables(params *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+	m.DescribeRouteTablesFnInvoked = true
+	if m.DescribeRouteTablesFn == nil {
+		return &ec2.DescribeRouteTablesOutput{RouteTables: fixtures.RouteTables(2)}, nil
+	}
+	return m.DescribeRouteTablesFn(params)
+}
+
+// DescribeInternetGateways is a mock implementation of ec2.DescribeInternetGateways
+func (m *NetworkClient) DescribeInternetGateways(params *ec2.DescribeInternetGatewaysInput) (*ec2.DescribeInternetGatewaysOutput, error) {
+	m.DescribeInternetGatewaysFnInvoked = true
+	if m.DescribeInternetGatewaysFn == nil {
+		return &ec2.DescribeInternetGatewaysOutput{InternetGateways: fixtures.InternetGateways(2)}, nil
+	}
+	return m.DescribeInternetGatewaysFn(params)
+}
+
+// DescribeNatGateways is a mock implementation of ec2.DescribeNatGateways
+func (m *NetworkClient) DescribeNatGateways(params *ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error) {
+	m.DescribeNatGatewaysFnInvoked = true
+	if m.DescribeNatGatewaysFn == nil {
+		return m.defaultDescribeNatGatewaysFn(params)
+	}
+	return m.DescribeNatGatewaysFn(params)
+}
+
+func (m *NetworkClient) defaultDescribeNatGatewaysFn(params *ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error) {
+	if m.DescribeNatGatewaysPages != nil {
+		page, next, err := pageFor(params.NextToken, len(m.DescribeNatGatewaysPages))
+		if err != nil {
+			return nil, err
+		}
+		out := *m.DescribeNatGatewaysPages[page]
+		out.NextToken = next
+		return &out, nil
+	}
+	return &ec2.DescribeNatGatewaysOutput{NatGateways: fixtures.NatGateways(2)}, nil
+}
+
+// DescribeNetworkInterfaces is a mock implementation of ec2.DescribeNetworkInterfaces
+func (m *NetworkClient) DescribeNetworkInterfaces(params *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	m.DescribeNetworkInterfacesFnInvoked = true
+	if m.DescribeNetworkInterfacesFn == nil {
+		return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: fixtures.NetworkInterfaces(2)}, nil
+	}
+	return m.DescribeNetworkInterfacesFn(params)
+}
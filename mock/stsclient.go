@@ -0,0 +1,41 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// The STSClient struct holds the mock implementation of the STSClient, to
+// facilitate testing
+type STSClient struct {
+	AssumeRoleFn        func(*sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error)
+	AssumeRoleFnInvoked bool
+	// AssumeRoleInputs records the input of every call
+	AssumeRoleInputs []*sts.AssumeRoleInput
+}
+
+// AssumeRole is a mock implementation of sts.AssumeRole
+func (m *STSClient) AssumeRole(params *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	m.AssumeRoleFnInvoked = true
+	m.AssumeRoleInputs = append(m.AssumeRoleInputs, params)
+	if m.AssumeRoleFn == nil {
+		return m.defaultAssumeRoleFn(params)
+	}
+	return m.AssumeRoleFn(params)
+}
+
+func (m *STSClient) defaultAssumeRoleFn(params *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	return &sts.AssumeRoleOutput{
+		AssumedRoleUser: &sts.AssumedRoleUser{
+			Arn: aws.String(aws.StringValue(params.RoleArn) + "/" + aws.StringValue(params.RoleSessionName)),
+		},
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("AKIAMOCK"),
+			SecretAccessKey: aws.String("mock-secret"),
+			SessionToken:    aws.String("mock-token"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
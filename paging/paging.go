@@ -0,0 +1,310 @@
+// Package paging orders listed items and pages through them with cursors.
+//
+// A cursor holds the sort keys of the last item of a page rather than an
+// offset, so that paging on after the crawlers swapped in a new snapshot
+// neither skips nor repeats items which were there all along.
+package paging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrBadCursor is returned for cursors which cannot be decoded, or which were
+// issued for another order
+var ErrBadCursor = errors.New("invalid cursor")
+
+// A Row is an item being listed. Doc is the expanded item, which is only
+// needed when sorting by its fields. Index is left to the caller, to find the
+// item the row was listed from.
+type Row struct {
+	ID      string
+	Account string
+	Region  string
+	Doc     map[string]interface{}
+	Index   int
+
+	keys []interface{}
+}
+
+// An Order sorts rows by a list of fields, then by id, account and region so
+// that every row has a place of its own
+type Order struct {
+	spec string
+	keys []key
+}
+
+type key struct {
+	path []string
+	desc bool
+}
+
+// ParseOrder parses a comma separated list of dot separated paths to sort by,
+// each descending if prefixed by '-', as in LaunchTime,-Tags.Name. An empty
+// list sorts by id only.
+func ParseOrder(spec string) (Order, error) {
+	o := Order{spec: spec}
+	if spec == "" {
+		return o, nil
+	}
+	for _, field := range strings.Split(spec, ",") {
+		k := key{}
+		field = strings.TrimSpace(field)
+		switch {
+		case strings.HasPrefix(field, "-"):
+			k.desc = true
+			field = field[1:]
+		case strings.HasPrefix(field, "+"):
+			field = field[1:]
+		}
+		k.path = strings.Split(field, ".")
+		for _, seg := range k.path {
+			if seg == "" {
+				return Order{}, fmt.Errorf("invalid sort field %q", field)
+			}
+		}
+		o.keys = append(o.keys, k)
+	}
+	return o, nil
+}
+
+// ByFields checks whether the order needs the expanded items
+func (o Order) ByFields() bool {
+	return len(o.keys) > 0
+}
+
+// Sort sorts rows, stably
+func (o Order) Sort(rows []Row) {
+	for idx := range rows {
+		rows[idx].keys = o.keysOf(rows[idx])
+	}
+	sort.Stable(byOrder{o, rows})
+}
+
+// After returns the rows of a sorted list following the one a cursor was
+// issued for, whether or not that row is still there
+func (o Order) After(rows []Row, cursor string) ([]Row, error) {
+	c, err := o.decode(cursor)
+	if err != nil {
+		return nil, err
+	}
+	idx := sort.Search(len(rows), func(idx int) bool {
+		return o.compare(rows[idx].keys, c) > 0
+	})
+	return rows[idx:], nil
+}
+
+// Check returns ErrBadCursor if a cursor was not issued for this order, so
+// that it can be rejected before any rows are listed
+func (o Order) Check(cursor string) error {
+	_, err := o.decode(cursor)
+	return err
+}
+
+// Cursor returns the cursor to page on after a row of a sorted list
+func (o Order) Cursor(r Row) string {
+	b, _ := json.Marshal(cursor{Sort: o.spec, Keys: r.keys})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// A cursor is the sort keys of a row, as encoded in its token
+type cursor struct {
+	Sort string        `json:"s"`
+	Keys []interface{} `json:"k"`
+}
+
+func (o Order) decode(token string) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrBadCursor
+	}
+	if c.Sort != o.spec || len(c.Keys) != len(o.keys)+3 {
+		return nil, ErrBadCursor
+	}
+	for idx, k := range c.Keys[:len(o.keys)] {
+		c.Keys[idx] = sortValue(k)
+	}
+	for _, k := range c.Keys[len(o.keys):] {
+		if _, ok := k.(string); !ok {
+			return nil, ErrBadCursor
+		}
+	}
+	return c.Keys, nil
+}
+
+// keysOf returns the values a row is sorted by: the fields, then the id,
+// account and region
+func (o Order) keysOf(r Row) []interface{} {
+	keys := make([]interface{}, 0, len(o.keys)+3)
+	for _, k := range o.keys {
+		keys = append(keys, sortValue(first(r.Doc, k.path)))
+	}
+	return append(keys, r.ID, r.Account, r.Region)
+}
+
+// compare orders the keys of two rows. Missing values sort last, whether
+// ascending or descending.
+func (o Order) compare(a, b []interface{}) int {
+	for idx := range a {
+		if a[idx] == nil || b[idx] == nil {
+			switch {
+			case a[idx] == nil && b[idx] == nil:
+				continue
+			case a[idx] == nil:
+				return 1
+			}
+			return -1
+		}
+		c := compareValues(a[idx], b[idx])
+		if idx < len(o.keys) && o.keys[idx].desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// sortValue returns a value as a bool, float64, time.Time or string, or nil if
+// it is neither. Strings holding a point in time are taken as one, as that is
+// how times are stored once recorded in the history, and in cursors.
+func sortValue(v interface{}) interface{} {
+	rv := indirect(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		if t, err := time.Parse(time.RFC3339Nano, rv.String()); err == nil {
+			return t
+		}
+		return rv.String()
+	case reflect.Struct:
+		if t, ok := rv.Interface().(time.Time); ok {
+			return t
+		}
+	}
+	return nil
+}
+
+// rank orders values of different types
+func rank(v interface{}) int {
+	switch v.(type) {
+	case bool:
+		return 0
+	case float64:
+		return 1
+	case time.Time:
+		return 2
+	}
+	return 3
+}
+
+func compareValues(a, b interface{}) int {
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	switch x := a.(type) {
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case float64:
+		y := b.(float64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case time.Time:
+		y := b.(time.Time)
+		switch {
+		case x.Before(y):
+			return -1
+		case x.After(y):
+			return 1
+		}
+		return 0
+	}
+	x, y := a.(string), b.(string)
+	if c := strings.Compare(strings.ToLower(x), strings.ToLower(y)); c != 0 {
+		return c
+	}
+	return strings.Compare(x, y)
+}
+
+// first returns the first value at a path, descending into lists
+func first(v interface{}, path []string) interface{} {
+	rv := indirect(v)
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for idx := 0; idx < rv.Len(); idx++ {
+			if f := first(rv.Index(idx).Interface(), path); f != nil {
+				return f
+			}
+		}
+		return nil
+	case reflect.Map:
+		if len(path) == 0 || rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		el := rv.MapIndex(reflect.ValueOf(path[0]).Convert(rv.Type().Key()))
+		if !el.IsValid() {
+			return nil
+		}
+		return first(el.Interface(), path[1:])
+	}
+	if len(path) > 0 {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// indirect follows pointers and interfaces, returning the zero Value for nil
+func indirect(v interface{}) reflect.Value {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+type byOrder struct {
+	o    Order
+	rows []Row
+}
+
+func (s byOrder) Len() int      { return len(s.rows) }
+func (s byOrder) Swap(i, j int) { s.rows[i], s.rows[j] = s.rows[j], s.rows[i] }
+func (s byOrder) Less(i, j int) bool {
+	return s.o.compare(s.rows[i].keys, s.rows[j].keys) < 0
+}
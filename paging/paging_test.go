@@ -0,0 +1,148 @@
+package paging
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var t0 = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func instance(id string, launched time.Time, name interface{}) Row {
+	return Row{ID: id, Doc: map[string]interface{}{
+		"InstanceId": id,
+		"LaunchTime": &launched,
+		"Tags":       []interface{}{map[string]interface{}{"Name": name}},
+	}}
+}
+
+func ids(rows []Row) []string {
+	var ids []string
+	for _, r := range rows {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func Test_ParseOrder(t *testing.T) {
+	o, err := ParseOrder("LaunchTime, -Tags.Name,+State.Code")
+	assert.Nil(t, err)
+	assert.Equal(t, []key{
+		{path: []string{"LaunchTime"}},
+		{path: []string{"Tags", "Name"}, desc: true},
+		{path: []string{"State", "Code"}},
+	}, o.keys)
+	assert.True(t, o.ByFields())
+
+	o, err = ParseOrder("")
+	assert.Nil(t, err)
+	assert.False(t, o.ByFields())
+
+	for _, spec := range []string{",", "-", "Tags..Name", "LaunchTime,"} {
+		_, err := ParseOrder(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func Test_Sort(t *testing.T) {
+	rows := []Row{
+		instance("i-3", t0, "b"),
+		instance("i-1", t0.Add(time.Hour), "a"),
+		instance("i-2", t0, "c"),
+		instance("i-0", t0, nil),
+		{ID: "i-4"},
+	}
+
+	o, _ := ParseOrder("LaunchTime,-Tags.Name")
+	o.Sort(rows)
+	assert.Equal(t, []string{"i-2", "i-3", "i-0", "i-1", "i-4"}, ids(rows))
+
+	o, _ = ParseOrder("")
+	o.Sort(rows)
+	assert.Equal(t, []string{"i-0", "i-1", "i-2", "i-3", "i-4"}, ids(rows))
+}
+
+func Test_Sort_Types(t *testing.T) {
+	rows := []Row{
+		{ID: "a", Doc: map[string]interface{}{"v": "b"}},
+		{ID: "b", Doc: map[string]interface{}{"v": 10}},
+		{ID: "c", Doc: map[string]interface{}{"v": 9.5}},
+		{ID: "d", Doc: map[string]interface{}{"v": "A"}},
+		{ID: "e", Doc: map[string]interface{}{"v": true}},
+		{ID: "f", Doc: map[string]interface{}{"v": "2017-03-01T12:00:00Z"}},
+		{ID: "g", Doc: map[string]interface{}{"v": map[string]interface{}{}}},
+		{ID: "h", Doc: map[string]interface{}{"v": "a"}},
+	}
+	o, _ := ParseOrder("v")
+	o.Sort(rows)
+	assert.Equal(t, []string{"e", "c", "b", "f", "d", "h", "a", "g"}, ids(rows))
+}
+
+func Test_Cursor(t *testing.T) {
+	o, _ := ParseOrder("-LaunchTime")
+	// crawl lists instances launched the given number of minutes after t0
+	crawl := func(launched map[string]int) []Row {
+		var rows []Row
+		for id, minutes := range launched {
+			rows = append(rows, instance(id, t0.Add(time.Duration(minutes)*time.Minute), id))
+		}
+		o.Sort(rows)
+		return rows
+	}
+
+	rows := crawl(map[string]int{"i-0": 0, "i-1": 1, "i-2": 2, "i-3": 3, "i-4": 4})
+	assert.Equal(t, []string{"i-4", "i-3", "i-2", "i-1", "i-0"}, ids(rows))
+	cursor := o.Cursor(rows[1])
+
+	next, err := o.After(rows, cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"i-2", "i-1", "i-0"}, ids(next))
+
+	// i-3 is gone and i-5 is new in the next crawl, paging on after i-3
+	// still continues with i-2
+	rows = crawl(map[string]int{"i-0": 0, "i-1": 1, "i-2": 2, "i-4": 4, "i-5": 5})
+	next, err = o.After(rows, cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"i-2", "i-1", "i-0"}, ids(next))
+
+	next, err = o.After(rows, o.Cursor(rows[len(rows)-1]))
+	assert.Nil(t, err)
+	assert.Empty(t, next)
+}
+
+func Test_Cursor_Regions(t *testing.T) {
+	o, _ := ParseOrder("")
+	rows := []Row{
+		{ID: "i-1", Region: "us-east-1"},
+		{ID: "i-0", Region: "eu-west-1"},
+		{ID: "i-0", Region: "us-east-1"},
+	}
+	o.Sort(rows)
+
+	next, err := o.After(rows, o.Cursor(rows[0]))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"i-0", "i-1"}, ids(next))
+	assert.Equal(t, "us-east-1", next[0].Region)
+}
+
+func Test_Cursor_Invalid(t *testing.T) {
+	o, _ := ParseOrder("LaunchTime")
+	rows := []Row{instance("i-0", t0, "a")}
+	o.Sort(rows)
+
+	other, _ := ParseOrder("-LaunchTime")
+	for _, cursor := range []string{
+		"not a cursor!",
+		base64.RawURLEncoding.EncodeToString([]byte("{")),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"s":"LaunchTime","k":["2017-03-01T12:00:00Z"]}`)),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"s":"LaunchTime","k":[null,1,"",""]}`)),
+		other.Cursor(rows[0]),
+	} {
+		_, err := o.After(rows, cursor)
+		assert.Equal(t, ErrBadCursor, err, cursor)
+		assert.Equal(t, ErrBadCursor, o.Check(cursor), cursor)
+	}
+	assert.Nil(t, o.Check(o.Cursor(rows[0])))
+}
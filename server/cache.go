@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alde/melkor"
+)
+
+// validators identify the version of a response, which changes whenever a
+// crawl completes. The ETag is weak as the same items may be encoded in
+// several ways.
+type validators struct {
+	etag         string
+	lastModified time.Time
+	maxAge       int
+}
+
+// checkModified computes the validators of a response to a request for the
+// resources of a snapshot, in a format. If the client already has the
+// response, a 304 is written and done is true.
+//
+// The validators are those of the crawl the snapshot is from, so that a point
+// in time in the past keeps the Last-Modified of the crawl it was answered
+// with. The max-age runs until the crawl after that one, which has usually
+// happened already.
+func (h *Handler) checkModified(w http.ResponseWriter, r *http.Request, f *format, s *melkor.Snapshot) (v *validators, done bool) {
+	last := s.CrawledAt()
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d\n%s\n%s\n%s", last.UnixNano(), f.name, r.URL.Path, r.URL.Query().Encode())
+	v = &validators{
+		etag:         fmt.Sprintf(`W/"%d-%x"`, s.Generation(), hash.Sum64()),
+		lastModified: last,
+	}
+	if !last.IsZero() {
+		next := last.Add(time.Duration(h.config.CrawlInterval) * time.Second)
+		if wait := next.Sub(time.Now()); wait > 0 {
+			v.maxAge = int(wait.Seconds())
+		}
+	}
+	if !v.notModified(r) {
+		return v, false
+	}
+	v.set(w)
+	w.WriteHeader(http.StatusNotModified)
+	return v, true
+}
+
+// notModified checks the conditional headers of a request. If-Modified-Since
+// is only looked at without If-None-Match. A * is not taken to match, as the
+// items asked for might not exist.
+func (v *validators) notModified(r *http.Request) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, etag := range strings.Split(inm, ",") {
+			etag = strings.TrimSpace(etag)
+			if strings.TrimPrefix(etag, "W/") == strings.TrimPrefix(v.etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || v.lastModified.IsZero() {
+		return false
+	}
+	return !v.lastModified.Truncate(time.Second).After(ims)
+}
+
+// set sets the validators and caching headers of a response. It does nothing
+// to responses without validators.
+func (v *validators) set(w http.ResponseWriter) {
+	if v == nil {
+		return
+	}
+	w.Header().Set("ETag", v.etag)
+	vary(w.Header(), "Accept")
+	if !v.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", v.maxAge))
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/fixtures"
+	"github.com/alde/melkor/mock"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupCached serves a crawler last crawled at a given time, crawling every ten
+// minutes. Its generation can be changed to simulate a crawl.
+func setupCached(crawled time.Time, generation *uint64) *mux.Router {
+	mc := &mock.InstanceCrawler{}
+	mc.SnapshotFn = func() *melkor.Snapshot {
+		data := fixtures.FullCrawlerData(3)
+		items := make([]melkor.Item, len(data))
+		for idx, d := range data {
+			items[idx] = melkor.Item{ID: d["InstanceId"].(string), Value: d}
+		}
+		return melkor.NewSnapshot(crawled, items, mock.ExpandData).WithGeneration(*generation)
+	}
+	return NewRouter(&config.Config{CrawlInterval: 600}, melkor.Crawlers{mc.Resource(): mc})
+}
+
+func get(m http.Handler, url string, header map[string]string) *httptest.ResponseRecorder {
+	wr := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", url, nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	m.ServeHTTP(wr, r)
+	return wr
+}
+
+func Test_Cache_Headers(t *testing.T) {
+	crawled := time.Now().Add(-4 * time.Minute)
+	generation := uint64(1)
+	m := setupCached(crawled, &generation)
+
+	for _, url := range []string{"/api/v1/aws/mock", "/api/v2/aws/mock", "/api/v1/aws/mock/i-1"} {
+		wr := get(m, url, nil)
+
+		assert.Equal(t, http.StatusOK, wr.Code, url)
+		assert.Regexp(t, `^W/"1-[0-9a-f]+"$`, wr.Header().Get("ETag"), url)
+		assert.Equal(t, crawled.UTC().Format(http.TimeFormat), wr.Header().Get("Last-Modified"), url)
+		assert.Regexp(t, `^max-age=(35[0-9]|360)$`, wr.Header().Get("Cache-Control"), url)
+	}
+}
+
+func Test_Cache_IfNoneMatch(t *testing.T) {
+	generation := uint64(1)
+	m := setupCached(time.Now(), &generation)
+
+	wr := get(m, "/api/v1/aws/mock?_expand=true", nil)
+	etag := wr.Header().Get("ETag")
+
+	wr = get(m, "/api/v1/aws/mock?_expand=true", map[string]string{"If-None-Match": `"other", ` + etag})
+	assert.Equal(t, http.StatusNotModified, wr.Code)
+	assert.Empty(t, wr.Body.String())
+	assert.Equal(t, etag, wr.Header().Get("ETag"))
+
+	wr = get(m, "/api/v1/aws/mock", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, wr.Code, "the query is part of the etag")
+
+	generation++
+	wr = get(m, "/api/v1/aws/mock?_expand=true", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, wr.Code, "a crawl changes the etag")
+	assert.NotEqual(t, etag, wr.Header().Get("ETag"))
+}
+
+func Test_Cache_IfModifiedSince(t *testing.T) {
+	crawled := time.Date(2017, 3, 1, 14, 0, 0, 500, time.UTC)
+	generation := uint64(1)
+	m := setupCached(crawled, &generation)
+
+	wr := get(m, "/api/v1/aws/mock/i-0", map[string]string{"If-Modified-Since": "Wed, 01 Mar 2017 14:00:00 GMT"})
+	assert.Equal(t, http.StatusNotModified, wr.Code)
+	assert.Equal(t, "max-age=0", wr.Header().Get("Cache-Control"), "the next crawl is overdue")
+
+	wr = get(m, "/api/v1/aws/mock/i-0", map[string]string{"If-Modified-Since": "Wed, 01 Mar 2017 13:59:59 GMT"})
+	assert.Equal(t, http.StatusOK, wr.Code)
+
+	wr = get(m, "/api/v1/aws/mock/i-0", map[string]string{
+		"If-Modified-Since": "Wed, 01 Mar 2017 14:00:00 GMT",
+		"If-None-Match":     `W/"0-0"`,
+	})
+	assert.Equal(t, http.StatusOK, wr.Code, "If-None-Match takes precedence")
+}
+
+func Test_Cache_Errors(t *testing.T) {
+	generation := uint64(1)
+	m := setupCached(time.Now(), &generation)
+
+	for _, url := range []string{"/api/v1/aws/mock/i-9", "/api/v1/aws/mock?_limit=x", "/api/v1/aws/unknown"} {
+		wr := get(m, url, map[string]string{"If-None-Match": "*"})
+		assert.NotEqual(t, http.StatusNotModified, wr.Code, url)
+		wr = get(m, url, nil)
+		assert.NotEqual(t, http.StatusOK, wr.Code, url)
+		assert.Empty(t, wr.Header().Get("ETag"), url)
+	}
+}
+
+func Test_Cache_BadParams(t *testing.T) {
+	generation := uint64(1)
+	m := setupCached(time.Date(2017, 3, 1, 14, 0, 0, 0, time.UTC), &generation)
+
+	for _, url := range []string{
+		"/api/v1/aws/mock?_filter=(Team:a",
+		"/api/v1/aws/mock?_sort=-",
+		"/api/v2/aws/mock?_cursor=x",
+		"/api/v2/aws/mock?_limit=x",
+	} {
+		wr := get(m, url, map[string]string{"If-Modified-Since": "Wed, 01 Mar 2017 15:00:00 GMT"})
+		assert.Equal(t, http.StatusBadRequest, wr.Code, url)
+	}
+}
+
+func Test_Cache_At(t *testing.T) {
+	then := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := time.Now().Add(-time.Minute)
+	h := &melkor.History{}
+	for _, crawled := range []time.Time{then, now} {
+		h.Record(melkor.Scope{}, melkor.NewSnapshot(crawled, []melkor.Item{
+			{ID: "i-0", Value: map[string]interface{}{"InstanceId": "i-0", "LaunchTime": crawled.String()}},
+		}, mock.ExpandData))
+	}
+	mc := &mock.InstanceCrawler{
+		SnapshotFn: func() *melkor.Snapshot { return h.At(now).WithGeneration(2) },
+		HistoryFn:  func() *melkor.History { return h },
+	}
+	m := NewRouter(&config.Config{CrawlInterval: 600}, melkor.Crawlers{mc.Resource(): mc})
+
+	for _, url := range []string{"/api/v1/aws/mock?_at=2017-03-01T13:00:00Z", "/api/v1/aws/mock/i-0?_at=2017-03-01T13:00:00Z"} {
+		wr := get(m, url, nil)
+		assert.Equal(t, http.StatusOK, wr.Code, url)
+		assert.Equal(t, "Wed, 01 Mar 2017 12:00:00 GMT", wr.Header().Get("Last-Modified"), url)
+		assert.Equal(t, "max-age=0", wr.Header().Get("Cache-Control"), url)
+		etag := wr.Header().Get("ETag")
+
+		wr = get(m, url, map[string]string{"If-Modified-Since": "Wed, 01 Mar 2017 12:00:00 GMT"})
+		assert.Equal(t, http.StatusNotModified, wr.Code, url)
+		wr = get(m, url, map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, wr.Code, url)
+	}
+
+	wr := get(m, "/api/v1/aws/mock", map[string]string{"If-Modified-Since": "Wed, 01 Mar 2017 12:00:00 GMT"})
+	assert.Equal(t, http.StatusOK, wr.Code, "the current snapshot was crawled since")
+}
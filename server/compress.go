@@ -0,0 +1,126 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// encodings are the content codings responses can be compressed with, in
+// order of preference
+var encodings = []string{"gzip", "deflate"}
+
+// compressor is implemented by both gzip and zlib writers
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compress compresses responses with gzip or deflate if the client accepts
+// either. Responses without a body, already encoded ones such as metrics, and
+// event streams are left alone.
+func compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vary(w.Header(), "Accept-Encoding")
+		encoding := acceptEncoding(r)
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptEncoding picks the content coding of the highest quality accepted by
+// a request, preferring gzip on a tie, or returns an empty string if none is
+func acceptEncoding(r *http.Request) string {
+	best, bestQ := len(encodings), 0.0
+	for _, accepted := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(accepted, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				var err error
+				if q, err = strconv.ParseFloat(p[2:], 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		for idx, enc := range encodings {
+			if name != enc && name != "*" {
+				continue
+			}
+			if q > bestQ || q == bestQ && q > 0 && idx < best {
+				best, bestQ = idx, q
+			}
+			break
+		}
+	}
+	if best == len(encodings) {
+		return ""
+	}
+	return encodings[best]
+}
+
+// A compressWriter compresses what is written to it, deciding whether to do
+// so once the header is written
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	w           compressor
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	h := cw.Header()
+	if status != http.StatusNoContent && status != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" && !strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if cw.encoding == "gzip" {
+			cw.w = gzip.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.w = zlib.NewWriter(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.w == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.w.Write(b)
+}
+
+// Flush sends what has been compressed so far to the client
+func (cw *compressWriter) Flush() {
+	if cw.w != nil {
+		cw.w.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close writes the end of the compressed stream, if any
+func (cw *compressWriter) close() error {
+	if cw.w == nil {
+		return nil
+	}
+	return cw.w.Close()
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alde/melkor/fixtures"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var acceptEncodingTests = map[string]string{
+	"":                       "",
+	"identity":               "",
+	"gzip":                   "gzip",
+	"deflate, gzip":          "gzip",
+	"gzip;q=0.5, deflate":    "deflate",
+	"gzip;q=0, deflate;q=0":  "",
+	"*":                      "gzip",
+	"br, deflate;q=0.1":      "deflate",
+	"GZIP;q=1.0, compress":   "gzip",
+	"gzip;q=zero, deflate=1": "",
+}
+
+func Test_acceptEncoding(t *testing.T) {
+	for header, expected := range acceptEncodingTests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", header)
+		assert.Equal(t, expected, acceptEncoding(r), header)
+	}
+}
+
+func Test_Compress(t *testing.T) {
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(3), time.Now(), 1)
+	plain := get(m, "/api/v1/aws/mock?_expand=true", nil)
+	assert.Empty(t, plain.Header().Get("Content-Encoding"))
+	assert.Equal(t, []string{"Accept-Encoding", "Accept"}, plain.Header()["Vary"])
+
+	for encoding, reader := range map[string]func(io.Reader) (io.Reader, error){
+		"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"deflate": func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+	} {
+		wr := get(m, "/api/v1/aws/mock?_expand=true", map[string]string{"Accept-Encoding": encoding})
+
+		assert.Equal(t, http.StatusOK, wr.Code)
+		assert.Equal(t, encoding, wr.Header().Get("Content-Encoding"))
+		assert.True(t, wr.Body.Len() < plain.Body.Len(), encoding)
+		r, err := reader(wr.Body)
+		if assert.Nil(t, err, encoding) {
+			b, err := ioutil.ReadAll(r)
+			assert.Nil(t, err)
+			assert.Equal(t, plain.Body.String(), string(b), encoding)
+		}
+	}
+}
+
+func Test_Compress_NotModified(t *testing.T) {
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(3), time.Now(), 1)
+	etag := get(m, "/api/v1/aws/mock", nil).Header().Get("ETag")
+
+	wr := get(m, "/api/v1/aws/mock", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, wr.Code)
+	assert.Empty(t, wr.Header().Get("Content-Encoding"))
+	assert.Equal(t, 0, wr.Body.Len())
+}
+
+func Test_Compress_Passthrough(t *testing.T) {
+	for contentType, encoding := range map[string]string{
+		"text/event-stream": "",
+		"application/json":  "br",
+	} {
+		h := compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			if encoding != "" {
+				w.Header().Set("Content-Encoding", encoding)
+			}
+			io.WriteString(w, "data")
+			w.(http.Flusher).Flush()
+		}))
+		wr := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(wr, r)
+
+		assert.Equal(t, encoding, wr.Header().Get("Content-Encoding"), contentType)
+		assert.Equal(t, "data", wr.Body.String(), contentType)
+		assert.True(t, wr.Flushed, contentType)
+	}
+}
@@ -0,0 +1,336 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alde/melkor/fields"
+
+	"github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// A format is a way of encoding responses, picked by the _format parameter or
+// by one of its media types in the Accept header
+type format struct {
+	name        string
+	contentType string
+	mediaTypes  []string
+	// write encodes a response, given both as a whole and as the items it
+	// lists. The selection, if any, tells which fields the items hold.
+	write func(w io.Writer, data interface{}, items *stream, sel *fields.Selection) error
+}
+
+var (
+	formatJSON = &format{
+		name:        "json",
+		contentType: contentTypeJSON,
+		mediaTypes:  []string{"application/json", "text/json"},
+		write:       writeJSONFormat,
+	}
+	formatCSV = &format{
+		name:        "csv",
+		contentType: "text/csv; charset=UTF-8",
+		mediaTypes:  []string{"text/csv"},
+		write:       writeCSV,
+	}
+	formatNDJSON = &format{
+		name:        "ndjson",
+		contentType: "application/x-ndjson",
+		mediaTypes:  []string{"application/x-ndjson", "application/ndjson", "application/jsonlines"},
+		write:       writeNDJSON,
+	}
+	formatYAML = &format{
+		name:        "yaml",
+		contentType: "application/x-yaml; charset=UTF-8",
+		mediaTypes:  []string{"application/x-yaml", "application/yaml", "text/yaml", "text/x-yaml"},
+		write:       writeYAML,
+	}
+	// formats are in order of preference, for wildcards in the Accept header
+	formats = []*format{formatJSON, formatCSV, formatNDJSON, formatYAML}
+)
+
+// negotiate picks the format of a response. The _format parameter takes
+// precedence over the Accept header, of which the acceptable media type of the
+// highest quality is used. JSON is used if neither asks for a known format, so
+// that clients sending an Accept header of their own keep getting JSON.
+func negotiate(r *http.Request) (*format, *apiError) {
+	if name := r.FormValue("_format"); name != "" {
+		for _, f := range formats {
+			if strings.EqualFold(f.name, name) {
+				return f, nil
+			}
+		}
+		return nil, badParam(codeInvalidParameter, "_format", "Bad format parameter")
+	}
+	best, bestQ := formatJSON, 0.0
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if f := formatOf(mediaType); f != nil && q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	return best, nil
+}
+
+// formatOf looks up the format of a media type, which may be a wildcard
+func formatOf(mediaType string) *format {
+	for _, f := range formats {
+		for _, mt := range f.mediaTypes {
+			if mt == mediaType || mediaType == "*/*" ||
+				strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(mediaType, "*")) {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// writeFormat writes a response in a format. Errors are always written as
+// JSON, using writeError or writeAPIError. Failing to write, typically because
+// the client went away, is only logged as the status has been sent already.
+func writeFormat(f *format, status int, data interface{}, items *stream, sel *fields.Selection, w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", f.contentType)
+	vary(w.Header(), "Accept")
+	w.WriteHeader(status)
+	err := f.write(w, data, items, sel)
+	if err != nil {
+		logrus.WithError(err).WithField("format", f.name).Debug("Response not written")
+	}
+	return err
+}
+
+// vary adds a request header to the Vary header, unless it is there already
+func vary(h http.Header, name string) {
+	for _, v := range h["Vary"] {
+		for _, n := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(n), name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+// writeJSONFormat writes the response as JSON, streaming any items as they
+// are encoded
+func writeJSONFormat(w io.Writer, data interface{}, items *stream, sel *fields.Selection) error {
+	switch x := data.(type) {
+	case jsonStreamer:
+		if err := x.writeJSON(w); err != nil {
+			return err
+		}
+	case json.RawMessage:
+		if _, err := w.Write(x); err != nil {
+			return err
+		}
+	default:
+		return json.NewEncoder(w).Encode(data)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeNDJSON writes the items as one JSON document per line, as they are
+// encoded
+func writeNDJSON(w io.Writer, data interface{}, items *stream, sel *fields.Selection) error {
+	return items.each(func(item interface{}) error {
+		b, err := encode(item)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "\n")
+		return err
+	})
+}
+
+// writeYAML writes the whole response, keyed like it is in JSON
+func writeYAML(w io.Writer, data interface{}, items *stream, sel *fields.Selection) error {
+	p, err := plain(data)
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// writeCSV writes the items as rows, with a header naming the dot separated
+// path of each column. Ids are written in a single id column. As the columns
+// are only known once all items are seen, the rows are flattened up front.
+func writeCSV(w io.Writer, data interface{}, items *stream, sel *fields.Selection) error {
+	var rows []map[string][]string
+	err := items.each(func(item interface{}) error {
+		row := make(map[string][]string)
+		rows = append(rows, row)
+		if id, ok := item.(string); ok {
+			row["id"] = []string{id}
+			return nil
+		}
+		p, err := plain(item)
+		if err != nil {
+			return err
+		}
+		flatten("", p, row)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cols := columns(rows, sel)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+	record := make([]string, len(cols))
+	for _, row := range rows {
+		for idx, col := range cols {
+			record[idx] = strings.Join(row[col], ";")
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// plain turns a value into the maps, slices, strings, int64, float64 and bool
+// it is encoded as in JSON, so that all formats name and write fields alike
+func plain(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var p interface{}
+	if err := dec.Decode(&p); err != nil {
+		return nil, err
+	}
+	return numbers(p), nil
+}
+
+// numbers replaces json.Numbers by int64 if they are whole, or float64
+func numbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, el := range x {
+			x[k] = numbers(el)
+		}
+	case []interface{}:
+		for idx, el := range x {
+			x[idx] = numbers(el)
+		}
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	}
+	return v
+}
+
+// flatten collects the values of a plain value by dot separated path. Lists
+// are descended into without adding to the path, like filters do, so that
+// the values of all elements end up in the same column.
+func flatten(path string, v interface{}, out map[string][]string) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, el := range x {
+			if path != "" {
+				k = path + "." + k
+			}
+			flatten(k, el, out)
+		}
+	case []interface{}:
+		for _, el := range x {
+			flatten(path, el, out)
+		}
+	case nil:
+	case string:
+		out[path] = append(out[path], x)
+	default:
+		out[path] = append(out[path], fmt.Sprint(x))
+	}
+}
+
+// columns lists the paths found in any of the rows. With fields selected, they
+// come in the order of the first field they fall under, and alphabetically
+// otherwise.
+func columns(rows []map[string][]string, sel *fields.Selection) []string {
+	seen := make(map[string]bool)
+	var cols []string
+	for _, row := range rows {
+		for col := range row {
+			if !seen[col] {
+				seen[col] = true
+				cols = append(cols, col)
+			}
+		}
+	}
+	sort.Strings(cols)
+	if sel != nil {
+		sort.Stable(byField{cols, sel.Fields()})
+	}
+	return cols
+}
+
+// byField orders columns by the first field they fall under. A field falls
+// under another if it starts with the segments of the other before any *.
+type byField struct {
+	cols   []string
+	fields []string
+}
+
+func (s byField) rank(col string) int {
+	segs := strings.Split(col, ".")
+	for idx, f := range s.fields {
+		prefix := strings.Split(f, ".")
+		for n, seg := range prefix {
+			if seg == "*" {
+				prefix = prefix[:n]
+				break
+			}
+		}
+		if len(prefix) > len(segs) {
+			continue
+		}
+		match := true
+		for n, seg := range prefix {
+			match = match && segs[n] == seg
+		}
+		if match {
+			return idx
+		}
+	}
+	return len(s.fields)
+}
+
+func (s byField) Len() int           { return len(s.cols) }
+func (s byField) Swap(i, j int)      { s.cols[i], s.cols[j] = s.cols[j], s.cols[i] }
+func (s byField) Less(i, j int) bool { return s.rank(s.cols[i]) < s.rank(s.cols[j]) }
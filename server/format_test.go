@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alde/melkor/fixtures"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var negotiateTests = []struct {
+	query    string
+	accept   string
+	expected string
+}{
+	{"", "", "json"},
+	{"", "text/csv", "csv"},
+	{"", "text/html,application/xhtml+xml,*/*;q=0.8", "json"},
+	{"", "application/json;q=0.5, application/x-yaml", "yaml"},
+	{"", "application/ndjson", "ndjson"},
+	{"", "text/*", "json"},
+	{"", "text/plain", "json"},
+	{"", "text/csv;q=0, application/x-ndjson;q=0.1", "ndjson"},
+	{"_format=CSV", "application/x-yaml", "csv"},
+}
+
+func Test_negotiate(t *testing.T) {
+	for _, tt := range negotiateTests {
+		r, _ := http.NewRequest("GET", "/api/v1/aws/mock?"+tt.query, nil)
+		r.Header.Set("Accept", tt.accept)
+
+		f, err := negotiate(r)
+		if assert.Nil(t, err, tt.accept) {
+			assert.Equal(t, tt.expected, f.name, tt.accept)
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_format=xml", nil)
+	_, err := negotiate(r)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "_format", err.Param)
+	}
+}
+
+func Test_Format_CSV(t *testing.T) {
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(2), time.Now(), 1)
+
+	wr := get(m, "/api/v1/aws/mock?_format=csv&_fields=InstanceId,ip=NetworkInterfaces.PrivateIpAddress,Monitoring.State,BlockDeviceMappings.*.Ebs.VolumeId", nil)
+	assert.Equal(t, http.StatusOK, wr.Code)
+	assert.Equal(t, "text/csv; charset=UTF-8", wr.Header().Get("Content-Type"))
+	assert.Equal(t, "InstanceId,ip,Monitoring.State,BlockDeviceMappings.Ebs.VolumeId\n"+
+		"i-0,10.20.30.0,disabled,vol-0\n"+
+		"i-1,10.20.30.1,disabled,vol-1\n", wr.Body.String())
+
+	wr = get(m, "/api/v2/aws/mock?_limit=1", map[string]string{"Accept": "text/csv"})
+	assert.Equal(t, "id\ni-0\n", wr.Body.String())
+
+	wr = get(m, "/api/v1/aws/mock/i-1?_fields=Tags.Team,State", map[string]string{"Accept": "text/csv"})
+	assert.Equal(t, http.StatusOK, wr.Code)
+	assert.Equal(t, "Tags.Team,State.Code,State.Name\nteam1,16,running\n", wr.Body.String())
+}
+
+func Test_Format_NDJSON(t *testing.T) {
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(3), time.Now(), 1)
+
+	wr := get(m, "/api/v2/aws/mock?_fields=InstanceId", map[string]string{"Accept": "application/x-ndjson"})
+	assert.Equal(t, http.StatusOK, wr.Code)
+	assert.Equal(t, "application/x-ndjson", wr.Header().Get("Content-Type"))
+	assert.Equal(t, `{"InstanceId":"i-0"}`+"\n"+`{"InstanceId":"i-1"}`+"\n"+`{"InstanceId":"i-2"}`+"\n", wr.Body.String())
+}
+
+func Test_Format_YAML(t *testing.T) {
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(2), time.Time{}, 1)
+
+	wr := get(m, "/api/v2/aws/mock?_limit=1", map[string]string{"Accept": "application/yaml"})
+	assert.Equal(t, http.StatusOK, wr.Code)
+	assert.Equal(t, "application/x-yaml; charset=UTF-8", wr.Header().Get("Content-Type"))
+	body := wr.Body.String()
+	for _, line := range []string{"count: 1", "crawled_at: null", "generation: 1", "items:", "- i-0", "total: 2"} {
+		assert.Contains(t, body, line+"\n")
+	}
+	assert.Contains(t, body, "next: /api/v2/aws/mock?")
+
+	wr = get(m, "/api/v1/aws/mock/i-0?_format=yaml&_fields=InstanceId,AmiLaunchIndex", nil)
+	assert.Equal(t, "AmiLaunchIndex: 0\nInstanceId: i-0\n", wr.Body.String())
+}
+
+func Test_Format_Errors(t *testing.T) {
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(2), time.Now(), 1)
+
+	wr := get(m, "/api/v1/aws/mock?_format=xml", map[string]string{"Accept": "text/csv"})
+	assert.Equal(t, http.StatusBadRequest, wr.Code)
+	assert.JSONEq(t, `{"error": "Bad format parameter"}`, wr.Body.String())
+
+	wr = get(m, "/api/v2/aws/mock?_format=xml", nil)
+	assert.Equal(t, http.StatusBadRequest, wr.Code)
+	assert.JSONEq(t, `{"error": {"code": "invalid_parameter", "message": "Bad format parameter", "param": "_format"}}`, wr.Body.String())
+
+	wr = get(m, "/api/v1/aws/mock/i-9", map[string]string{"Accept": "text/csv"})
+	assert.Equal(t, http.StatusNotFound, wr.Code)
+	assert.True(t, strings.HasPrefix(wr.Header().Get("Content-Type"), "application/json"))
+}
+
+func Test_Format_ETag(t *testing.T) {
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(2), time.Now(), 1)
+
+	json := get(m, "/api/v1/aws/mock", nil)
+	csv := get(m, "/api/v1/aws/mock", map[string]string{"Accept": "text/csv"})
+	assert.NotEqual(t, json.Header().Get("ETag"), csv.Header().Get("ETag"))
+	assert.Contains(t, csv.Header()["Vary"], "Accept")
+
+	wr := get(m, "/api/v1/aws/mock", map[string]string{"Accept": "text/csv", "If-None-Match": json.Header().Get("ETag")})
+	assert.Equal(t, http.StatusOK, wr.Code)
+}
@@ -1,10 +1,20 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/alde/melkor"
 	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/fields"
+	"github.com/alde/melkor/filter"
+	"github.com/alde/melkor/paging"
 	"github.com/alde/melkor/version"
 
 	"github.com/gorilla/mux"
@@ -15,53 +25,337 @@ import (
 type Handler struct {
 	config   *config.Config
 	crawlers melkor.Crawlers
+	hub      *hub
 }
 
-// NewHandler createss a new HTTP handler
+// NewHandler createss a new HTTP handler, watching the crawlers for changes
 func NewHandler(cfg *config.Config, crawlers melkor.Crawlers) *Handler {
-	return &Handler{config: cfg, crawlers: crawlers}
+	h := &Handler{config: cfg, crawlers: crawlers, hub: newHub()}
+	for _, c := range crawlers {
+		resource := c.Resource()
+		c.OnChange(func(cs melkor.Changeset) {
+			h.hub.publish(resource, cs)
+		})
+	}
+	return h
+}
+
+// crawler looks up the crawler of the requested resource, or returns nil if
+// there is none
+func (h *Handler) crawler(vars map[string]string) melkor.Crawler {
+	resource := vars["resource"]
+	crawler := h.crawlers.Get(resource)
+	if crawler == nil {
+		logrus.WithField("resource", resource).Debug("Not Found")
+	}
+	return crawler
+}
+
+// snapshot looks up the snapshot of the requested resource, narrowed down to
+// the requested account and region if any. The current snapshot is used unless
+// a point in time is given. Accounts and regions which are crawled but have
+// nothing crawled yet are empty, like an uncrawled resource is. It returns nil
+// if any of them is unknown.
+func (h *Handler) snapshot(vars map[string]string, at time.Time) *melkor.Snapshot {
+	resource := vars["resource"]
+	crawler := h.crawler(vars)
+	if crawler == nil {
+		return nil
+	}
+	snapshot := crawler.Snapshot()
+	if !at.IsZero() {
+		snapshot = crawler.History().At(at)
+		if snapshot == nil {
+			logrus.WithFields(logrus.Fields{"resource": resource, "at": at}).Debug("No History")
+			return nil
+		}
+	}
+	if account, ok := vars["account"]; ok {
+		narrowed := snapshot.Account(account)
+		if narrowed == nil && h.crawlsAccount(account) {
+			narrowed = empty(snapshot)
+		}
+		if narrowed == nil {
+			logrus.WithFields(logrus.Fields{"resource": resource, "account": account}).Debug("Account Not Found")
+			return nil
+		}
+		snapshot = narrowed
+	}
+	if region, ok := vars["region"]; ok {
+		narrowed := snapshot.Region(region)
+		if narrowed == nil && h.crawlsRegion(region) {
+			narrowed = empty(snapshot)
+		}
+		if narrowed == nil {
+			logrus.WithFields(logrus.Fields{"resource": resource, "region": region}).Debug("Region Not Found")
+		}
+		snapshot = narrowed
+	}
+	return snapshot
+}
+
+// empty returns an empty snapshot of the same generation, for an account or
+// region which is crawled but has nothing crawled yet
+func empty(s *melkor.Snapshot) *melkor.Snapshot {
+	return (&melkor.Snapshot{}).WithGeneration(s.Generation())
+}
+
+// crawlsAccount checks whether an account is configured to be crawled
+func (h *Handler) crawlsAccount(account string) bool {
+	for _, a := range h.config.Accounts {
+		if a.ID() == account {
+			return true
+		}
+	}
+	return false
+}
+
+// crawlsRegion checks whether a region is configured to be crawled
+func (h *Handler) crawlsRegion(region string) bool {
+	for _, r := range h.config.Regions() {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// listParams are the parameters understood by ListAWSResources
+var listParams = []string{"_at", "_cursor", "_expand", "_fields", "_filter", "_format", "_limit", "_sort"}
+
+// A listing is a page of the requested resources, filtered and sorted
+type listing struct {
+	snapshot *melkor.Snapshot
+	rows     []paging.Row
+	// total counts the resources matching the filter, on all pages
+	total int
+	// next is the cursor of the page after this one, if there is one
+	next   string
+	expand bool
+	sel    *fields.Selection
+}
+
+// items streams the resources listed, as expanded and projected if requested
+// or as their ids otherwise. Expanded resources are written as the JSON the
+// snapshot encoded them as once, rather than encoded anew.
+func (l *listing) items(ctx context.Context) *stream {
+	return &stream{ctx: ctx, n: len(l.rows), item: func(idx int) interface{} {
+		row := l.rows[idx]
+		switch {
+		case l.sel != nil:
+			return l.sel.Apply(l.doc(row))
+		case !l.expand:
+			return row.ID
+		}
+		if b := l.snapshot.JSON(row.Index); b != nil {
+			return json.RawMessage(b)
+		}
+		return l.doc(row)
+	}}
+}
+
+// doc returns the expanded resource of a row, expanding it if it was not
+// needed to list it
+func (l *listing) doc(row paging.Row) map[string]interface{} {
+	if row.Doc != nil {
+		return row.Doc
+	}
+	return l.snapshot.Expand(row.Index)
+}
+
+// A listQuery is a request for a list of resources, as parsed from its
+// parameters
+type listQuery struct {
+	limit  int
+	at     time.Time
+	sel    *fields.Selection
+	order  paging.Order
+	filter *filter.Filter
+	cursor string
+	expand bool
+}
+
+// parseList parses and checks all parameters of a list request, so that bad
+// ones are rejected before looking at any resources
+func parseList(r *http.Request) (*listQuery, *apiError) {
+	if param := unknownParam(r, listParams); param != "" {
+		return nil, badParam(codeUnknownParameter, param, "Unknown parameter "+param)
+	}
+	q := &listQuery{cursor: r.FormValue("_cursor")}
+	var err error
+	q.limit, err = parseLimit(r)
+	if err != nil {
+		return nil, badParam(codeInvalidParameter, "_limit", "Bad limit parameter")
+	}
+	q.at, err = parseAt(r)
+	if err != nil {
+		return nil, badParam(codeInvalidParameter, "_at", "Bad at parameter")
+	}
+	q.sel, err = parseFields(r)
+	if err != nil {
+		return nil, badParam(codeInvalidParameter, "_fields", err.Error())
+	}
+	q.order, err = paging.ParseOrder(r.FormValue("_sort"))
+	if err != nil {
+		return nil, badParam(codeInvalidParameter, "_sort", err.Error())
+	}
+	if expr := r.FormValue("_filter"); expr != "" {
+		q.filter, err = filter.Parse(expr)
+		if err != nil {
+			e := badParam(codeInvalidFilter, "_filter", err.Error())
+			if se, ok := err.(*filter.SyntaxError); ok {
+				e.Position = se.Pos
+			}
+			return nil, e
+		}
+	}
+	if q.cursor != "" {
+		if err := q.order.Check(q.cursor); err != nil {
+			return nil, badParam(codeInvalidCursor, "_cursor", "Bad cursor parameter")
+		}
+	}
+	q.expand = r.FormValue("_expand") == "true" || q.sel != nil
+	return q, nil
+}
+
+// list filters, sorts and pages through the resources of a snapshot, as shared
+// by all versions of the API
+func list(resource string, q *listQuery, snapshot *melkor.Snapshot) *listing {
+	l := &listing{
+		snapshot: snapshot,
+		expand:   q.expand,
+		sel:      q.sel,
+	}
+	logrus.WithFields(logrus.Fields{"resource": resource, "limit": q.limit, "expand": l.expand}).Debug("Listing resources")
+	rows := listRows(snapshot, q.order.ByFields() || q.filter != nil)
+	if q.filter != nil {
+		rows = filterRows(q.filter, rows)
+	}
+	l.total = len(rows)
+	q.order.Sort(rows)
+	if q.cursor != "" {
+		// The cursor was checked by parseList
+		rows, _ = q.order.After(rows, q.cursor)
+	}
+	if q.limit > 0 && len(rows) > q.limit {
+		rows = rows[:q.limit]
+		l.next = q.order.Cursor(rows[q.limit-1])
+	}
+	logrus.WithField("count", len(rows)).Debug("limited data")
+	l.rows = rows
+	return l
 }
 
 // ListAWSResources returns a list of the requested resources, or a 404 if none
 // can be found in storage
 func (h *Handler) ListAWSResources() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		resource := vars["resource"]
-		limit, err := parseLimit(r)
-		if err != nil {
-			writeError(http.StatusBadRequest, "Bad limit parameter", w)
+		f, apiErr := negotiate(r)
+		if apiErr != nil {
+			writeError(apiErr.Status, apiErr.Message, w)
 			return
 		}
-
-		crawler := h.crawlers.Get(resource)
-		if crawler == nil {
-			logrus.WithField("resource", resource).Debug("Not Found")
+		q, apiErr := parseList(r)
+		if apiErr != nil {
+			writeError(apiErr.Status, apiErr.Message, w)
+			return
+		}
+		vars := mux.Vars(r)
+		snapshot := h.snapshot(vars, q.at)
+		if snapshot == nil {
 			notFound(w)
 			return
 		}
+		v, done := h.checkModified(w, r, f, snapshot)
+		if done {
+			return
+		}
+		l := list(vars["resource"], q, snapshot)
+		if l.next != "" {
+			w.Header().Set("Link", nextLink(r, l.next))
+		}
+		v.set(w)
+		items := l.items(r.Context())
+		writeFormat(f, http.StatusOK, items, items, l.sel, w)
+	}
+}
 
-		expand := r.FormValue("_expand") == "true"
-		logrus.WithFields(logrus.Fields{"resource": resource, "limit": limit, "expand": expand}).Debug("Listing resources")
-		if expand {
-			data := crawler.ListExpanded()
+// A page is a list of resources as returned by the v2 API, along with what
+// clients need to make sense of it. CrawledAt is null until the first crawl
+// completes, and Next is null on the last page. Formats listing items one by
+// one, such as CSV, only write the items.
+type page struct {
+	Items      *stream    `json:"items,omitempty"`
+	Count      int        `json:"count"`
+	Total      int        `json:"total"`
+	CrawledAt  *time.Time `json:"crawled_at"`
+	Generation uint64     `json:"generation"`
+	Next       *string    `json:"next"`
+}
 
-			filter := r.FormValue("_filter")
-			if filter != "" {
-				data, err = applyFilter(filter, data)
-				if err != nil {
-					writeError(http.StatusInternalServerError, err.Error(), w)
-					return
-				}
-			}
-			data = applyLimitExpanded(data, limit)
-			logrus.WithField("count", len(data)).Debug("limited data")
-			writeJSON(http.StatusOK, data, w)
+// writeJSON writes the page as JSON, streaming its items
+func (p *page) writeJSON(w io.Writer) error {
+	if _, err := io.WriteString(w, `{"items":`); err != nil {
+		return err
+	}
+	if err := p.Items.writeJSON(w); err != nil {
+		return err
+	}
+	// Without items, the other fields are all that is left
+	rest := *p
+	rest.Items = nil
+	b, err := json.Marshal(rest)
+	if err != nil {
+		return err
+	}
+	// The other fields follow the items, in place of the opening brace
+	b[0] = ','
+	_, err = w.Write(b)
+	return err
+}
+
+// ListAWSResourcesV2 returns a page of the requested resources, or a 404 if
+// none can be found in storage. Errors are written as structured objects.
+func (h *Handler) ListAWSResourcesV2() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, apiErr := negotiate(r)
+		if apiErr != nil {
+			writeAPIError(apiErr, w)
 			return
 		}
-		data := crawler.List()
-		data = applyLimit(data, limit)
-		writeJSON(http.StatusOK, data, w)
+		q, apiErr := parseList(r)
+		if apiErr != nil {
+			writeAPIError(apiErr, w)
+			return
+		}
+		vars := mux.Vars(r)
+		snapshot := h.snapshot(vars, q.at)
+		if snapshot == nil {
+			writeAPIError(errNotFound, w)
+			return
+		}
+		v, done := h.checkModified(w, r, f, snapshot)
+		if done {
+			return
+		}
+		l := list(vars["resource"], q, snapshot)
+		items := l.items(r.Context())
+		p := &page{
+			Items:      items,
+			Count:      len(l.rows),
+			Total:      l.total,
+			Generation: l.snapshot.Generation(),
+		}
+		if crawled := l.snapshot.CrawledAt(); !crawled.IsZero() {
+			p.CrawledAt = &crawled
+		}
+		if l.next != "" {
+			next := nextURL(r, l.next)
+			p.Next = &next
+			w.Header().Set("Link", nextLink(r, l.next))
+		}
+		v.set(w)
+		writeFormat(f, http.StatusOK, p, items, l.sel, w)
 	}
 }
 
@@ -71,22 +365,263 @@ func (h *Handler) GetSingleAWSResource() http.HandlerFunc {
 		vars := mux.Vars(r)
 		resource := vars["resource"]
 		id := vars["id"]
-		crawler := h.crawlers.Get(resource)
-		if crawler == nil {
-			logrus.WithField("resource", resource).Debug("Not Found")
+		f, apiErr := negotiate(r)
+		if apiErr != nil {
+			writeError(apiErr.Status, apiErr.Message, w)
+			return
+		}
+		if r.FormValue("_history") == "true" {
+			h.writeHistory(r, f, w)
+			return
+		}
+		at, err := parseAt(r)
+		if err != nil {
+			writeError(http.StatusBadRequest, "Bad at parameter", w)
+			return
+		}
+		sel, err := parseFields(r)
+		if err != nil {
+			writeError(http.StatusBadRequest, err.Error(), w)
+			return
+		}
+		snapshot := h.snapshot(vars, at)
+		if snapshot == nil {
 			notFound(w)
 			return
 		}
+		v, done := h.checkModified(w, r, f, snapshot)
+		if done {
+			return
+		}
 		logrus.WithFields(logrus.Fields{"resource": resource, "id": id}).Debug("Fetching single resource")
-		data := crawler.Get(id)
-		if data == nil {
-			logrus.WithFields(logrus.Fields{"resource": resource, "id": id}).Debug("Not Found")
-			notFound(w)
+		key, ok := singleKey(vars, w, snapshot)
+		if !ok {
 			return
 		}
+		idx := snapshot.IndexOfKey(key)
+		var data interface{} = snapshot.Expand(idx)
+		if sel != nil {
+			data = sel.Apply(snapshot.Expand(idx))
+		} else if b := snapshot.JSON(idx); b != nil {
+			data = json.RawMessage(b)
+		}
 
-		writeJSON(http.StatusOK, data, w)
+		v.set(w)
+		writeFormat(f, http.StatusOK, data, sliceStream(r.Context(), []interface{}{data}), sel, w)
+	}
+}
+
+// DiffAWSResources lists the ids of the resources added, removed and modified
+// between two points in time. Formats listing items one by one, such as CSV,
+// write a row per resource.
+func (h *Handler) DiffAWSResources() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		f, apiErr := negotiate(r)
+		if apiErr != nil {
+			writeError(apiErr.Status, apiErr.Message, w)
+			return
+		}
+		old, new, ok := h.diffSnapshots(vars, r, w)
+		if !ok {
+			return
+		}
+		d := melkor.DiffSnapshots(old, new)
+		writeFormat(f, http.StatusOK, d, sliceStream(r.Context(), collectionDiffRows(d)), nil, w)
+	}
+}
+
+// DiffSingleAWSResource lists the fields of a single item added, removed and
+// changed between two points in time. Formats listing items one by one, such
+// as CSV, write a row per field.
+func (h *Handler) DiffSingleAWSResource() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		f, apiErr := negotiate(r)
+		if apiErr != nil {
+			writeError(apiErr.Status, apiErr.Message, w)
+			return
+		}
+		old, new, ok := h.diffSnapshots(vars, r, w)
+		if !ok {
+			return
+		}
+		key, ok := singleKey(vars, w, old, new)
+		if !ok {
+			return
+		}
+		d := melkor.DiffDocs(expandKey(old, key), expandKey(new, key))
+		writeFormat(f, http.StatusOK, d, sliceStream(r.Context(), diffRows(d)), nil, w)
+	}
+}
+
+// collectionDiffRows lists the resources of a diff one by one, along with how
+// they changed
+func collectionDiffRows(d melkor.CollectionDiff) []interface{} {
+	var rows []interface{}
+	for _, c := range []struct {
+		change string
+		keys   []melkor.Key
+	}{{melkor.Added, d.Added}, {melkor.Removed, d.Removed}, {melkor.Modified, d.Modified}} {
+		for _, k := range c.keys {
+			row := map[string]interface{}{"change": c.change, "id": k.ID}
+			if k.Account != "" {
+				row["account"] = k.Account
+			}
+			if k.Region != "" {
+				row["region"] = k.Region
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// diffRows lists the fields of a diff one by one, along with how they changed
+func diffRows(d melkor.Diff) []interface{} {
+	var rows []interface{}
+	for _, v := range d.Added {
+		rows = append(rows, map[string]interface{}{"change": "added", "path": v.Path, "new": v.Value})
+	}
+	for _, v := range d.Removed {
+		rows = append(rows, map[string]interface{}{"change": "removed", "path": v.Path, "old": v.Value})
+	}
+	for _, c := range d.Changed {
+		rows = append(rows, map[string]interface{}{"change": "changed", "path": c.Path, "old": c.Old, "new": c.New})
+	}
+	return rows
+}
+
+// singleKey finds the key of the item asked for in any of the snapshots. As
+// ids are only unique within an account and region, an id found in several of
+// them must be narrowed down. If there is no single item an error is written
+// and ok is false.
+func singleKey(vars map[string]string, w http.ResponseWriter, snapshots ...*melkor.Snapshot) (key melkor.Key, ok bool) {
+	id := vars["id"]
+	var keys []melkor.Key
+	seen := make(map[melkor.Key]bool)
+	for _, s := range snapshots {
+		for _, idx := range s.Lookup(id) {
+			if k := s.Key(idx); !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	switch len(keys) {
+	case 0:
+		logrus.WithFields(logrus.Fields{"resource": vars["resource"], "id": id}).Debug("Not Found")
+		notFound(w)
+		return key, false
+	case 1:
+		return keys[0], true
+	}
+	scopes := make([]string, len(keys))
+	for idx, k := range keys {
+		scopes[idx] = k.Scope.String()
+	}
+	sort.Strings(scopes)
+	writeError(http.StatusConflict, fmt.Sprintf("%s is found in %s, narrow it down by account and region",
+		id, strings.Join(scopes, ", ")), w)
+	return key, false
+}
+
+// expandKey returns the expanded item with a key, or nil if there is none
+func expandKey(s *melkor.Snapshot, k melkor.Key) map[string]interface{} {
+	idx := s.IndexOfKey(k)
+	if idx < 0 {
+		return nil
+	}
+	return s.Expand(idx)
+}
+
+// diffSnapshots looks up the snapshots to compare, at the since and until
+// parameters. Until defaults to now. If they cannot be looked up an error is
+// written and ok is false.
+func (h *Handler) diffSnapshots(vars map[string]string, r *http.Request, w http.ResponseWriter) (old, new *melkor.Snapshot, ok bool) {
+	since, err := parseTime(r, "since")
+	if err != nil || since.IsZero() {
+		writeError(http.StatusBadRequest, "Bad since parameter", w)
+		return nil, nil, false
+	}
+	until, err := parseTime(r, "until")
+	if err != nil {
+		writeError(http.StatusBadRequest, "Bad until parameter", w)
+		return nil, nil, false
+	}
+	if until.IsZero() {
+		until = time.Now()
 	}
+	if until.Before(since) {
+		writeError(http.StatusBadRequest, "since must not be after until", w)
+		return nil, nil, false
+	}
+	logrus.WithFields(logrus.Fields{"resource": vars["resource"], "since": since, "until": until}).Debug("Diffing resources")
+
+	// Both sides are looked up in the history, as it holds the items in the
+	// same form for any point in time.
+	old = h.snapshot(vars, since)
+	new = h.snapshot(vars, until)
+	if old == nil || new == nil {
+		notFound(w)
+		return nil, nil, false
+	}
+	return old, new, true
+}
+
+// writeHistory writes all recorded versions of a single item, narrowed down to
+// the requested account and region if any, in a format. It changes with every
+// crawl, so its validators are those of the current snapshot.
+func (h *Handler) writeHistory(r *http.Request, f *format, w http.ResponseWriter) {
+	vars := mux.Vars(r)
+	crawler := h.crawler(vars)
+	if crawler == nil {
+		notFound(w)
+		return
+	}
+	v, done := h.checkModified(w, r, f, crawler.Snapshot())
+	if done {
+		return
+	}
+	logrus.WithFields(logrus.Fields{"resource": vars["resource"], "id": vars["id"]}).Debug("Fetching history")
+	var records []melkor.Record
+	for _, rec := range crawler.History().Get(vars["id"]) {
+		if inScope(vars, rec.Scope) {
+			records = append(records, rec)
+		}
+	}
+	if len(records) == 0 {
+		notFound(w)
+		return
+	}
+	items := make([]interface{}, len(records))
+	for idx, rec := range records {
+		items[idx] = rec
+	}
+	v.set(w)
+	writeFormat(f, http.StatusOK, records, sliceStream(r.Context(), items), nil, w)
+}
+
+// restored checks whether any of the resources served were restored from
+// storage rather than crawled since starting
+func restored(status []melkor.CrawlStatus) bool {
+	for _, st := range status {
+		if st.Restored {
+			return true
+		}
+	}
+	return false
+}
+
+// inScope checks whether a scope matches the requested account and region
+func inScope(vars map[string]string, scope melkor.Scope) bool {
+	if account, ok := vars["account"]; ok && account != scope.Account {
+		return false
+	}
+	if region, ok := vars["region"]; ok && region != scope.Region {
+		return false
+	}
+	return true
 }
 
 // ServiceMetadata displays hopefully useful information about the service
@@ -99,6 +634,12 @@ func (h *Handler) ServiceMetadata() http.HandlerFunc {
 			inner["resource"] = c.Resource()
 			inner["last_crawled"] = c.LastCrawled()
 			inner["count"] = c.Count()
+			status := c.Status()
+			inner["regions"] = status
+			inner["restored"] = restored(status)
+			if !c.LastCrawled().IsZero() {
+				inner["age_seconds"] = int(time.Since(c.LastCrawled()).Seconds())
+			}
 			crawlers = append(crawlers, inner)
 		}
 		data["owner"] = h.config.Owner
@@ -106,6 +647,13 @@ func (h *Handler) ServiceMetadata() http.HandlerFunc {
 		data["service_name"] = "melkor"
 		data["service_version"] = version.Version
 		data["aws_region"] = h.config.AWSRegion
+		data["aws_regions"] = h.config.Regions()
+		var accounts []string
+		for _, a := range h.config.Accounts {
+			accounts = append(accounts, a.ID())
+		}
+		data["aws_accounts"] = accounts
+		data["storage"] = h.config.Storage
 		data["crawlers"] = crawlers
 
 		writeJSON(http.StatusOK, data, w)
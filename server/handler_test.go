@@ -176,17 +176,17 @@ func Test_ListAWSResources_Filter_ValueInsensitive(t *testing.T) {
 func Test_ListAWSResources_Filter_InvalidFormat(t *testing.T) {
 	m, wr := setupListAWSResources(fixtures.FullCrawlerData(3))
 
-	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_expand=true&_filter=Tags.Team:TEAM2", nil)
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_expand=true&_filter=Tags.Team%3ATEAM2)", nil)
 	m.ServeHTTP(wr, r)
 
-	assert.Equal(t, http.StatusInternalServerError, wr.Code)
+	assert.Equal(t, http.StatusBadRequest, wr.Code)
 
 	var actual map[string]interface{}
 	err := json.Unmarshal(wr.Body.Bytes(), &actual)
 
 	assert.Nil(t, err)
 	assert.Contains(t, actual, "error")
-	assert.Contains(t, actual["error"], "invalid format of filter")
+	assert.Equal(t, `syntax error at position 16: unexpected ")"`, actual["error"])
 }
 
 func Test_ListAWSResources_Filter_And_Limit(t *testing.T) {
@@ -317,3 +317,815 @@ func Test_ServiceMetadata(t *testing.T) {
 		assert.Equal(t, int(v.(float64)), 20)
 	}
 }
+
+func regionalCrawler() *mock.InstanceCrawler {
+	mc := &mock.InstanceCrawler{}
+	mc.SnapshotFn = func() *melkor.Snapshot {
+		data := fixtures.FullCrawlerData(3)
+		split := map[string][]map[string]interface{}{
+			"eu-west-1": data[:1],
+			"us-east-1": data[1:],
+		}
+		parts := make(map[melkor.Scope]*melkor.Snapshot)
+		for region, ds := range split {
+			var items []melkor.Item
+			for _, d := range ds {
+				items = append(items, melkor.Item{ID: d["InstanceId"].(string), Region: region, Value: d})
+			}
+			parts[melkor.Scope{Region: region}] = melkor.NewSnapshot(time.Now(), items, mock.ExpandData)
+		}
+		return melkor.Merge(parts)
+	}
+	return mc
+}
+
+func Test_ListAWSResources_AllRegions(t *testing.T) {
+	mc := regionalCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+	wr := httptest.NewRecorder()
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_expand=true", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	var actual []map[string]interface{}
+	err := json.Unmarshal(wr.Body.Bytes(), &actual)
+	assert.Nil(t, err)
+	assert.Len(t, actual, 3)
+	assert.Equal(t, "eu-west-1", actual[0]["Region"])
+	assert.Equal(t, "us-east-1", actual[1]["Region"])
+	assert.Equal(t, "us-east-1", actual[2]["Region"])
+}
+
+func Test_ListAWSResources_Region(t *testing.T) {
+	mc := regionalCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+	wr := httptest.NewRecorder()
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/us-east-1/mock", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	var actual []string
+	err := json.Unmarshal(wr.Body.Bytes(), &actual)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"i-1", "i-2"}, actual)
+}
+
+func Test_ListAWSResources_Uncrawled(t *testing.T) {
+	mc := &mock.InstanceCrawler{SnapshotFn: func() *melkor.Snapshot { return &melkor.Snapshot{} }}
+	cfg := &config.Config{
+		AWSRegions: []string{"eu-west-1", "us-east-1"},
+		Accounts:   []config.Account{{RoleARN: "arn:aws:iam::111111111111:role/melkor"}},
+	}
+	m := NewRouter(cfg, melkor.Crawlers{mc.Resource(): mc})
+
+	for url, code := range map[string]int{
+		"/api/v1/aws/mock":                                  http.StatusOK,
+		"/api/v1/aws/us-east-1/mock":                        http.StatusOK,
+		"/api/v1/accounts/111111111111/aws/mock":            http.StatusOK,
+		"/api/v1/accounts/111111111111/aws/eu-west-1/mock":  http.StatusOK,
+		"/api/v2/aws/eu-west-1/mock":                        http.StatusOK,
+		"/api/v1/aws/ap-south-1/mock":                       http.StatusNotFound,
+		"/api/v1/accounts/222222222222/aws/mock":            http.StatusNotFound,
+		"/api/v1/accounts/111111111111/aws/ap-south-1/mock": http.StatusNotFound,
+		"/api/v1/aws/us-east-1/mock/i-0":                    http.StatusNotFound,
+	} {
+		wr := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", url, nil)
+		m.ServeHTTP(wr, r)
+
+		assert.Equal(t, code, wr.Code, url)
+		if code == http.StatusOK && strings.Contains(url, "/v1/") {
+			assert.JSONEq(t, "[]", wr.Body.String(), url)
+		}
+	}
+}
+
+func Test_ListAWSResources_UnknownRegion(t *testing.T) {
+	mc := regionalCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+	wr := httptest.NewRecorder()
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/ap-south-1/mock", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusNotFound, wr.Code)
+}
+
+func Test_GetSingleAWSResource_Region(t *testing.T) {
+	mc := regionalCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+
+	wr := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/api/v1/aws/us-east-1/mock/i-2", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	var actual map[string]interface{}
+	err := json.Unmarshal(wr.Body.Bytes(), &actual)
+	assert.Nil(t, err)
+	assert.Equal(t, "i-2", actual["InstanceId"])
+	assert.Equal(t, "us-east-1", actual["Region"])
+
+	wr = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/api/v1/aws/eu-west-1/mock/i-2", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusNotFound, wr.Code)
+}
+
+func Test_ServiceMetadata_Regions(t *testing.T) {
+	now := time.Now()
+	mc := &mock.InstanceCrawler{
+		CountFn:       func() int { return 1 },
+		LastCrawledFn: func() time.Time { return now },
+		StatusFn: func() []melkor.CrawlStatus {
+			return []melkor.CrawlStatus{
+				{Scope: melkor.Scope{Region: "eu-west-1"}, LastCrawled: now, LastAttempt: now, Count: 1},
+				{Scope: melkor.Scope{Region: "us-east-1"}, LastAttempt: now, Error: "throttled"},
+			}
+		},
+	}
+	cfg := &config.Config{AWSRegions: []string{"eu-west-1", "us-east-1"}}
+	m := NewRouter(cfg, melkor.Crawlers{mc.Resource(): mc})
+	wr := httptest.NewRecorder()
+
+	r, _ := http.NewRequest("GET", "/service-metadata", nil)
+	m.ServeHTTP(wr, r)
+
+	var actual map[string]interface{}
+	err := json.Unmarshal(wr.Body.Bytes(), &actual)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"eu-west-1", "us-east-1"}, actual["aws_regions"])
+
+	crawler0 := actual["crawlers"].([]interface{})[0].(map[string]interface{})
+	regions := crawler0["regions"].([]interface{})
+	assert.Len(t, regions, 2)
+	assert.Equal(t, "throttled", regions[1].(map[string]interface{})["error"])
+	assert.NotContains(t, regions[0], "error")
+}
+
+func accountCrawler() *mock.InstanceCrawler {
+	mc := &mock.InstanceCrawler{}
+	mc.SnapshotFn = func() *melkor.Snapshot {
+		data := fixtures.FullCrawlerData(4)
+		split := map[melkor.Scope][]map[string]interface{}{
+			{Account: "111111111111", Region: "eu-west-1"}: data[:1],
+			{Account: "111111111111", Region: "us-east-1"}: data[1:2],
+			{Account: "222222222222", Region: "eu-west-1"}: data[2:],
+		}
+		parts := make(map[melkor.Scope]*melkor.Snapshot)
+		for sc, ds := range split {
+			var items []melkor.Item
+			for _, d := range ds {
+				items = append(items, melkor.Item{
+					ID:      d["InstanceId"].(string),
+					Account: sc.Account,
+					Region:  sc.Region,
+					Value:   d,
+				})
+			}
+			parts[sc] = melkor.NewSnapshot(time.Now(), items, mock.ExpandData)
+		}
+		return melkor.Merge(parts)
+	}
+	return mc
+}
+
+var accountTests = []struct {
+	url      string
+	code     int
+	expected []string
+}{
+	{"/api/v1/accounts/111111111111/aws/mock", http.StatusOK, []string{"i-0", "i-1"}},
+	{"/api/v1/accounts/222222222222/aws/mock", http.StatusOK, []string{"i-2", "i-3"}},
+	{"/api/v1/accounts/111111111111/aws/us-east-1/mock", http.StatusOK, []string{"i-1"}},
+	{"/api/v1/accounts/222222222222/aws/us-east-1/mock", http.StatusNotFound, nil},
+	{"/api/v1/accounts/333333333333/aws/mock", http.StatusNotFound, nil},
+	{"/api/v1/aws/eu-west-1/mock", http.StatusOK, []string{"i-0", "i-2", "i-3"}},
+}
+
+func Test_ListAWSResources_Accounts(t *testing.T) {
+	mc := accountCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+
+	for _, tt := range accountTests {
+		wr := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", tt.url, nil)
+		m.ServeHTTP(wr, r)
+
+		assert.Equal(t, tt.code, wr.Code, tt.url)
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var actual []string
+		err := json.Unmarshal(wr.Body.Bytes(), &actual)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.expected, actual, tt.url)
+	}
+}
+
+func Test_GetSingleAWSResource_Account(t *testing.T) {
+	mc := accountCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+
+	wr := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/api/v1/accounts/222222222222/aws/mock/i-3", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	var actual map[string]interface{}
+	err := json.Unmarshal(wr.Body.Bytes(), &actual)
+	assert.Nil(t, err)
+	assert.Equal(t, "222222222222", actual["AccountId"])
+
+	wr = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/api/v1/accounts/111111111111/aws/mock/i-3", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusNotFound, wr.Code)
+}
+
+func historyCrawler() *mock.InstanceCrawler {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	eu := melkor.Scope{Region: "eu-west-1"}
+	h := melkor.NewHistory(0)
+	for n, state := range []string{"pending", "running"} {
+		items := []melkor.Item{{
+			ID:     "i-0",
+			Region: eu.Region,
+			Value:  map[string]interface{}{"InstanceId": "i-0", "State": state},
+		}}
+		if n == 0 {
+			items = append(items, melkor.Item{
+				ID:     "i-1",
+				Region: eu.Region,
+				Value:  map[string]interface{}{"InstanceId": "i-1", "State": "running"},
+			})
+		}
+		h.Record(eu, melkor.NewSnapshot(t0.Add(time.Duration(n)*time.Hour), items, mock.ExpandData))
+	}
+	return &mock.InstanceCrawler{
+		Data:      []map[string]interface{}{{"InstanceId": "i-0", "State": "running"}},
+		HistoryFn: func() *melkor.History { return h },
+	}
+}
+
+var atTests = []struct {
+	url      string
+	code     int
+	expected string
+}{
+	{"/api/v1/aws/mock?_at=2017-03-01T12:30:00Z", http.StatusOK, `["i-0","i-1"]`},
+	{"/api/v1/aws/mock?_at=1488373200000", http.StatusOK, `["i-0"]`},
+	{"/api/v1/aws/mock?_at=2017-03-01T11:00:00Z", http.StatusNotFound, ""},
+	{"/api/v1/aws/eu-west-1/mock?_at=2017-03-01T12:30:00Z", http.StatusOK, `["i-0","i-1"]`},
+	{"/api/v1/aws/us-east-1/mock?_at=2017-03-01T12:30:00Z", http.StatusNotFound, ""},
+	{"/api/v1/aws/mock?_at=yesterday", http.StatusBadRequest, ""},
+	{"/api/v1/aws/mock/i-1?_at=2017-03-01T12:30:00Z", http.StatusOK, ""},
+	{"/api/v1/aws/mock/i-1?_at=2017-03-01T13:30:00Z", http.StatusNotFound, ""},
+	{"/api/v1/aws/mock/i-1?_at=yesterday", http.StatusBadRequest, ""},
+}
+
+func Test_At(t *testing.T) {
+	mc := historyCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+
+	for _, tt := range atTests {
+		wr := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", tt.url, nil)
+		m.ServeHTTP(wr, r)
+
+		assert.Equal(t, tt.code, wr.Code, tt.url)
+		if tt.expected != "" {
+			assert.JSONEq(t, tt.expected, wr.Body.String(), tt.url)
+		}
+	}
+}
+
+func Test_GetSingleAWSResource_At(t *testing.T) {
+	mc := historyCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+
+	wr := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock/i-0?_at=2017-03-01T12:30:00Z", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	var actual map[string]interface{}
+	err := json.Unmarshal(wr.Body.Bytes(), &actual)
+	assert.Nil(t, err)
+	assert.Equal(t, "pending", actual["State"])
+	assert.Equal(t, "eu-west-1", actual["Region"])
+}
+
+func Test_GetSingleAWSResource_History(t *testing.T) {
+	mc := historyCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+
+	wr := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock/i-0?_history=true", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	var actual []map[string]interface{}
+	err := json.Unmarshal(wr.Body.Bytes(), &actual)
+	assert.Nil(t, err)
+	assert.Len(t, actual, 1)
+	assert.Equal(t, "i-0", actual[0]["id"])
+	assert.Equal(t, "eu-west-1", actual[0]["region"])
+	assert.Equal(t, "2017-03-01T12:00:00Z", actual[0]["first_seen"])
+	assert.Equal(t, "2017-03-01T13:00:00Z", actual[0]["last_seen"])
+	versions := actual[0]["versions"].([]interface{})
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "2017-03-01T13:00:00Z", versions[0].(map[string]interface{})["to"])
+	assert.NotContains(t, versions[1], "to")
+
+	for _, url := range []string{
+		"/api/v1/aws/mock/i-9?_history=true",
+		"/api/v1/aws/us-east-1/mock/i-0?_history=true",
+		"/api/v1/aws/unknown/i-0?_history=true",
+	} {
+		wr = httptest.NewRecorder()
+		r, _ = http.NewRequest("GET", url, nil)
+		m.ServeHTTP(wr, r)
+		assert.Equal(t, http.StatusNotFound, wr.Code, url)
+	}
+}
+
+var diffTests = []struct {
+	url      string
+	code     int
+	expected string
+}{
+	{
+		"/api/v1/aws/mock/_diff?since=2017-03-01T12:00:00Z&until=2017-03-01T13:00:00Z",
+		http.StatusOK,
+		`{"added":[],"removed":[{"id":"i-1","region":"eu-west-1"}],"modified":[{"id":"i-0","region":"eu-west-1"}]}`,
+	},
+	{
+		"/api/v1/aws/eu-west-1/mock/_diff?since=1488369600000",
+		http.StatusOK,
+		`{"added":[],"removed":[{"id":"i-1","region":"eu-west-1"}],"modified":[{"id":"i-0","region":"eu-west-1"}]}`,
+	},
+	{
+		"/api/v1/aws/mock/_diff?since=2017-03-01T12:00:00Z&until=2017-03-01T12:30:00Z",
+		http.StatusOK,
+		`{"added":[],"removed":[],"modified":[]}`,
+	},
+	{
+		"/api/v1/aws/mock/i-0/_diff?since=2017-03-01T12:00:00Z",
+		http.StatusOK,
+		`{"added":[],"removed":[],"changed":[{"path":"State","old":"pending","new":"running"}]}`,
+	},
+	{
+		"/api/v1/aws/mock/i-1/_diff?since=2017-03-01T12:00:00Z",
+		http.StatusOK,
+		`{"added":[],"removed":[{"path":"InstanceId","value":"i-1"},{"path":"Region","value":"eu-west-1"},{"path":"State","value":"running"}],"changed":[]}`,
+	},
+	{"/api/v1/aws/mock/_diff", http.StatusBadRequest, ""},
+	{"/api/v1/aws/mock/_diff?since=yesterday", http.StatusBadRequest, ""},
+	{"/api/v1/aws/mock/_diff?since=2017-03-01T12:00:00Z&until=today", http.StatusBadRequest, ""},
+	{"/api/v1/aws/mock/_diff?since=2017-03-01T13:00:00Z&until=2017-03-01T12:00:00Z", http.StatusBadRequest, ""},
+	{"/api/v1/aws/mock/_diff?since=2017-03-01T11:00:00Z", http.StatusNotFound, ""},
+	{"/api/v1/aws/us-east-1/mock/_diff?since=2017-03-01T12:00:00Z", http.StatusNotFound, ""},
+	{"/api/v1/aws/unknown/_diff?since=2017-03-01T12:00:00Z", http.StatusNotFound, ""},
+	{"/api/v1/aws/mock/i-9/_diff?since=2017-03-01T12:00:00Z", http.StatusNotFound, ""},
+}
+
+func Test_Diff(t *testing.T) {
+	mc := historyCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+
+	for _, tt := range diffTests {
+		wr := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", tt.url, nil)
+		m.ServeHTTP(wr, r)
+
+		assert.Equal(t, tt.code, wr.Code, tt.url)
+		if tt.expected != "" {
+			assert.JSONEq(t, tt.expected, wr.Body.String(), tt.url)
+		}
+	}
+}
+
+func Test_Diff_Formats(t *testing.T) {
+	mc := historyCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+
+	for _, tt := range []struct {
+		url         string
+		accept      string
+		contentType string
+		expected    string
+	}{
+		{
+			"/api/v1/aws/mock/_diff?since=2017-03-01T12:00:00Z&_format=csv", "",
+			"text/csv; charset=UTF-8",
+			"change,id,region\nremoved,i-1,eu-west-1\nmodified,i-0,eu-west-1\n",
+		},
+		{
+			"/api/v1/aws/mock/i-0/_diff?since=2017-03-01T12:00:00Z", "application/x-ndjson",
+			"application/x-ndjson",
+			`{"change":"changed","new":"running","old":"pending","path":"State"}` + "\n",
+		},
+		{
+			"/api/v1/aws/mock/i-0/_diff?since=2017-03-01T12:00:00Z&_format=yaml", "",
+			"application/x-yaml; charset=UTF-8",
+			"added: []\nchanged:\n- new: running\n  old: pending\n  path: State\nremoved: []\n",
+		},
+	} {
+		wr := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", tt.url, nil)
+		r.Header.Set("Accept", tt.accept)
+		m.ServeHTTP(wr, r)
+
+		assert.Equal(t, http.StatusOK, wr.Code, tt.url)
+		assert.Equal(t, tt.contentType, wr.Header().Get("Content-Type"), tt.url)
+		assert.Equal(t, tt.expected, wr.Body.String(), tt.url)
+	}
+
+	wr := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock/_diff?since=2017-03-01T12:00:00Z&_format=xml", nil)
+	m.ServeHTTP(wr, r)
+	assert.Equal(t, http.StatusBadRequest, wr.Code)
+}
+
+// sharedIDCrawler has crawled i-0 in two regions, stopped in one of them
+func sharedIDCrawler() *mock.InstanceCrawler {
+	t0 := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := melkor.NewHistory(0)
+	for n, states := range []map[string]string{
+		{"eu-west-1": "running", "us-east-1": "running"},
+		{"eu-west-1": "running", "us-east-1": "stopped"},
+	} {
+		parts := make(map[melkor.Scope]*melkor.Snapshot)
+		for region, state := range states {
+			items := []melkor.Item{{
+				ID:     "i-0",
+				Region: region,
+				Value:  map[string]interface{}{"InstanceId": "i-0", "State": state},
+			}}
+			parts[melkor.Scope{Region: region}] = melkor.NewSnapshot(t0.Add(time.Duration(n)*time.Hour), items, mock.ExpandData)
+		}
+		h.RecordAll(parts)
+	}
+	return &mock.InstanceCrawler{HistoryFn: func() *melkor.History { return h }}
+}
+
+var sharedIDTests = []struct {
+	url      string
+	code     int
+	expected string
+}{
+	{
+		"/api/v1/aws/mock/_diff?since=2017-03-01T12:00:00Z",
+		http.StatusOK,
+		`{"added":[],"removed":[],"modified":[{"id":"i-0","region":"us-east-1"}]}`,
+	},
+	{"/api/v1/aws/mock/i-0/_diff?since=2017-03-01T12:00:00Z", http.StatusConflict, ""},
+	{
+		"/api/v1/aws/us-east-1/mock/i-0/_diff?since=2017-03-01T12:00:00Z",
+		http.StatusOK,
+		`{"added":[],"removed":[],"changed":[{"path":"State","old":"running","new":"stopped"}]}`,
+	},
+	{
+		"/api/v1/aws/eu-west-1/mock/i-0/_diff?since=2017-03-01T12:00:00Z",
+		http.StatusOK,
+		`{"added":[],"removed":[],"changed":[]}`,
+	},
+	{"/api/v1/aws/mock/i-0?_at=2017-03-01T13:00:00Z", http.StatusConflict, ""},
+	{
+		"/api/v1/aws/us-east-1/mock/i-0?_at=2017-03-01T13:00:00Z",
+		http.StatusOK,
+		`{"InstanceId":"i-0","Region":"us-east-1","State":"stopped"}`,
+	},
+}
+
+func Test_SharedID(t *testing.T) {
+	mc := sharedIDCrawler()
+	m := NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+
+	for _, tt := range sharedIDTests {
+		wr := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", tt.url, nil)
+		m.ServeHTTP(wr, r)
+
+		assert.Equal(t, tt.code, wr.Code, tt.url)
+		if tt.expected != "" {
+			assert.JSONEq(t, tt.expected, wr.Body.String(), tt.url)
+		}
+	}
+}
+
+func Test_ServiceMetadata_Restored(t *testing.T) {
+	saved := time.Now().Add(-time.Hour)
+	mc := &mock.InstanceCrawler{
+		CountFn:       func() int { return 1 },
+		LastCrawledFn: func() time.Time { return saved },
+		StatusFn: func() []melkor.CrawlStatus {
+			return []melkor.CrawlStatus{
+				{Scope: melkor.Scope{Region: "eu-west-1"}, LastCrawled: saved, Count: 1, Restored: true},
+			}
+		},
+	}
+	m := NewRouter(&config.Config{Storage: "file"}, melkor.Crawlers{mc.Resource(): mc})
+	wr := httptest.NewRecorder()
+
+	r, _ := http.NewRequest("GET", "/service-metadata", nil)
+	m.ServeHTTP(wr, r)
+
+	var actual map[string]interface{}
+	err := json.Unmarshal(wr.Body.Bytes(), &actual)
+	assert.Nil(t, err)
+	assert.Equal(t, "file", actual["storage"])
+
+	crawler0 := actual["crawlers"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, true, crawler0["restored"])
+	assert.InDelta(t, 3600, crawler0["age_seconds"], 5)
+	assert.Equal(t, true, crawler0["regions"].([]interface{})[0].(map[string]interface{})["restored"])
+}
+
+func Test_ListAWSResources_Fields(t *testing.T) {
+	m, wr := setupListAWSResources(fixtures.FullCrawlerData(2))
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_fields=InstanceId,Tags.Team,ip=NetworkInterfaces.PrivateIpAddress", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	var actual []map[string]interface{}
+	assert.Nil(t, json.Unmarshal(wr.Body.Bytes(), &actual))
+	assert.Equal(t, []map[string]interface{}{
+		{"InstanceId": "i-0", "Tags": []interface{}{map[string]interface{}{"Team": "team0"}}, "ip": []interface{}{"10.20.30.0"}},
+		{"InstanceId": "i-1", "Tags": []interface{}{map[string]interface{}{"Team": "team1"}}, "ip": []interface{}{"10.20.30.1"}},
+	}, actual)
+}
+
+func Test_ListAWSResources_Fields_Filter(t *testing.T) {
+	m, wr := setupListAWSResources(fixtures.FullCrawlerData(3))
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_expand=true&_filter=(Tags.Team:team2)&_fields=InstanceId", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	assert.JSONEq(t, `[{"InstanceId": "i-2"}]`, wr.Body.String())
+}
+
+func Test_ListAWSResources_Fields_Invalid(t *testing.T) {
+	m, wr := setupListAWSResources(fixtures.FullCrawlerData(3))
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_fields=Tags..Team", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusBadRequest, wr.Code)
+	assert.Contains(t, wr.Body.String(), "invalid fields at position 6")
+}
+
+func Test_GetSingleAWSResource_Fields(t *testing.T) {
+	m, wr := setupGetSingleAWSResource()
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock/i-1?_fields=InstanceId,State.(Code,Name)", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	var actual map[string]interface{}
+	assert.Nil(t, json.Unmarshal(wr.Body.Bytes(), &actual))
+	assert.Equal(t, "i-1", actual["InstanceId"])
+	assert.Len(t, actual, 2)
+	assert.Len(t, actual["State"], 2)
+
+	wr = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/api/v1/aws/mock/i-1?_fields=a=b,a=c", nil)
+	m.ServeHTTP(wr, r)
+	assert.Equal(t, http.StatusBadRequest, wr.Code)
+}
+
+func Test_ListAWSResources_Sort(t *testing.T) {
+	m, wr := setupListAWSResources(fixtures.FullCrawlerData(4))
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_sort=-AmiLaunchIndex", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	assert.JSONEq(t, `["i-3", "i-2", "i-1", "i-0"]`, wr.Body.String())
+	assert.Empty(t, wr.Header().Get("Link"))
+
+	wr = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/api/v1/aws/mock?_sort=Tags..Team", nil)
+	m.ServeHTTP(wr, r)
+	assert.Equal(t, http.StatusBadRequest, wr.Code)
+}
+
+func Test_ListAWSResources_Cursor(t *testing.T) {
+	m, _ := setupListAWSResources(fixtures.FullCrawlerData(5))
+
+	var pages [][]string
+	next := "/api/v1/aws/mock?_sort=-AmiLaunchIndex&_limit=2"
+	for next != "" {
+		wr := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", next, nil)
+		m.ServeHTTP(wr, r)
+		assert.Equal(t, http.StatusOK, wr.Code)
+
+		var page []string
+		assert.Nil(t, json.Unmarshal(wr.Body.Bytes(), &page))
+		pages = append(pages, page)
+
+		next = ""
+		if link := wr.Header().Get("Link"); link != "" {
+			assert.True(t, strings.HasSuffix(link, `>; rel="next"`), link)
+			next = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			assert.Contains(t, next, "_sort=-AmiLaunchIndex")
+		}
+	}
+	assert.Equal(t, [][]string{{"i-4", "i-3"}, {"i-2", "i-1"}, {"i-0"}}, pages)
+}
+
+func Test_ListAWSResources_Cursor_Expanded(t *testing.T) {
+	m, wr := setupListAWSResources(fixtures.FullCrawlerData(3))
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_fields=InstanceId&_limit=1", nil)
+	m.ServeHTTP(wr, r)
+	assert.JSONEq(t, `[{"InstanceId": "i-0"}]`, wr.Body.String())
+	link := wr.Header().Get("Link")
+
+	wr = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`), nil)
+	m.ServeHTTP(wr, r)
+	assert.JSONEq(t, `[{"InstanceId": "i-1"}]`, wr.Body.String())
+}
+
+func Test_ListAWSResources_Cursor_Invalid(t *testing.T) {
+	m, wr := setupListAWSResources(fixtures.FullCrawlerData(3))
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_limit=1", nil)
+	m.ServeHTTP(wr, r)
+	link := wr.Header().Get("Link")
+	assert.NotEmpty(t, link)
+	cursor := link[strings.Index(link, "_cursor=")+len("_cursor=") : strings.Index(link, "&")]
+
+	for _, query := range []string{"_cursor=garbage", "_sort=InstanceId&_cursor=" + cursor} {
+		wr = httptest.NewRecorder()
+		r, _ = http.NewRequest("GET", "/api/v1/aws/mock?"+query, nil)
+		m.ServeHTTP(wr, r)
+		assert.Equal(t, http.StatusBadRequest, wr.Code, query)
+	}
+}
+
+func Test_ListAWSResources_Filter_NotExpanded(t *testing.T) {
+	m, wr := setupListAWSResources(fixtures.FullCrawlerData(4))
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_filter=Tags.Team%20in%20(team1,team3)", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	assert.JSONEq(t, `["i-1", "i-3"]`, wr.Body.String())
+
+	wr = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/api/v1/aws/mock?_filter=(Tags.Team:team1", nil)
+	m.ServeHTTP(wr, r)
+	assert.Equal(t, http.StatusBadRequest, wr.Code)
+}
+
+func Test_ListAWSResources_UnknownParameter(t *testing.T) {
+	m, wr := setupListAWSResources(fixtures.FullCrawlerData(3))
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_expand=true&_filtr=(Tags.Team:team1)", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusBadRequest, wr.Code)
+	assert.JSONEq(t, `{"error": "Unknown parameter _filtr"}`, wr.Body.String())
+
+	wr = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/api/v1/aws/mock?cachebuster=1&_limit=1", nil)
+	m.ServeHTTP(wr, r)
+	assert.Equal(t, http.StatusOK, wr.Code)
+}
+
+func setupListAWSResourcesV2(data []map[string]interface{}, crawled time.Time, generation uint64) *mux.Router {
+	mc := &mock.InstanceCrawler{Data: data}
+	mc.SnapshotFn = func() *melkor.Snapshot {
+		items := make([]melkor.Item, len(data))
+		for idx, d := range data {
+			items[idx] = melkor.Item{ID: d["InstanceId"].(string), Region: "eu-west-1", Value: d}
+		}
+		return melkor.Merge(map[melkor.Scope]*melkor.Snapshot{
+			{Region: "eu-west-1"}: melkor.NewSnapshot(crawled, items, mock.ExpandData),
+		}).WithGeneration(generation)
+	}
+	return NewRouter(&config.Config{}, melkor.Crawlers{mc.Resource(): mc})
+}
+
+func Test_ListAWSResourcesV2(t *testing.T) {
+	crawled := time.Date(2017, 3, 1, 14, 0, 0, 0, time.UTC)
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(5), crawled, 3)
+	wr := httptest.NewRecorder()
+
+	r, _ := http.NewRequest("GET", "/api/v2/aws/mock?_filter=AmiLaunchIndex%3E0&_limit=2", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	var actual struct {
+		Items      []string   `json:"items"`
+		Count      int        `json:"count"`
+		Total      int        `json:"total"`
+		CrawledAt  *time.Time `json:"crawled_at"`
+		Generation uint64     `json:"generation"`
+		Next       *string    `json:"next"`
+	}
+	assert.Nil(t, json.Unmarshal(wr.Body.Bytes(), &actual))
+	assert.Equal(t, []string{"i-1", "i-2"}, actual.Items)
+	assert.Equal(t, 2, actual.Count)
+	assert.Equal(t, 4, actual.Total)
+	assert.True(t, crawled.Equal(*actual.CrawledAt))
+	assert.Equal(t, uint64(3), actual.Generation)
+	if assert.NotNil(t, actual.Next) {
+		assert.True(t, strings.HasPrefix(*actual.Next, "/api/v2/aws/mock?"), *actual.Next)
+		assert.Equal(t, "<"+*actual.Next+`>; rel="next"`, wr.Header().Get("Link"))
+	}
+}
+
+func Test_ListAWSResourcesV2_Pages(t *testing.T) {
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(5), time.Now(), 1)
+
+	var pages [][]map[string]interface{}
+	next := "/api/v2/aws/eu-west-1/mock?_fields=InstanceId&_sort=-AmiLaunchIndex&_limit=2"
+	for next != "" {
+		wr := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", next, nil)
+		m.ServeHTTP(wr, r)
+		assert.Equal(t, http.StatusOK, wr.Code)
+
+		var p struct {
+			Items []map[string]interface{} `json:"items"`
+			Total int                      `json:"total"`
+			Next  *string                  `json:"next"`
+		}
+		assert.Nil(t, json.Unmarshal(wr.Body.Bytes(), &p))
+		assert.Equal(t, 5, p.Total)
+		pages = append(pages, p.Items)
+
+		next = ""
+		if p.Next != nil {
+			next = *p.Next
+		}
+	}
+	assert.Equal(t, [][]map[string]interface{}{
+		{{"InstanceId": "i-4"}, {"InstanceId": "i-3"}},
+		{{"InstanceId": "i-2"}, {"InstanceId": "i-1"}},
+		{{"InstanceId": "i-0"}},
+	}, pages)
+}
+
+func Test_ListAWSResourcesV2_Empty(t *testing.T) {
+	m := setupListAWSResourcesV2(mock.EmptyCrawlerData(), time.Time{}, 0)
+	wr := httptest.NewRecorder()
+
+	r, _ := http.NewRequest("GET", "/api/v2/aws/mock", nil)
+	m.ServeHTTP(wr, r)
+
+	assert.Equal(t, http.StatusOK, wr.Code)
+	assert.JSONEq(t, `{"items": [], "count": 0, "total": 0, "crawled_at": null, "generation": 0, "next": null}`, wr.Body.String())
+}
+
+var v2ErrorTests = []struct {
+	url      string
+	status   int
+	expected string
+}{
+	{"/api/v2/aws/unknown", http.StatusNotFound, `{"code": "not_found", "message": "Not Found"}`},
+	{"/api/v2/aws/us-east-1/mock", http.StatusNotFound, `{"code": "not_found", "message": "Not Found"}`},
+	{"/api/v2/aws/mock?_filtr=x", http.StatusBadRequest, `{"code": "unknown_parameter", "message": "Unknown parameter _filtr", "param": "_filtr"}`},
+	{"/api/v2/aws/mock?_limit=all", http.StatusBadRequest, `{"code": "invalid_parameter", "message": "Bad limit parameter", "param": "_limit"}`},
+	{"/api/v2/aws/mock?_sort=Tags..Team", http.StatusBadRequest, `{"code": "invalid_parameter", "message": "invalid sort field \"Tags..Team\"", "param": "_sort"}`},
+	{"/api/v2/aws/mock?_filter=(Tags.Team:team1", http.StatusBadRequest, `{"code": "invalid_filter", "message": "syntax error at position 1: unclosed parenthesis", "param": "_filter", "position": 1}`},
+	{"/api/v2/aws/mock?_cursor=garbage", http.StatusBadRequest, `{"code": "invalid_cursor", "message": "Bad cursor parameter", "param": "_cursor"}`},
+}
+
+func Test_ListAWSResourcesV2_Errors(t *testing.T) {
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(2), time.Now(), 1)
+
+	for _, tt := range v2ErrorTests {
+		wr := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", tt.url, nil)
+		m.ServeHTTP(wr, r)
+
+		assert.Equal(t, tt.status, wr.Code, tt.url)
+		assert.JSONEq(t, `{"error": `+tt.expected+`}`, wr.Body.String(), tt.url)
+	}
+}
+
+func Test_ListAWSResources_V1Unchanged(t *testing.T) {
+	m := setupListAWSResourcesV2(fixtures.FullCrawlerData(2), time.Now(), 1)
+	wr := httptest.NewRecorder()
+
+	r, _ := http.NewRequest("GET", "/api/v1/aws/mock?_limit=all", nil)
+	m.ServeHTTP(wr, r)
+	assert.JSONEq(t, `{"error": "Bad limit parameter"}`, wr.Body.String())
+
+	wr = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/api/v1/aws/mock", nil)
+	m.ServeHTTP(wr, r)
+	assert.JSONEq(t, `["i-0", "i-1"]`, wr.Body.String())
+}
@@ -2,10 +2,18 @@ package server
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/fields"
+	"github.com/alde/melkor/filter"
+	"github.com/alde/melkor/paging"
 )
 
 const (
@@ -28,6 +36,62 @@ func writeError(status int, message string, w http.ResponseWriter) error {
 	return writeJSON(status, data, w)
 }
 
+// Error codes of the v2 API, telling clients what went wrong without parsing
+// the message
+const (
+	codeNotFound         = "not_found"
+	codeUnknownParameter = "unknown_parameter"
+	codeInvalidParameter = "invalid_parameter"
+	codeInvalidFilter    = "invalid_filter"
+	codeInvalidCursor    = "invalid_cursor"
+)
+
+// An apiError is a request failing, written by v1 handlers as its message
+// alone and by v2 handlers as a structured error object. Param names the
+// parameter at fault, if any, and Position where in it the problem is.
+type apiError struct {
+	Status   int    `json:"-"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Param    string `json:"param,omitempty"`
+	Position int    `json:"position,omitempty"`
+}
+
+var errNotFound = &apiError{Status: http.StatusNotFound, Code: codeNotFound, Message: "Not Found"}
+
+// badParam creates the error of a malformed parameter
+func badParam(code, param, message string) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: code, Message: message, Param: param}
+}
+
+// writeAPIError writes an error as a structured error object
+func writeAPIError(e *apiError, w http.ResponseWriter) error {
+	return writeJSON(e.Status, map[string]*apiError{"error": e}, w)
+}
+
+// unknownParam returns the first parameter starting with '_' which is not
+// known, so that a misspelled one is not silently ignored
+func unknownParam(r *http.Request, known []string) string {
+	var names []string
+	for name := range r.URL.Query() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !strings.HasPrefix(name, "_") {
+			continue
+		}
+		ok := false
+		for _, k := range known {
+			ok = ok || name == k
+		}
+		if !ok {
+			return name
+		}
+	}
+	return ""
+}
+
 func parseLimit(r *http.Request) (int, error) {
 	l := r.FormValue("_limit")
 	if l == "" {
@@ -36,98 +100,70 @@ func parseLimit(r *http.Request) (int, error) {
 	return strconv.Atoi(l)
 }
 
-func applyLimit(data []string, limit int) []string {
-	if limit == 0 {
-		return data
-	}
-	var collection []string
-	for idx, el := range data {
-		if idx == limit {
-			break
-		}
-		collection = append(collection, el)
+// parseFields parses the fields to project expanded items onto. It returns nil
+// if all fields are wanted.
+func parseFields(r *http.Request) (*fields.Selection, error) {
+	f := r.FormValue("_fields")
+	if f == "" {
+		return nil, nil
 	}
-	return collection
+	return fields.Parse(f)
 }
 
-func applyLimitExpanded(data []map[string]interface{}, limit int) []map[string]interface{} {
-	if limit == 0 {
-		return data
-	}
-	var collection []map[string]interface{}
-	for idx, el := range data {
-		if idx == limit {
-			break
-		}
-		collection = append(collection, el)
-	}
-	return collection
+// parseAt parses the point in time to look at. The zero time means now.
+func parseAt(r *http.Request) (time.Time, error) {
+	return parseTime(r, "_at")
 }
 
-func applyFilter(filter string, data []map[string]interface{}) ([]map[string]interface{}, error) {
-	keys, value, err := parseFilter(filter)
-	if err != nil {
-		return data, err
+// parseTime parses a point in time, either as RFC 3339 or as milliseconds
+// since the epoch like Edda does. A missing parameter gives the zero time.
+func parseTime(r *http.Request, name string) (time.Time, error) {
+	a := r.FormValue(name)
+	if a == "" {
+		return time.Time{}, nil
 	}
-	var collection []map[string]interface{}
-	for i, el := range data {
-		if deepSearch(el, keys, value) {
-			collection = append(collection, data[i])
-		}
+	if ms, err := strconv.ParseInt(a, 10, 64); err == nil {
+		return time.Unix(0, ms*int64(time.Millisecond)), nil
 	}
-
-	return collection, nil
+	return time.Parse(time.RFC3339, a)
 }
 
-func deepSearch(el map[string]interface{}, keys []string, value string) bool {
-	k := keys[0]
-	if len(keys) == 1 {
-		v := el[k]
-		if v == nil {
-			return false
-		}
-
-		return strings.ToLower(el[k].(string)) == strings.ToLower(value)
-	}
-	tail := keys[1:]
-
-	switch el[k].(type) {
-	case map[string]interface{}:
-		return deepSearch(el[k].(map[string]interface{}), tail, value)
-	case []map[string]interface{}:
-		for _, b := range el[k].([]map[string]interface{}) {
-			if deepSearch(b, tail, value) {
-				return true
-			}
-		}
-	case []interface{}:
-		for _, b := range el[k].([]interface{}) {
-			if deepSearch(b.(map[string]interface{}), tail, value) {
-				return true
-			}
+// listRows lists the items of a snapshot to be filtered, sorted and paged
+// through. The items are only expanded if needed to do so, otherwise they are
+// expanded one at a time as they are written.
+func listRows(s *melkor.Snapshot, expand bool) []paging.Row {
+	rows := make([]paging.Row, s.Count())
+	for idx := range rows {
+		item := s.Item(idx)
+		rows[idx] = paging.Row{ID: item.ID, Account: item.Account, Region: item.Region, Index: idx}
+		if expand {
+			rows[idx].Doc = s.Expand(idx)
 		}
 	}
+	return rows
+}
 
-	return false
+// nextURL returns the URL of the page after a cursor, keeping all other
+// parameters of the request
+func nextURL(r *http.Request, cursor string) string {
+	q := r.URL.Query()
+	q.Set("_cursor", cursor)
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	return u.String()
 }
 
-func parseFilter(filter string) ([]string, string, error) {
-	if !strings.HasPrefix(filter, "(") && !strings.HasSuffix(filter, ")") {
-		return []string{}, "", errors.New("invalid format of filter, must be surrounded by '()'")
-	}
-	s := strings.Trim(filter, "()")
-	if strings.Count(s, ":") != 1 {
-		return []string{}, "", errors.New("invalid format of filter, only one ':' allowed")
-	}
-	if strings.HasPrefix(s, ".") || strings.HasSuffix(s, ".") || strings.HasPrefix(s, ":") || strings.HasSuffix(s, ":") {
-		return []string{}, "", errors.New("invalid format of filter, must not start or end with '.' or ':'")
-	}
-	if strings.Count(s, ".:") != 0 || strings.Count(s, ":.") != 0 {
-		return []string{}, "", errors.New("invalid format of filter, must not have '.' adjacent to ':'")
+// nextLink returns a Link header pointing to the page after a cursor
+func nextLink(r *http.Request, cursor string) string {
+	return fmt.Sprintf("<%s>; rel=\"next\"", nextURL(r, cursor))
+}
+
+// filterRows keeps the rows matching a parsed filter
+func filterRows(f *filter.Filter, rows []paging.Row) []paging.Row {
+	var collection []paging.Row
+	for _, row := range rows {
+		if f.Match(row.Doc) {
+			collection = append(collection, row)
+		}
 	}
-	splits := strings.Split(s, ":")
-	val := splits[1]
-	k0 := splits[0]
-	keys := strings.Split(k0, ".")
-	return keys, val, nil
+	return collection
 }
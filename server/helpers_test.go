@@ -6,6 +6,9 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/alde/melkor/filter"
+	"github.com/alde/melkor/paging"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,81 +61,25 @@ func Test_parseLimit_Invalid(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
-var filterTests = []struct {
-	input string
-	keys  []string
-	value string
-}{
-	{"(foo.bar:baz)", []string{"foo", "bar"}, "baz"},
-	{"(foo:baz)", []string{"foo"}, "baz"},
-	{"(egg.bacon.ham:breakfast)", []string{"egg", "bacon", "ham"}, "breakfast"},
-	{"(breakfast:bacon.ham)", []string{"breakfast"}, "bacon.ham"},
-}
-
-func Test_parseFilter(t *testing.T) {
-	for _, tt := range filterTests {
-		ak, av, _ := parseFilter(tt.input)
-		assert.Equal(t, tt.keys, ak)
-		assert.Equal(t, tt.value, av)
-	}
-}
-
-var badFilters = []string{
-	")(", "(foo.:)", "foo.bar:bib", "(foo:bar:baz)", "(foo.bar.baz)",
-}
-
-func Test_parseFilter_Fail(t *testing.T) {
-	for _, input := range badFilters {
-		_, _, err := parseFilter(input)
-		assert.NotNil(t, err, "Parsing %s", input)
-	}
-}
-
-func Test_deepSearch(t *testing.T) {
-	input := map[string]interface{}{
-		"foo": map[string]interface{}{
-			"bar": "baz",
-		},
-	}
-
-	res := deepSearch(input, []string{"foo", "bar"}, "baz")
-	assert.True(t, res)
-}
-
-func Test_deepSearch_Two(t *testing.T) {
-	input := map[string]interface{}{
-		"foo": []map[string]interface{}{
-			{"bar": "baz"},
-			{"bar": "bingo"},
-		},
+func docRows(docs []map[string]interface{}) []paging.Row {
+	rows := make([]paging.Row, len(docs))
+	for idx, doc := range docs {
+		rows[idx].Doc = doc
 	}
-
-	res := deepSearch(input, []string{"foo", "bar"}, "bingo")
-	assert.True(t, res)
+	return rows
 }
 
-func Test_deepSearch_Three(t *testing.T) {
-	var tags []interface{}
-	tags = append(tags, map[string]interface{}{
-		"Key":   "Team",
-		"Value": "TestTeam",
-		"Team":  "TestTeam",
-	})
-	input := map[string]interface{}{
-		"foo": []map[string]interface{}{
-			{"bar": "baz"},
-			{"bar": "bingo"},
-			{"tags": tags},
-		},
+func rowDocs(rows []paging.Row) []map[string]interface{} {
+	docs := make([]map[string]interface{}, len(rows))
+	for idx, row := range rows {
+		docs[idx] = row.Doc
 	}
-	t.Logf("%+v", input)
-
-	res := deepSearch(input, []string{"foo", "tags", "Team"}, "TestTeam")
-	assert.True(t, res)
+	return docs
 }
 
-func Test_applyFilter(t *testing.T) {
-	filter := "(foo.bar:baz)"
+func Test_filterRows(t *testing.T) {
+	f, err := filter.Parse("(foo.bar:baz)")
+	assert.Nil(t, err)
 	input := []map[string]interface{}{
 		{
 			"foo": map[string]interface{}{
@@ -146,20 +93,18 @@ func Test_applyFilter(t *testing.T) {
 		},
 	}
 
-	actual, err := applyFilter(filter, input)
-	if err != nil {
-		t.Error(err)
-	}
+	actual := filterRows(f, docRows(input))
 	expected := []map[string]interface{}{
 		{
 			"foo": map[string]interface{}{"bar": "baz"},
 		},
 	}
-	assert.Equal(t, expected, actual)
+	assert.Equal(t, expected, rowDocs(actual))
 }
 
-func Test_applyFilter_Two(t *testing.T) {
-	filter := "(foo.bar:bob)"
+func Test_filterRows_Two(t *testing.T) {
+	f, err := filter.Parse("(foo.bar:bob)")
+	assert.Nil(t, err)
 	input := []map[string]interface{}{
 		{
 			"foo": []map[string]interface{}{
@@ -176,14 +121,11 @@ func Test_applyFilter_Two(t *testing.T) {
 		},
 	}
 
-	actual, err := applyFilter(filter, input)
-	if err != nil {
-		t.Error(err)
-	}
+	actual := filterRows(f, docRows(input))
 	expected := []map[string]interface{}{
 		{
 			"foo": []map[string]interface{}{{"bar": "baz"}, {"bar": "bob"}},
 		},
 	}
-	assert.Equal(t, expected, actual)
+	assert.Equal(t, expected, rowDocs(actual))
 }
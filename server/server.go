@@ -20,7 +20,7 @@ func NewRouter(cfg *config.Config, crawlers melkor.Crawlers) *mux.Router {
 			Methods(route.Method).
 			Path(route.Pattern).
 			Name(route.Name).
-			Handler(prometheus.InstrumentHandler(route.Name, route.Handler))
+			Handler(prometheus.InstrumentHandler(route.Name, compress(route.Handler)))
 	}
 	return router
 }
@@ -33,25 +33,81 @@ type route struct {
 	Handler http.Handler
 }
 
+const (
+	// regionPattern matches AWS region names, such as eu-west-1 or
+	// us-gov-west-1
+	regionPattern = "{region:[a-z]{2}(?:-[a-z]+)+-[0-9]+}"
+	// accountPattern matches AWS account IDs
+	accountPattern = "{account:[0-9]{12}}"
+	// idPattern matches resource ids, which may hold slashes such as those of
+	// the ARNs of load balancers and target groups
+	idPattern = "{id:.+}"
+)
+
+// scopes lists the ways of narrowing down the resources, from the most to the
+// least specific: by account and region, by account, by region, and not at
+// all. Routes are matched in order, so the most specific ones must come first
+// as they would otherwise be taken for a less specific one. The patterns are
+// prefixed with the version of the API.
+var scopes = []struct {
+	name    string
+	pattern string
+}{
+	{"AccountRegional", "/accounts/" + accountPattern + "/aws/" + regionPattern},
+	{"Account", "/accounts/" + accountPattern + "/aws"},
+	{"Regional", "/aws/" + regionPattern},
+	{"", "/aws"},
+}
+
 func routes(h *Handler) []route {
-	return []route{
-		{
-			Name:    "ListResources",
-			Method:  "GET",
-			Pattern: "/api/v1/aws/{resource}",
-			Handler: h.ListAWSResources(),
-		},
-		{
-			Name:    "GetSingleResource",
+	// Every resource route exists once per scope, named after it, such as
+	// ListResources and ListAccountRegionalResources. The _watch and _diff
+	// routes come first as they would otherwise be taken for a resource.
+	// Version 2 of the API only lists resources so far, its routes are
+	// suffixed with V2.
+	resources := []struct {
+		verb    string
+		noun    string
+		pattern string
+		handler http.Handler
+	}{
+		{"WatchAll", "Resources", "/_watch", h.WatchAllAWSResources()},
+		{"Watch", "Resources", "/{resource}/_watch", h.WatchAWSResources()},
+		{"Diff", "Resources", "/{resource}/_diff", h.DiffAWSResources()},
+		{"DiffSingle", "Resource", "/{resource}/" + idPattern + "/_diff", h.DiffSingleAWSResource()},
+		{"List", "Resources", "/{resource}", h.ListAWSResources()},
+		{"GetSingle", "Resource", "/{resource}/" + idPattern, h.GetSingleAWSResource()},
+	}
+
+	var rs []route
+	for _, sc := range scopes {
+		for _, res := range resources {
+			rs = append(rs, route{
+				Name:    res.verb + sc.name + res.noun,
+				Method:  "GET",
+				Pattern: "/api/v1" + sc.pattern + res.pattern,
+				Handler: res.handler,
+			})
+		}
+		rs = append(rs, route{
+			Name:    "List" + sc.name + "ResourcesV2",
 			Method:  "GET",
-			Pattern: "/api/v1/aws/{resource}/{id}",
-			Handler: h.GetSingleAWSResource(),
-		},
-		{
+			Pattern: "/api/v2" + sc.pattern + "/{resource}",
+			Handler: h.ListAWSResourcesV2(),
+		})
+	}
+	return append(rs,
+		route{
 			Name:    "ServiceMetadata",
 			Method:  "GET",
 			Pattern: "/service-metadata",
 			Handler: h.ServiceMetadata(),
 		},
-	}
+		route{
+			Name:    "Metrics",
+			Method:  "GET",
+			Pattern: "/metrics",
+			Handler: prometheus.Handler(),
+		},
+	)
 }
@@ -1,11 +1,13 @@
 package server
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/alde/melkor"
 	"github.com/alde/melkor/config"
 
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,5 +27,24 @@ func Test_NewRouter(t *testing.T) {
 
 func Test_routes(t *testing.T) {
 	h := NewHandler(cfg, crw)
-	assert.Len(t, routes(h), 3, "3 routes is the magic number.")
+	assert.Len(t, routes(h), 30, "30 routes is the magic number.")
+}
+
+func Test_routes_SlashedIds(t *testing.T) {
+	nr := NewRouter(cfg, crw)
+	arn := "arn:aws:elasticloadbalancing:eu-west-1:123456789012:targetgroup/tg-0/73e2d6bc24d8a067"
+
+	for path, name := range map[string]string{
+		"/api/v1/aws/targetgroups/" + arn:                                 "GetSingleResource",
+		"/api/v1/aws/targetgroups/" + arn + "/_diff":                      "DiffSingleResource",
+		"/api/v1/aws/eu-west-1/targetgroups/" + arn:                       "GetSingleRegionalResource",
+		"/api/v1/accounts/123456789012/aws/eu-west-1/targetgroups/" + arn: "GetSingleAccountRegionalResource",
+	} {
+		r, _ := http.NewRequest("GET", path, nil)
+		var match mux.RouteMatch
+		if assert.True(t, nr.Match(r, &match), path) {
+			assert.Equal(t, name, match.Route.GetName(), path)
+			assert.Equal(t, arn, match.Vars["id"], path)
+		}
+	}
 }
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// A stream is a list of items built and encoded one at a time as they are
+// written, rather than all of them up front, so that listing a large
+// collection does not hold all of it in memory at once. It stops early once
+// the context is done, such as when the client went away.
+type stream struct {
+	ctx  context.Context
+	n    int
+	item func(idx int) interface{}
+}
+
+// sliceStream streams items already built
+func sliceStream(ctx context.Context, items []interface{}) *stream {
+	return &stream{ctx: ctx, n: len(items), item: func(idx int) interface{} {
+		return items[idx]
+	}}
+}
+
+// each calls fn with every item in turn, stopping at the first error
+func (s *stream) each(fn func(item interface{}) error) error {
+	for idx := 0; idx < s.n; idx++ {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(s.item(idx)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSON writes the items as a JSON array, one item at a time
+func (s *stream) writeJSON(w io.Writer) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	sep := ""
+	err := s.each(func(item interface{}) error {
+		b, err := encode(item)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		sep = ","
+		_, err = w.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+// encode encodes an item as JSON, unless it is already
+func encode(item interface{}) ([]byte, error) {
+	if raw, ok := item.(json.RawMessage); ok {
+		return raw, nil
+	}
+	return json.Marshal(item)
+}
+
+// MarshalJSON encodes all items at once, for formats needing the whole
+// response such as YAML
+func (s *stream) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	err := s.writeJSON(&buf)
+	return buf.Bytes(), err
+}
+
+// A jsonStreamer writes itself as JSON a piece at a time
+type jsonStreamer interface {
+	writeJSON(w io.Writer) error
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_stream(t *testing.T) {
+	s := sliceStream(context.Background(), []interface{}{"i-0", map[string]interface{}{"a": 1}})
+
+	var buf bytes.Buffer
+	assert.Nil(t, s.writeJSON(&buf))
+	assert.Equal(t, `["i-0",{"a":1}]`, buf.String())
+
+	b, err := json.Marshal(map[string]interface{}{"items": s})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"items":["i-0",{"a":1}]}`, string(b))
+
+	buf.Reset()
+	assert.Nil(t, sliceStream(context.Background(), nil).writeJSON(&buf))
+	assert.Equal(t, `[]`, buf.String())
+}
+
+func Test_stream_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var built []int
+	s := &stream{ctx: ctx, n: 100, item: func(idx int) interface{} {
+		built = append(built, idx)
+		if idx == 2 {
+			cancel()
+		}
+		return idx
+	}}
+
+	var buf bytes.Buffer
+	err := s.writeJSON(&buf)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []int{0, 1, 2}, built, "no items are built once the client is gone")
+	assert.Equal(t, `[0,1,2`, buf.String())
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/filter"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// eventBacklog is the number of events kept for clients resuming with
+	// Last-Event-ID
+	eventBacklog = 1000
+	// subscriptionBuffer is the number of events a client may lag behind
+	// before being disconnected. It can resume with Last-Event-ID.
+	subscriptionBuffer = 256
+	// keepAlive is how often an idle stream gets a comment, to keep proxies
+	// from closing it
+	keepAlive = 30 * time.Second
+	// resyncEvent tells a resuming client that the events it missed are
+	// unknown, and that it should list the resources anew
+	resyncEvent = "resync"
+)
+
+// An event is a single change of a crawled resource, as streamed to watching
+// clients
+type event struct {
+	seq uint64
+	// id is the event id sent to clients, the seq prefixed with the epoch
+	id       string
+	Type     string `json:"type"`
+	Resource string `json:"resource"`
+	melkor.Scope
+	ID        string                 `json:"id"`
+	CrawledAt time.Time              `json:"crawled_at"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// A hub fans out the changes found by the crawlers to the clients watching
+// them. The most recent events are kept, so that clients can resume where
+// they left off after reconnecting.
+//
+// Event ids count from the start of the process, and are prefixed with the
+// time it started as an epoch, so that ids handed out before a restart are not
+// mistaken for current ones.
+type hub struct {
+	epoch   int64
+	mu      sync.Mutex
+	lastID  uint64
+	backlog []event
+	subs    map[chan event]*subscription
+}
+
+func newHub() *hub {
+	return &hub{
+		epoch: time.Now().UnixNano(),
+		subs:  make(map[chan event]*subscription),
+	}
+}
+
+// A subscription tells which events a client watches: those of a resource, or
+// of all resources if empty, within the requested account and region and
+// matching a filter, if any. Resync events are always wanted.
+type subscription struct {
+	resource string
+	vars     map[string]string
+	match    func(map[string]interface{}) bool
+}
+
+// wants checks whether an event is to be sent to the subscriber
+func (s *subscription) wants(ev event) bool {
+	if ev.Type == resyncEvent {
+		return true
+	}
+	if s.resource != "" && ev.Resource != s.resource {
+		return false
+	}
+	if !inScope(s.vars, ev.Scope) {
+		return false
+	}
+	return s.match == nil || s.match(ev.Data)
+}
+
+// A cursor is where a client resuming with Last-Event-ID left off
+type cursor struct {
+	epoch int64
+	seq   uint64
+}
+
+// parseEventID parses an event id, as sent by eventID
+func parseEventID(id string) (*cursor, error) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("bad event id %q", id)
+	}
+	epoch, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad event id %q", id)
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad event id %q", id)
+	}
+	return &cursor{epoch: epoch, seq: seq}, nil
+}
+
+// eventID returns the id sent to clients for an event
+func (hb *hub) eventID(seq uint64) string {
+	return fmt.Sprintf("%d-%d", hb.epoch, seq)
+}
+
+// publish turns a changeset of a resource into events and hands them to the
+// subscribers wanting them. Subscribers lagging too far behind are dropped
+// rather than blocking the crawler. The first crawl of a scope is skipped, as it would
+// announce every resource as added whenever Melkor starts.
+func (hb *hub) publish(resource string, cs melkor.Changeset) {
+	if cs.Initial {
+		logrus.WithFields(logrus.Fields{"resource": resource, "scope": cs.Scope.String()}).Debug("Not sending initial crawl to watchers")
+		return
+	}
+
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	for _, c := range cs.Changes {
+		hb.lastID++
+		ev := event{
+			seq:       hb.lastID,
+			id:        hb.eventID(hb.lastID),
+			Type:      c.Type,
+			Resource:  resource,
+			Scope:     c.Scope,
+			ID:        c.ID,
+			CrawledAt: cs.CrawledAt,
+			Data:      c.Data,
+		}
+		hb.backlog = append(hb.backlog, ev)
+		for sub, want := range hb.subs {
+			if !want.wants(ev) {
+				continue
+			}
+			select {
+			case sub <- ev:
+			default:
+				delete(hb.subs, sub)
+				close(sub)
+			}
+		}
+	}
+	if len(hb.backlog) > eventBacklog {
+		hb.backlog = append([]event(nil), hb.backlog[len(hb.backlog)-eventBacklog:]...)
+	}
+}
+
+// subscribe registers a new subscriber. If resuming, the events it wants after
+// the last one seen are returned, to be sent before any new ones. If they are
+// not all known, because the last one seen is from before a restart or has
+// left the backlog, a single resync event is returned instead.
+func (hb *hub) subscribe(want *subscription, resume *cursor) (chan event, []event) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	var missed []event
+	if resume != nil {
+		missed = hb.since(want, *resume)
+	}
+	sub := make(chan event, subscriptionBuffer)
+	hb.subs[sub] = want
+	return sub, missed
+}
+
+// since returns the events wanted after a cursor. Callers must hold the lock.
+func (hb *hub) since(want *subscription, c cursor) []event {
+	known := c.epoch == hb.epoch && c.seq <= hb.lastID
+	if len(hb.backlog) > 0 && c.seq+1 < hb.backlog[0].seq {
+		known = false
+	}
+	if !known {
+		return []event{{seq: hb.lastID, id: hb.eventID(hb.lastID), Type: resyncEvent}}
+	}
+	var missed []event
+	for _, ev := range hb.backlog {
+		if ev.seq > c.seq && want.wants(ev) {
+			missed = append(missed, ev)
+		}
+	}
+	return missed
+}
+
+// unsubscribe removes a subscriber, unless it has already been dropped
+func (hb *hub) unsubscribe(sub chan event) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	if _, ok := hb.subs[sub]; ok {
+		delete(hb.subs, sub)
+		close(sub)
+	}
+}
+
+// WatchAWSResources streams the changes of the requested resource as
+// Server-Sent Events
+func (h *Handler) WatchAWSResources() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		crawler := h.crawler(vars)
+		if crawler == nil {
+			notFound(w)
+			return
+		}
+		h.watch(vars, crawler.Resource(), w, r)
+	}
+}
+
+// WatchAllAWSResources streams the changes of all resources as Server-Sent
+// Events
+func (h *Handler) WatchAllAWSResources() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.watch(mux.Vars(r), "", w, r)
+	}
+}
+
+// watch streams the events of a resource, or all resources if empty, within
+// the requested account and region. Events can be filtered like lists of
+// expanded resources.
+func (h *Handler) watch(vars map[string]string, resource string, w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(http.StatusInternalServerError, "Streaming unsupported", w)
+		return
+	}
+	want := &subscription{resource: resource, vars: vars}
+	if expr := r.FormValue("_filter"); expr != "" {
+		f, err := filter.Parse(expr)
+		if err != nil {
+			writeError(http.StatusBadRequest, err.Error(), w)
+			return
+		}
+		want.match = f.Match
+	}
+	var resume *cursor
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID != "" {
+		var err error
+		resume, err = parseEventID(lastEventID)
+		if err != nil {
+			writeError(http.StatusBadRequest, "Bad Last-Event-ID header", w)
+			return
+		}
+	}
+
+	sub, missed := h.hub.subscribe(want, resume)
+	defer h.hub.unsubscribe(sub)
+	logrus.WithFields(logrus.Fields{"resource": resource, "last_event_id": lastEventID}).Debug("Watching resources")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for _, ev := range missed {
+		if err := writeEvent(ev, w); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, ok := <-sub:
+			if !ok {
+				logrus.WithField("resource", resource).Debug("Dropped lagging watcher")
+				return
+			}
+			if err := writeEvent(ev, w); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(ev event, w http.ResponseWriter) error {
+	var data interface{} = ev
+	if ev.Type == resyncEvent {
+		data = map[string]string{"type": resyncEvent}
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.id, ev.Type, b)
+	return err
+}
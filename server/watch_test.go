@@ -0,0 +1,382 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/mock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	watchEU = melkor.Scope{Region: "eu-west-1"}
+	watchUS = melkor.Scope{Region: "us-east-1"}
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  map[string]interface{}
+}
+
+func change(typ string, sc melkor.Scope, id, team string) melkor.Change {
+	return melkor.Change{
+		Scope: sc,
+		Type:  typ,
+		ID:    id,
+		Data:  map[string]interface{}{"InstanceId": id, "Tags": map[string]interface{}{"Team": team}},
+	}
+}
+
+// watch opens a stream, returning its events as they arrive
+func watch(t *testing.T, srv *httptest.Server, path, lastEventID string) (*http.Response, <-chan sseEvent) {
+	req, _ := http.NewRequest("GET", srv.URL+path, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	assert.Nil(t, err)
+
+	events := make(chan sseEvent)
+	go func() {
+		defer close(events)
+		if resp.StatusCode != http.StatusOK {
+			return
+		}
+		r := bufio.NewReader(resp.Body)
+		var ev sseEvent
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case line == "":
+				events <- ev
+				ev = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data)
+			}
+		}
+	}()
+	return resp, events
+}
+
+func next(t *testing.T, events <-chan sseEvent) sseEvent {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return sseEvent{}
+}
+
+func setupWatch() (*httptest.Server, *mock.InstanceCrawler, *mock.InstanceCrawler) {
+	instances := &mock.InstanceCrawler{ResourceFn: func() string { return "Instances" }}
+	volumes := &mock.InstanceCrawler{ResourceFn: func() string { return "Volumes" }}
+	srv := httptest.NewServer(NewRouter(&config.Config{}, melkor.Crawlers{
+		"Instances": instances,
+		"Volumes":   volumes,
+	}))
+	return srv, instances, volumes
+}
+
+func Test_WatchAWSResources(t *testing.T) {
+	srv, instances, volumes := setupWatch()
+	defer srv.Close()
+
+	resp, events := watch(t, srv, "/api/v1/aws/instances/_watch", "")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	crawled := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	volumes.Notify(melkor.Changeset{Scope: watchEU, CrawledAt: crawled, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "vol-0", "a"),
+	}})
+	instances.Notify(melkor.Changeset{Scope: watchEU, CrawledAt: crawled, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-0", "a"),
+		change(melkor.Removed, watchEU, "i-1", "b"),
+	}})
+
+	ev := next(t, events)
+	assert.True(t, strings.HasSuffix(ev.id, "-2"), ev.id)
+	assert.Equal(t, "added", ev.event)
+	assert.Equal(t, "i-0", ev.data["id"])
+	assert.Equal(t, "Instances", ev.data["resource"])
+	assert.Equal(t, "eu-west-1", ev.data["region"])
+	assert.Equal(t, "2017-03-01T12:00:00Z", ev.data["crawled_at"])
+	assert.Equal(t, "i-0", ev.data["data"].(map[string]interface{})["InstanceId"])
+
+	ev = next(t, events)
+	assert.True(t, strings.HasSuffix(ev.id, "-3"), ev.id)
+	assert.Equal(t, "removed", ev.event)
+	assert.Equal(t, "i-1", ev.data["id"])
+}
+
+func Test_WatchAWSResources_Filter(t *testing.T) {
+	srv, instances, _ := setupWatch()
+	defer srv.Close()
+
+	resp, events := watch(t, srv, "/api/v1/aws/us-east-1/instances/_watch?_filter=(Tags.Team:b)", "")
+	defer resp.Body.Close()
+
+	instances.Notify(melkor.Changeset{Scope: watchEU, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-0", "b"),
+	}})
+	instances.Notify(melkor.Changeset{Scope: watchUS, Changes: []melkor.Change{
+		change(melkor.Added, watchUS, "i-1", "a"),
+		change(melkor.Modified, watchUS, "i-2", "b"),
+	}})
+
+	ev := next(t, events)
+	assert.Equal(t, "modified", ev.event)
+	assert.Equal(t, "i-2", ev.data["id"])
+}
+
+func Test_WatchAllAWSResources(t *testing.T) {
+	srv, instances, volumes := setupWatch()
+	defer srv.Close()
+
+	resp, events := watch(t, srv, "/api/v1/aws/_watch", "")
+	defer resp.Body.Close()
+
+	instances.Notify(melkor.Changeset{Scope: watchEU, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-0", "a"),
+	}})
+	volumes.Notify(melkor.Changeset{Scope: watchEU, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "vol-0", "a"),
+	}})
+
+	assert.Equal(t, "Instances", next(t, events).data["resource"])
+	assert.Equal(t, "Volumes", next(t, events).data["resource"])
+}
+
+func Test_WatchAWSResources_Resume(t *testing.T) {
+	srv, instances, _ := setupWatch()
+	defer srv.Close()
+
+	resp, events := watch(t, srv, "/api/v1/aws/instances/_watch", "")
+	instances.Notify(melkor.Changeset{Scope: watchEU, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-0", "a"),
+		change(melkor.Added, watchEU, "i-1", "a"),
+		change(melkor.Added, watchEU, "i-2", "a"),
+	}})
+	first := next(t, events).id
+	resp.Body.Close()
+
+	resp, events = watch(t, srv, "/api/v1/aws/instances/_watch", first)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "i-1", next(t, events).data["id"])
+	assert.Equal(t, "i-2", next(t, events).data["id"])
+
+	instances.Notify(melkor.Changeset{Scope: watchEU, Changes: []melkor.Change{
+		change(melkor.Modified, watchEU, "i-0", "b"),
+	}})
+	ev := next(t, events)
+	assert.True(t, strings.HasSuffix(ev.id, "-4"), ev.id)
+	assert.Equal(t, "i-0", ev.data["id"])
+}
+
+func Test_WatchAWSResources_SkipsInitial(t *testing.T) {
+	srv, instances, _ := setupWatch()
+	defer srv.Close()
+
+	resp, events := watch(t, srv, "/api/v1/aws/instances/_watch", "")
+	defer resp.Body.Close()
+
+	instances.Notify(melkor.Changeset{Scope: watchEU, Initial: true, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-0", "a"),
+	}})
+	instances.Notify(melkor.Changeset{Scope: watchEU, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-1", "a"),
+	}})
+
+	ev := next(t, events)
+	assert.True(t, strings.HasSuffix(ev.id, "-1"), ev.id)
+	assert.Equal(t, "i-1", ev.data["id"])
+}
+
+func Test_WatchAWSResources_Resync(t *testing.T) {
+	before, instances, _ := setupWatch()
+	defer before.Close()
+
+	resp, events := watch(t, before, "/api/v1/aws/instances/_watch", "")
+	instances.Notify(melkor.Changeset{Scope: watchEU, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-0", "a"),
+	}})
+	last := next(t, events).id
+	resp.Body.Close()
+
+	// A restart begins a new epoch, in which the old ids mean nothing
+	time.Sleep(time.Millisecond)
+	after, instances, _ := setupWatch()
+	defer after.Close()
+	instances.Notify(melkor.Changeset{Scope: watchEU, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-1", "a"),
+	}})
+
+	resp, events = watch(t, after, "/api/v1/aws/instances/_watch", last)
+	defer resp.Body.Close()
+
+	ev := next(t, events)
+	assert.Equal(t, "resync", ev.event)
+	assert.Equal(t, "resync", ev.data["type"])
+	assert.True(t, strings.HasSuffix(ev.id, "-1"), ev.id)
+	assert.NotEqual(t, last, ev.id)
+
+	instances.Notify(melkor.Changeset{Scope: watchEU, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-2", "a"),
+	}})
+	assert.Equal(t, "i-2", next(t, events).data["id"])
+}
+
+func Test_WatchAWSResources_BusyOtherResource(t *testing.T) {
+	srv, instances, volumes := setupWatch()
+	defer srv.Close()
+
+	resp, events := watch(t, srv, "/api/v1/aws/instances/_watch", "")
+	defer resp.Body.Close()
+
+	var changes []melkor.Change
+	for n := 0; n <= 2*subscriptionBuffer; n++ {
+		changes = append(changes, change(melkor.Modified, watchEU, "vol-0", "a"))
+	}
+	volumes.Notify(melkor.Changeset{Scope: watchEU, Changes: changes})
+	instances.Notify(melkor.Changeset{Scope: watchEU, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-0", "a"),
+	}})
+
+	assert.Equal(t, "i-0", next(t, events).data["id"])
+}
+
+func Test_WatchAWSResources_Errors(t *testing.T) {
+	srv, _, _ := setupWatch()
+	defer srv.Close()
+
+	for path, code := range map[string]int{
+		"/api/v1/aws/unknown/_watch":                   http.StatusNotFound,
+		"/api/v1/aws/instances/_watch?_filter=(Team:a": http.StatusBadRequest,
+		"/api/v1/aws/_watch?_filter=(Team::a)":         http.StatusBadRequest,
+	} {
+		resp, _ := watch(t, srv, path, "")
+		resp.Body.Close()
+		assert.Equal(t, code, resp.StatusCode, path)
+	}
+
+	for _, id := range []string{"yesterday", "1", "1-x"} {
+		resp, _ := watch(t, srv, "/api/v1/aws/instances/_watch", id)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, id)
+	}
+}
+
+func Test_hub_DropsLaggingSubscribers(t *testing.T) {
+	hb := newHub()
+	sub, _ := hb.subscribe(&subscription{}, nil)
+
+	var changes []melkor.Change
+	for n := 0; n <= subscriptionBuffer; n++ {
+		changes = append(changes, change(melkor.Added, watchEU, "i-0", "a"))
+	}
+	hb.publish("Instances", melkor.Changeset{Changes: changes})
+
+	var received int
+	for range sub {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+	assert.Len(t, hb.subs, 0)
+	hb.unsubscribe(sub)
+}
+
+func Test_hub_Backlog(t *testing.T) {
+	hb := newHub()
+
+	var changes []melkor.Change
+	for n := 0; n < eventBacklog+10; n++ {
+		changes = append(changes, change(melkor.Added, watchEU, "i-0", "a"))
+	}
+	hb.publish("Instances", melkor.Changeset{Changes: changes})
+
+	_, missed := hb.subscribe(&subscription{}, &cursor{epoch: hb.epoch, seq: 10})
+	assert.Len(t, missed, eventBacklog)
+	assert.Equal(t, uint64(11), missed[0].seq)
+
+	_, missed = hb.subscribe(&subscription{}, nil)
+	assert.Empty(t, missed)
+}
+
+func Test_hub_Subscription(t *testing.T) {
+	hb := newHub()
+	want := &subscription{resource: "Instances", vars: map[string]string{"region": "eu-west-1"}}
+	quiet, _ := hb.subscribe(want, nil)
+
+	var changes []melkor.Change
+	for n := 0; n <= subscriptionBuffer; n++ {
+		changes = append(changes, change(melkor.Added, watchEU, "vol-0", "a"))
+	}
+	hb.publish("Volumes", melkor.Changeset{Changes: changes})
+	hb.publish("Instances", melkor.Changeset{Changes: []melkor.Change{
+		change(melkor.Added, watchUS, "i-0", "a"),
+		change(melkor.Added, watchEU, "i-1", "a"),
+	}})
+
+	assert.Len(t, hb.subs, 1, "busy unrelated resources do not drop a watcher")
+	assert.Len(t, quiet, 1)
+	assert.Equal(t, "i-1", (<-quiet).ID)
+
+	_, missed := hb.subscribe(want, &cursor{epoch: hb.epoch, seq: 0})
+	if assert.Len(t, missed, 1) {
+		assert.Equal(t, "i-1", missed[0].ID)
+	}
+}
+
+func Test_hub_Resync(t *testing.T) {
+	hb := newHub()
+
+	var changes []melkor.Change
+	for n := 0; n < eventBacklog+10; n++ {
+		changes = append(changes, change(melkor.Added, watchEU, "i-0", "a"))
+	}
+	hb.publish("Instances", melkor.Changeset{Changes: changes})
+
+	for name, c := range map[string]cursor{
+		"evicted":       {epoch: hb.epoch, seq: 9},
+		"other epoch":   {epoch: hb.epoch - 1, seq: 500},
+		"unknown event": {epoch: hb.epoch, seq: eventBacklog + 11},
+	} {
+		_, missed := hb.subscribe(&subscription{}, &c)
+		if assert.Len(t, missed, 1, name) {
+			assert.Equal(t, resyncEvent, missed[0].Type, name)
+			assert.Equal(t, hb.eventID(eventBacklog+10), missed[0].id, name)
+		}
+	}
+}
+
+func Test_hub_SkipsInitial(t *testing.T) {
+	hb := newHub()
+	sub, _ := hb.subscribe(&subscription{}, nil)
+
+	hb.publish("Instances", melkor.Changeset{Initial: true, Changes: []melkor.Change{
+		change(melkor.Added, watchEU, "i-0", "a"),
+	}})
+	assert.Empty(t, hb.backlog)
+	assert.Len(t, sub, 0)
+	assert.Equal(t, uint64(0), hb.lastID)
+}
@@ -0,0 +1,354 @@
+package melkor
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ExpandFunc turns a crawled item into its expanded, filterable form. It must
+// return a new map on every call, which the Snapshot is free to modify.
+type ExpandFunc func(item interface{}) map[string]interface{}
+
+// A Scope identifies a single account and region being crawled. Account is
+// empty when crawling with the default credentials.
+type Scope struct {
+	Account string `json:"account,omitempty"`
+	Region  string `json:"region"`
+}
+
+// Less orders scopes by account, then region
+func (s Scope) Less(o Scope) bool {
+	if s.Account != o.Account {
+		return s.Account < o.Account
+	}
+	return s.Region < o.Region
+}
+
+func (s Scope) String() string {
+	if s.Account == "" {
+		return s.Region
+	}
+	return s.Account + "/" + s.Region
+}
+
+// An Item is a single crawled resource
+type Item struct {
+	ID      string
+	Account string
+	Region  string
+	Value   interface{}
+}
+
+// A Snapshot is an immutable view of a single completed crawl. Crawlers build
+// a new Snapshot once a crawl has finished and swap it in as a whole, so a
+// reader holding on to a Snapshot always sees the result of exactly one crawl,
+// no matter how many crawls complete in the meantime.
+//
+// A Snapshot spanning several accounts or regions is merged from one Snapshot
+// per Scope, see Merge.
+//
+// Every item is expanded and encoded as JSON at most once, when first needed,
+// which for crawled items is when the crawl is recorded in the History. The
+// expanded items handed out are shared between all readers, and must not be
+// modified.
+//
+// The zero value is an empty Snapshot.
+type Snapshot struct {
+	crawled time.Time
+	items   []Item
+	// entries hold what is computed of each item, shared with the snapshots
+	// merged from or into this one
+	entries []*entry
+	expand  ExpandFunc
+	parts   map[Scope]*Snapshot
+	// generation counts the snapshots swapped in by the crawler
+	generation uint64
+	// unrecorded lists the fields of expanded items kept out of the History
+	unrecorded []string
+
+	// index maps every id to the items with it, and keys to the item with
+	// it, built when first needed
+	indexOnce sync.Once
+	index     map[string][]int
+	keys      map[Key]int
+}
+
+// An entry is an item as expanded, tagged with its account and region and
+// normalized like JSON would, along with its JSON encoding
+type entry struct {
+	once sync.Once
+	doc  map[string]interface{}
+	json []byte
+}
+
+// NewSnapshot creates a Snapshot from the crawled items. The items must not be
+// modified after being handed over.
+func NewSnapshot(crawled time.Time, items []Item, expand ExpandFunc) *Snapshot {
+	entries := make([]*entry, len(items))
+	for idx := range entries {
+		entries[idx] = &entry{}
+	}
+	return &Snapshot{
+		crawled: crawled,
+		items:   items,
+		entries: entries,
+		expand:  expand,
+	}
+}
+
+// Merge creates a Snapshot holding the items of one Snapshot per Scope, in
+// order of account and region. All parts must share the same ExpandFunc. The
+// merged Snapshot is considered crawled when its most recently crawled part
+// was.
+func Merge(parts map[Scope]*Snapshot) *Snapshot {
+	var scopes []Scope
+	for sc := range parts {
+		scopes = append(scopes, sc)
+	}
+	SortScopes(scopes)
+
+	merged := &Snapshot{parts: make(map[Scope]*Snapshot)}
+	for _, sc := range scopes {
+		p := parts[sc]
+		merged.parts[sc] = p
+		merged.items = append(merged.items, p.items...)
+		merged.entries = append(merged.entries, p.entries...)
+		if p.expand != nil {
+			merged.expand = p.expand
+		}
+		if p.crawled.After(merged.crawled) {
+			merged.crawled = p.crawled
+		}
+	}
+	return merged
+}
+
+// Region returns the part of a merged Snapshot crawled from a single region,
+// or nil if there is no such region
+func (s *Snapshot) Region(region string) *Snapshot {
+	return s.narrow(func(sc Scope) bool { return sc.Region == region })
+}
+
+// Account returns the part of a merged Snapshot crawled from a single account,
+// or nil if there is no such account
+func (s *Snapshot) Account(account string) *Snapshot {
+	return s.narrow(func(sc Scope) bool { return sc.Account == account })
+}
+
+func (s *Snapshot) narrow(match func(Scope) bool) *Snapshot {
+	parts := make(map[Scope]*Snapshot)
+	for sc, p := range s.parts {
+		if match(sc) {
+			parts[sc] = p
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	m := Merge(parts)
+	m.generation = s.generation
+	return m
+}
+
+// CrawledAt is the time the crawl producing the Snapshot finished
+func (s *Snapshot) CrawledAt() time.Time {
+	return s.crawled
+}
+
+// Generation tells how many snapshots the crawler had swapped in when it swapped
+// in this one, so that clients can tell whether the items changed between two
+// requests. It is kept when narrowing down to an account or region, and is
+// zero for snapshots looked up in the History.
+func (s *Snapshot) Generation() uint64 {
+	return s.generation
+}
+
+// WithGeneration returns a copy of the Snapshot of the given generation
+func (s *Snapshot) WithGeneration(generation uint64) *Snapshot {
+	return &Snapshot{
+		crawled:    s.crawled,
+		items:      s.items,
+		entries:    s.entries,
+		expand:     s.expand,
+		parts:      s.parts,
+		generation: generation,
+		unrecorded: s.unrecorded,
+	}
+}
+
+// WithUnrecorded returns a copy of the Snapshot whose items are recorded in a
+// History without some of their fields. These are served like any other, but
+// changes to them alone record no new version. It is meant for fields derived
+// from other resources, which would otherwise change on every crawl of those.
+func (s *Snapshot) WithUnrecorded(fields ...string) *Snapshot {
+	return &Snapshot{
+		crawled:    s.crawled,
+		items:      s.items,
+		entries:    s.entries,
+		expand:     s.expand,
+		parts:      s.parts,
+		generation: s.generation,
+		unrecorded: fields,
+	}
+}
+
+// Count the number of items in the Snapshot
+func (s *Snapshot) Count() int {
+	return len(s.items)
+}
+
+// List the ids of all items
+func (s *Snapshot) List() []string {
+	data := make([]string, len(s.items))
+	for idx, item := range s.items {
+		data[idx] = item.ID
+	}
+	return data
+}
+
+// Item returns the item at an index of List
+func (s *Snapshot) Item(idx int) Item {
+	return s.items[idx]
+}
+
+// Expand returns the expanded item at an index of List, so that callers can
+// go through the items one at a time rather than all of them at once
+func (s *Snapshot) Expand(idx int) map[string]interface{} {
+	return s.entry(idx).doc
+}
+
+// JSON returns the expanded item at an index of List encoded as JSON, or nil
+// if it cannot be encoded
+func (s *Snapshot) JSON(idx int) []byte {
+	return s.entry(idx).json
+}
+
+// Key returns the key of the item at an index of List
+func (s *Snapshot) Key(idx int) Key {
+	item := s.items[idx]
+	return Key{Scope: Scope{Account: item.Account, Region: item.Region}, ID: item.ID}
+}
+
+// IndexOf returns the index in List of the first item with an id, or -1 if
+// there is none. The same id may be found in several accounts or regions, see
+// Lookup.
+func (s *Snapshot) IndexOf(id string) int {
+	if idxs := s.Lookup(id); len(idxs) > 0 {
+		return idxs[0]
+	}
+	return -1
+}
+
+// Lookup returns the indexes in List of all items with an id, one per account
+// and region it is found in
+func (s *Snapshot) Lookup(id string) []int {
+	s.buildIndex()
+	return s.index[id]
+}
+
+// IndexOfKey returns the index in List of the item with a key, or -1 if there
+// is none
+func (s *Snapshot) IndexOfKey(k Key) int {
+	s.buildIndex()
+	if idx, ok := s.keys[k]; ok {
+		return idx
+	}
+	return -1
+}
+
+func (s *Snapshot) buildIndex() {
+	s.indexOnce.Do(func() {
+		s.index = make(map[string][]int, len(s.items))
+		s.keys = make(map[Key]int, len(s.items))
+		for idx, item := range s.items {
+			s.index[item.ID] = append(s.index[item.ID], idx)
+			s.keys[s.Key(idx)] = idx
+		}
+	})
+}
+
+// ListExpanded returns all expanded items
+func (s *Snapshot) ListExpanded() []map[string]interface{} {
+	data := make([]map[string]interface{}, len(s.items))
+	for idx := range s.items {
+		data[idx] = s.entry(idx).doc
+	}
+	return data
+}
+
+// Get returns a single expanded item by id, or nil if it does not exist. If
+// the id is found in several accounts or regions, the first one is returned.
+func (s *Snapshot) Get(id string) map[string]interface{} {
+	idx := s.IndexOf(id)
+	if idx < 0 {
+		return nil
+	}
+	return s.entry(idx).doc
+}
+
+// entry returns the entry of the item at an index, computing it if it is the
+// first time it is needed. Items which cannot be encoded are left as
+// expanded, without JSON.
+func (s *Snapshot) entry(idx int) *entry {
+	e := s.entries[idx]
+	e.once.Do(func() {
+		item := s.items[idx]
+		doc := s.expand(item.Value)
+		if item.Account != "" {
+			doc["AccountId"] = item.Account
+		}
+		if item.Region != "" {
+			doc["Region"] = item.Region
+		}
+		e.doc = doc
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return
+		}
+		var normalized map[string]interface{}
+		if err := json.Unmarshal(b, &normalized); err != nil {
+			return
+		}
+		e.doc, e.json = normalized, b
+	})
+	return e
+}
+
+// recorded returns the expanded item at an index as recorded in a History,
+// without the account and region it was tagged with nor its unrecorded fields
+func (s *Snapshot) recorded(idx int) map[string]interface{} {
+	item, doc := s.items[idx], s.entry(idx).doc
+	if item.Account == "" && item.Region == "" && len(s.unrecorded) == 0 {
+		return doc
+	}
+	data := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		if k == "AccountId" && item.Account != "" || k == "Region" && item.Region != "" || s.isUnrecorded(k) {
+			continue
+		}
+		data[k] = v
+	}
+	return data
+}
+
+func (s *Snapshot) isUnrecorded(field string) bool {
+	for _, f := range s.unrecorded {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// SortScopes sorts scopes by account, then region
+func SortScopes(scopes []Scope) {
+	sort.Sort(byScope(scopes))
+}
+
+type byScope []Scope
+
+func (s byScope) Len() int           { return len(s) }
+func (s byScope) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byScope) Less(i, j int) bool { return s[i].Less(s[j]) }
@@ -0,0 +1,139 @@
+package melkor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func identity(item interface{}) map[string]interface{} {
+	data := make(map[string]interface{})
+	for k, v := range item.(map[string]interface{}) {
+		data[k] = v
+	}
+	return data
+}
+
+func items(sc Scope, ids ...string) []Item {
+	var data []Item
+	for _, id := range ids {
+		data = append(data, Item{
+			ID:      id,
+			Account: sc.Account,
+			Region:  sc.Region,
+			Value:   map[string]interface{}{"id": id},
+		})
+	}
+	return data
+}
+
+func Test_Snapshot_Empty(t *testing.T) {
+	s := &Snapshot{}
+
+	assert.Equal(t, 0, s.Count())
+	assert.Empty(t, s.List())
+	assert.NotNil(t, s.List())
+	assert.Empty(t, s.ListExpanded())
+	assert.Nil(t, s.Get("i-0"))
+	assert.Nil(t, s.Region("eu-west-1"))
+	assert.True(t, s.CrawledAt().IsZero())
+}
+
+func Test_Snapshot(t *testing.T) {
+	now := time.Now()
+	s := NewSnapshot(now, items(Scope{}, "a", "b"), identity)
+
+	assert.Equal(t, 2, s.Count())
+	assert.Equal(t, []string{"a", "b"}, s.List())
+	assert.Len(t, s.ListExpanded(), 2)
+	assert.Equal(t, "b", s.Get("b")["id"])
+	assert.NotContains(t, s.Get("b"), "Region")
+	assert.Nil(t, s.Get("c"))
+	assert.Equal(t, now, s.CrawledAt())
+}
+
+func Test_Snapshot_List_IsCopy(t *testing.T) {
+	s := NewSnapshot(time.Now(), items(Scope{}, "a"), identity)
+
+	l := s.List()
+	l[0] = "mutated"
+
+	assert.Equal(t, []string{"a"}, s.List())
+}
+
+func Test_Merge(t *testing.T) {
+	earlier := time.Now().Add(-time.Hour)
+	later := time.Now()
+	us := Scope{Region: "us-east-1"}
+	eu := Scope{Region: "eu-west-1"}
+	s := Merge(map[Scope]*Snapshot{
+		us: NewSnapshot(earlier, items(us, "c"), identity),
+		eu: NewSnapshot(later, items(eu, "a", "b"), identity),
+	})
+
+	assert.Equal(t, []string{"a", "b", "c"}, s.List())
+	assert.Equal(t, later, s.CrawledAt())
+	assert.Equal(t, "c", s.Item(2).ID)
+	assert.Equal(t, "us-east-1", s.Item(2).Region)
+	assert.Equal(t, s.Get("c"), s.Expand(2))
+	assert.Equal(t, "us-east-1", s.Get("c")["Region"])
+	for _, d := range s.ListExpanded() {
+		assert.Contains(t, d, "Region")
+	}
+
+	regional := s.Region("us-east-1")
+	assert.Equal(t, []string{"c"}, regional.List())
+	assert.Equal(t, earlier, regional.CrawledAt())
+	assert.Nil(t, s.Region("ap-south-1"))
+}
+
+func Test_Merge_Accounts(t *testing.T) {
+	now := time.Now()
+	a1eu := Scope{Account: "111111111111", Region: "eu-west-1"}
+	a1us := Scope{Account: "111111111111", Region: "us-east-1"}
+	a2eu := Scope{Account: "222222222222", Region: "eu-west-1"}
+	s := Merge(map[Scope]*Snapshot{
+		a2eu: NewSnapshot(now, items(a2eu, "d"), identity),
+		a1us: NewSnapshot(now, items(a1us, "c"), identity),
+		a1eu: NewSnapshot(now, items(a1eu, "a", "b"), identity),
+	})
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, s.List())
+	assert.Equal(t, "222222222222", s.Get("d")["AccountId"])
+	assert.Equal(t, "eu-west-1", s.Get("d")["Region"])
+
+	assert.Equal(t, []string{"a", "b", "c"}, s.Account("111111111111").List())
+	assert.Equal(t, []string{"a", "b", "d"}, s.Region("eu-west-1").List())
+	assert.Equal(t, []string{"d"}, s.Account("222222222222").Region("eu-west-1").List())
+	assert.Nil(t, s.Account("222222222222").Region("us-east-1"))
+	assert.Nil(t, s.Account("333333333333"))
+}
+
+func Test_Snapshot_Lookup(t *testing.T) {
+	us := Scope{Region: "us-east-1"}
+	s := Merge(map[Scope]*Snapshot{
+		eu: NewSnapshot(time.Now(), items(eu, "a", "b"), identity),
+		us: NewSnapshot(time.Now(), items(us, "a"), identity),
+	})
+
+	assert.Equal(t, []int{0, 2}, s.Lookup("a"))
+	assert.Empty(t, s.Lookup("c"))
+	assert.Equal(t, 0, s.IndexOf("a"))
+	assert.Equal(t, Key{Scope: us, ID: "a"}, s.Key(2))
+	assert.Equal(t, 2, s.IndexOfKey(Key{Scope: us, ID: "a"}))
+	assert.Equal(t, -1, s.IndexOfKey(Key{Scope: us, ID: "b"}))
+}
+
+func Test_Snapshot_Generation(t *testing.T) {
+	us := Scope{Region: "us-east-1"}
+	s := Merge(map[Scope]*Snapshot{
+		us: NewSnapshot(time.Now(), items(us, "a"), identity),
+	})
+	g := s.WithGeneration(7)
+
+	assert.Equal(t, uint64(0), s.Generation())
+	assert.Equal(t, uint64(7), g.Generation())
+	assert.Equal(t, uint64(7), g.Region("us-east-1").Generation())
+	assert.Equal(t, s.List(), g.List())
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// A FileStore keeps every key in a file of its own in a directory. Files are
+// replaced atomically, so that a crash while writing never leaves a truncated
+// value behind.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a FileStore, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (f *FileStore) path(key string) string {
+	return filepath.Join(f.dir, url.QueryEscape(key)+".json")
+}
+
+// Get reads the value of a key
+func (f *FileStore) Get(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(f.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Put replaces the value of a key
+func (f *FileStore) Put(key string, value []byte) error {
+	tmp, err := ioutil.TempFile(f.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(key))
+}
@@ -0,0 +1,97 @@
+// Package storage persists what the crawlers have found, so that Melkor can
+// serve it right away after a restart instead of waiting for the first crawl.
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+)
+
+// ErrNotFound is returned by a Store getting a key it does not hold
+var ErrNotFound = errors.New("not found")
+
+// A Store is a key-value store backing the crawlers. It must be safe for
+// concurrent use.
+type Store interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+}
+
+// An Opener opens a Store as configured
+type Opener func(c *config.Config) (Store, error)
+
+var (
+	mu       sync.RWMutex
+	backends = map[string]Opener{
+		"file": func(c *config.Config) (Store, error) { return NewFileStore(c.StoragePath) },
+		"none": func(*config.Config) (Store, error) { return none{}, nil },
+	}
+)
+
+// Register makes a storage backend available by name
+func Register(name string, open Opener) {
+	mu.Lock()
+	defer mu.Unlock()
+	backends[name] = open
+}
+
+// Open opens the configured storage backend
+func Open(c *config.Config) (Store, error) {
+	mu.RLock()
+	open, ok := backends[c.Storage]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q, expected one of %s", c.Storage, names())
+	}
+	return open(c)
+}
+
+func names() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	var n []string
+	for name := range backends {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return strings.Join(n, ", ")
+}
+
+// Save persists the history of a crawler
+func Save(s Store, c melkor.Crawler) error {
+	data, err := json.Marshal(c.History())
+	if err != nil {
+		return err
+	}
+	return s.Put(key(c), data)
+}
+
+// Load warm-starts a crawler from its persisted history. Having nothing
+// persisted is not an error.
+func Load(s Store, c melkor.Crawler) error {
+	data, err := s.Get(key(c))
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.Restore(data)
+}
+
+func key(c melkor.Crawler) string {
+	return strings.ToLower(c.Resource())
+}
+
+// none is the Store used when storage is turned off
+type none struct{}
+
+func (none) Get(string) ([]byte, error) { return nil, ErrNotFound }
+func (none) Put(string, []byte) error   { return nil }
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/mock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "melkor-storage")
+	assert.Nil(t, err)
+	return dir
+}
+
+func Test_FileStore(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	s, err := NewFileStore(filepath.Join(dir, "nested"))
+	assert.Nil(t, err)
+
+	_, err = s.Get("instances")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.Nil(t, s.Put("instances", []byte("one")))
+	assert.Nil(t, s.Put("instances", []byte("two")))
+	assert.Nil(t, s.Put("../escape", []byte("three")))
+
+	data, err := s.Get("instances")
+	assert.Nil(t, err)
+	assert.Equal(t, "two", string(data))
+	data, err = s.Get("../escape")
+	assert.Nil(t, err)
+	assert.Equal(t, "three", string(data))
+
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "nested"))
+	assert.Len(t, files, 2, "no temporary files are left behind")
+}
+
+func Test_Open(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := Open(&config.Config{Storage: "file", StoragePath: dir})
+	assert.Nil(t, err)
+	assert.IsType(t, &FileStore{}, s)
+
+	s, err = Open(&config.Config{Storage: "none"})
+	assert.Nil(t, err)
+	assert.Nil(t, s.Put("instances", []byte("data")))
+	_, err = s.Get("instances")
+	assert.Equal(t, ErrNotFound, err)
+
+	_, err = Open(&config.Config{Storage: "floppy"})
+	assert.EqualError(t, err, `unknown storage backend "floppy", expected one of file, none`)
+}
+
+type memory map[string][]byte
+
+func (m memory) Get(key string) ([]byte, error) {
+	if v, ok := m[key]; ok {
+		return v, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m memory) Put(key string, value []byte) error {
+	m[key] = value
+	return nil
+}
+
+func Test_Register(t *testing.T) {
+	m := memory{}
+	Register("memory", func(*config.Config) (Store, error) { return m, nil })
+
+	s, err := Open(&config.Config{Storage: "memory"})
+	assert.Nil(t, err)
+	assert.Nil(t, s.Put("a", []byte("b")))
+	assert.Equal(t, "b", string(m["a"]))
+}
+
+func Test_SaveLoad(t *testing.T) {
+	m := memory{}
+	mc := &mock.InstanceCrawler{
+		HistoryFn: func() *melkor.History { return melkor.NewHistory(0) },
+	}
+	var restored []byte
+	mc.RestoreFn = func(data []byte) error {
+		restored = data
+		return nil
+	}
+
+	err := Load(m, mc)
+	assert.Nil(t, err)
+	assert.False(t, mc.RestoreFnInvoked, "nothing saved yet")
+
+	err = Save(m, mc)
+	assert.Nil(t, err)
+	assert.Contains(t, m, "mock")
+
+	err = Load(m, mc)
+	assert.Nil(t, err)
+	assert.Equal(t, m["mock"], restored)
+}
+
+type failing struct{}
+
+func (failing) Get(string) ([]byte, error) { return nil, errors.New("disk on fire") }
+func (failing) Put(string, []byte) error   { return errors.New("disk on fire") }
+
+func Test_SaveLoad_Errors(t *testing.T) {
+	mc := &mock.InstanceCrawler{}
+
+	assert.NotNil(t, Save(failing{}, mc))
+	assert.NotNil(t, Load(failing{}, mc))
+	assert.False(t, mc.RestoreFnInvoked)
+}
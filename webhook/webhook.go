@@ -0,0 +1,346 @@
+// Package webhook POSTs the changes found by the crawlers to configured URLs.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/filter"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// queueSize is the number of payloads a webhook may lag behind before
+	// new ones are dead-lettered
+	queueSize = 1000
+	// maxBackoff caps the time waited between attempts
+	maxBackoff = 5 * time.Minute
+)
+
+// deliveries counts the outcome of every delivery attempt per webhook:
+// delivered, retried, failed after the last attempt, or dropped as the queue
+// was full
+var deliveries = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "melkor",
+		Subsystem: "webhook",
+		Name:      "deliveries_total",
+		Help:      "Webhook delivery attempts by webhook and result.",
+	},
+	[]string{"webhook", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(deliveries)
+}
+
+// A Payload is the body POSTed for a single change
+type Payload struct {
+	Delivery string `json:"delivery"`
+	Type     string `json:"type"`
+	Resource string `json:"resource"`
+	melkor.Scope
+	ID        string                 `json:"id"`
+	CrawledAt time.Time              `json:"crawled_at"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// A Dispatcher sends the changes of the crawlers to every matching webhook.
+// Every webhook has a queue of its own, delivered in order, so that a slow
+// webhook does not hold up the others, nor the crawlers.
+//
+// Payloads are signed with HMAC-SHA256 if the webhook has a secret, the hex
+// encoded signature is sent in the X-Melkor-Signature header as
+// sha256=<signature>. Failed deliveries are retried with exponential backoff,
+// and written to the dead-letter log once all attempts have failed.
+type Dispatcher struct {
+	hooks   []*hook
+	client  *http.Client
+	retries int
+	backoff time.Duration
+
+	deadLetterMu sync.Mutex
+	deadLetter   io.Writer
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type hook struct {
+	config.Webhook
+	name      string
+	resources map[string]bool
+	events    map[string]bool
+	filter    *filter.Filter
+	queue     chan Payload
+}
+
+// New creates a Dispatcher for the configured webhooks
+func New(c *config.Config) (*Dispatcher, error) {
+	d := &Dispatcher{
+		client:  &http.Client{Timeout: 10 * time.Second},
+		retries: c.WebhookRetries,
+		backoff: time.Second,
+		done:    make(chan struct{}),
+	}
+	if c.WebhookDeadLetter != "" {
+		f, err := os.OpenFile(c.WebhookDeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("opening dead-letter log: %s", err)
+		}
+		d.deadLetter = f
+	}
+	for _, w := range c.Webhooks {
+		h := &hook{
+			Webhook:   w,
+			name:      w.ID(),
+			resources: set(w.Resources),
+			events:    set(w.Events),
+			queue:     make(chan Payload, queueSize),
+		}
+		if w.Filter != "" {
+			f, err := filter.Parse(w.Filter)
+			if err != nil {
+				return nil, fmt.Errorf("webhook %s: %s", h.name, err)
+			}
+			h.filter = f
+		}
+		d.hooks = append(d.hooks, h)
+	}
+	return d, nil
+}
+
+func set(values []string) map[string]bool {
+	s := make(map[string]bool, len(values))
+	for _, v := range values {
+		s[strings.ToLower(v)] = true
+	}
+	return s
+}
+
+// matches checks whether a change of a resource is to be sent to the webhook
+func (h *hook) matches(resource string, c melkor.Change) bool {
+	if len(h.resources) > 0 && !h.resources[strings.ToLower(resource)] {
+		return false
+	}
+	if len(h.events) > 0 && !h.events[c.Type] {
+		return false
+	}
+	return h.filter == nil || h.filter.Match(c.Data)
+}
+
+// Watch registers the Dispatcher with the crawlers, and starts delivering
+// their changes
+func (d *Dispatcher) Watch(crawlers melkor.Crawlers) {
+	for _, h := range d.hooks {
+		d.wg.Add(1)
+		go d.work(h)
+	}
+	for _, c := range crawlers {
+		resource := c.Resource()
+		c.OnChange(func(cs melkor.Changeset) {
+			d.publish(resource, cs)
+		})
+	}
+}
+
+// Stop stops delivering, abandoning any retries in progress
+func (d *Dispatcher) Stop() {
+	close(d.done)
+	d.wg.Wait()
+	if c, ok := d.deadLetter.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// publish queues the changes of a crawl for every matching webhook. The first
+// crawl of a scope is skipped, as it would announce every resource as added
+// whenever Melkor starts.
+func (d *Dispatcher) publish(resource string, cs melkor.Changeset) {
+	if cs.Initial {
+		logrus.WithFields(logrus.Fields{"resource": resource, "scope": cs.Scope.String()}).Debug("Not sending initial crawl to webhooks")
+		return
+	}
+	for _, c := range cs.Changes {
+		for _, h := range d.hooks {
+			if !h.matches(resource, c) {
+				continue
+			}
+			p := Payload{
+				Delivery:  deliveryID(),
+				Type:      c.Type,
+				Resource:  resource,
+				Scope:     c.Scope,
+				ID:        c.ID,
+				CrawledAt: cs.CrawledAt,
+				Data:      c.Data,
+			}
+			select {
+			case h.queue <- p:
+			default:
+				deliveries.WithLabelValues(h.name, "dropped").Inc()
+				d.writeDeadLetter(h, p, 0, "queue full")
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) work(h *hook) {
+	defer d.wg.Done()
+	for {
+		select {
+		case p := <-h.queue:
+			d.deliver(h, p)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// deliver sends a payload, retrying with exponential backoff until it is
+// accepted, refused for good, or out of attempts
+func (d *Dispatcher) deliver(h *hook, p Payload) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		d.writeDeadLetter(h, p, 0, err.Error())
+		return
+	}
+	backoff := d.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(h, p, body)
+		if err == nil {
+			deliveries.WithLabelValues(h.name, "delivered").Inc()
+			return
+		}
+		logger := logrus.WithError(err).WithFields(logrus.Fields{
+			"webhook":  h.name,
+			"delivery": p.Delivery,
+			"attempt":  attempt,
+		})
+		if !retry || attempt > d.retries {
+			logger.Error("Webhook delivery failed")
+			deliveries.WithLabelValues(h.name, "failed").Inc()
+			d.writeDeadLetter(h, p, attempt, err.Error())
+			return
+		}
+		logger.Warn("Webhook delivery failed, retrying")
+		deliveries.WithLabelValues(h.name, "retried").Inc()
+		select {
+		case <-time.After(backoff):
+		case <-d.done:
+			return
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// post makes a single delivery attempt. Errors are worth retrying unless the
+// webhook refused the payload itself.
+func (d *Dispatcher) post(h *hook, p Payload, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "melkor")
+	req.Header.Set("X-Melkor-Event", p.Type)
+	req.Header.Set("X-Melkor-Delivery", p.Delivery)
+	if h.Secret != "" {
+		req.Header.Set("X-Melkor-Signature", "sha256="+Sign([]byte(h.Secret), body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected status %s", resp.Status)
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return true, err
+	case resp.StatusCode >= 500:
+		return true, err
+	}
+	return false, err
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of a payload
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// A deadLetter is a payload which could not be delivered, as written to the
+// dead-letter log
+type deadLetter struct {
+	Time     time.Time `json:"time"`
+	Webhook  string    `json:"webhook"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Payload  Payload   `json:"payload"`
+}
+
+// writeDeadLetter appends an undeliverable payload to the dead-letter log, one
+// JSON object per line, or logs it if there is no dead-letter log
+func (d *Dispatcher) writeDeadLetter(h *hook, p Payload, attempts int, reason string) {
+	dl := deadLetter{
+		Time:     time.Now(),
+		Webhook:  h.name,
+		Attempts: attempts,
+		Error:    reason,
+		Payload:  p,
+	}
+	if d.deadLetter == nil {
+		logrus.WithFields(logrus.Fields{
+			"webhook":  h.name,
+			"delivery": p.Delivery,
+			"error":    reason,
+		}).Error("Dead-lettered webhook delivery")
+		return
+	}
+	line, err := json.Marshal(dl)
+	if err != nil {
+		logrus.WithError(err).Error("Encoding dead letter")
+		return
+	}
+
+	d.deadLetterMu.Lock()
+	defer d.deadLetterMu.Unlock()
+	if _, err := d.deadLetter.Write(append(line, '\n')); err != nil {
+		logrus.WithError(err).Error("Writing dead letter")
+	}
+}
+
+// deliveryID returns a random id for a delivery, allowing the receiver to
+// detect duplicates
+func deliveryID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,256 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alde/melkor"
+	"github.com/alde/melkor/config"
+	"github.com/alde/melkor/mock"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+var eu = melkor.Scope{Account: "111111111111", Region: "eu-west-1"}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver answers with the given statuses in turn, then 200, passing on every
+// request received
+func receiver(statuses ...int) (*httptest.Server, <-chan received) {
+	var mu sync.Mutex
+	ch := make(chan received, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		ch <- received{header: r.Header, body: body}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(statuses) > 0 {
+			w.WriteHeader(statuses[0])
+			statuses = statuses[1:]
+		}
+	}))
+	return srv, ch
+}
+
+func next(t *testing.T, ch <-chan received) received {
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a delivery")
+	}
+	return received{}
+}
+
+func setup(t *testing.T, c *config.Config) (*Dispatcher, *mock.InstanceCrawler, *bytes.Buffer) {
+	d, err := New(c)
+	assert.Nil(t, err)
+	d.backoff = time.Millisecond
+	dl := &bytes.Buffer{}
+	d.deadLetter = dl
+	mc := &mock.InstanceCrawler{ResourceFn: func() string { return "Instances" }}
+	d.Watch(melkor.Crawlers{"Instances": mc})
+	return d, mc, dl
+}
+
+func changeset(changes ...melkor.Change) melkor.Changeset {
+	return melkor.Changeset{
+		Scope:     eu,
+		CrawledAt: time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC),
+		Changes:   changes,
+	}
+}
+
+func change(typ, id, env string) melkor.Change {
+	return melkor.Change{
+		Scope: eu,
+		Type:  typ,
+		ID:    id,
+		Data: map[string]interface{}{
+			"InstanceId": id,
+			"Tags":       map[string]interface{}{"Environment": env},
+		},
+	}
+}
+
+func count(name, result string) float64 {
+	m := &dto.Metric{}
+	deliveries.WithLabelValues(name, result).Write(m)
+	return m.GetCounter().GetValue()
+}
+
+func Test_Deliver(t *testing.T) {
+	srv, ch := receiver()
+	defer srv.Close()
+	d, mc, _ := setup(t, &config.Config{Webhooks: []config.Webhook{
+		{Name: "deliver", URL: srv.URL, Secret: "s3cret"},
+	}})
+	defer d.Stop()
+
+	mc.Notify(changeset(change(melkor.Added, "i-0", "production")))
+
+	r := next(t, ch)
+	assert.Equal(t, "application/json", r.header.Get("Content-Type"))
+	assert.Equal(t, "added", r.header.Get("X-Melkor-Event"))
+	assert.Equal(t, "sha256="+Sign([]byte("s3cret"), r.body), r.header.Get("X-Melkor-Signature"))
+
+	var p map[string]interface{}
+	err := json.Unmarshal(r.body, &p)
+	assert.Nil(t, err)
+	assert.Equal(t, r.header.Get("X-Melkor-Delivery"), p["delivery"])
+	assert.NotEmpty(t, p["delivery"])
+	assert.Equal(t, "added", p["type"])
+	assert.Equal(t, "Instances", p["resource"])
+	assert.Equal(t, "111111111111", p["account"])
+	assert.Equal(t, "eu-west-1", p["region"])
+	assert.Equal(t, "i-0", p["id"])
+	assert.Equal(t, "2017-03-01T12:00:00Z", p["crawled_at"])
+	assert.Equal(t, "i-0", p["data"].(map[string]interface{})["InstanceId"])
+}
+
+func Test_Deliver_Unsigned(t *testing.T) {
+	srv, ch := receiver()
+	defer srv.Close()
+	d, mc, _ := setup(t, &config.Config{Webhooks: []config.Webhook{{URL: srv.URL}}})
+	defer d.Stop()
+
+	mc.Notify(changeset(change(melkor.Removed, "i-0", "production")))
+
+	r := next(t, ch)
+	assert.Equal(t, "removed", r.header.Get("X-Melkor-Event"))
+	assert.Empty(t, r.header.Get("X-Melkor-Signature"))
+}
+
+func Test_Deliver_Matching(t *testing.T) {
+	srv, ch := receiver()
+	defer srv.Close()
+	d, mc, _ := setup(t, &config.Config{Webhooks: []config.Webhook{
+		{URL: srv.URL + "/volumes", Resources: []string{"volumes"}},
+		{URL: srv.URL + "/production", Resources: []string{"instances"}, Filter: "(Tags.Environment:production)"},
+		{URL: srv.URL + "/added", Events: []string{"added"}, Filter: "(Tags.Environment:test)"},
+	}})
+	defer d.Stop()
+
+	mc.Notify(melkor.Changeset{Scope: eu, Initial: true, Changes: []melkor.Change{
+		change(melkor.Added, "i-0", "production"),
+	}})
+	mc.Notify(changeset(
+		change(melkor.Modified, "i-1", "test"),
+		change(melkor.Modified, "i-2", "production"),
+		change(melkor.Added, "i-3", "test"),
+	))
+
+	var ids []string
+	for n := 0; n < 2; n++ {
+		var p Payload
+		json.Unmarshal(next(t, ch).body, &p)
+		ids = append(ids, p.ID)
+	}
+	sort.Strings(ids)
+	assert.Equal(t, []string{"i-2", "i-3"}, ids)
+	select {
+	case r := <-ch:
+		t.Errorf("unexpected delivery %s", r.body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func Test_Deliver_Retry(t *testing.T) {
+	srv, ch := receiver(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer srv.Close()
+	d, mc, dl := setup(t, &config.Config{
+		WebhookRetries: 2,
+		Webhooks:       []config.Webhook{{Name: "retry", URL: srv.URL}},
+	})
+
+	mc.Notify(changeset(change(melkor.Added, "i-0", "production")))
+
+	first := next(t, ch)
+	next(t, ch)
+	last := next(t, ch)
+	assert.Equal(t, first.header.Get("X-Melkor-Delivery"), last.header.Get("X-Melkor-Delivery"))
+	assert.Equal(t, first.body, last.body)
+
+	d.Stop()
+	assert.Equal(t, 2.0, count("retry", "retried"))
+	assert.Equal(t, 1.0, count("retry", "delivered"))
+	assert.Empty(t, dl.String())
+}
+
+func Test_Deliver_DeadLetter(t *testing.T) {
+	srv, ch := receiver(500, 500, 500, 500)
+	defer srv.Close()
+	d, mc, dl := setup(t, &config.Config{
+		WebhookRetries: 2,
+		Webhooks:       []config.Webhook{{Name: "dead", URL: srv.URL}},
+	})
+
+	mc.Notify(changeset(change(melkor.Added, "i-0", "production")))
+	for n := 0; n < 3; n++ {
+		next(t, ch)
+	}
+
+	// wait for the dead letter to be written
+	for n := 0; n < 100 && count("dead", "failed") == 0; n++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.Stop()
+
+	assert.Equal(t, 2.0, count("dead", "retried"))
+	assert.Equal(t, 1.0, count("dead", "failed"))
+	var letter map[string]interface{}
+	err := json.Unmarshal(dl.Bytes(), &letter)
+	assert.Nil(t, err)
+	assert.Equal(t, "dead", letter["webhook"])
+	assert.Equal(t, 3.0, letter["attempts"])
+	assert.Contains(t, letter["error"], "500")
+	assert.Equal(t, "i-0", letter["payload"].(map[string]interface{})["id"])
+}
+
+func Test_Deliver_Refused(t *testing.T) {
+	srv, ch := receiver(http.StatusBadRequest)
+	defer srv.Close()
+	d, mc, dl := setup(t, &config.Config{
+		WebhookRetries: 5,
+		Webhooks:       []config.Webhook{{Name: "refused", URL: srv.URL}},
+	})
+
+	mc.Notify(changeset(change(melkor.Added, "i-0", "production")))
+	next(t, ch)
+	for n := 0; n < 100 && count("refused", "failed") == 0; n++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.Stop()
+
+	assert.Equal(t, 0.0, count("refused", "retried"))
+	assert.Equal(t, 1.0, count("refused", "failed"))
+	assert.Equal(t, 1, strings.Count(dl.String(), "\n"))
+}
+
+func Test_New_Errors(t *testing.T) {
+	_, err := New(&config.Config{Webhooks: []config.Webhook{{URL: "http://localhost", Filter: "broken"}}})
+	assert.NotNil(t, err)
+
+	_, err = New(&config.Config{WebhookDeadLetter: "/nonexistent/dir/dead-letter.log"})
+	assert.NotNil(t, err)
+}
+
+func Test_Sign(t *testing.T) {
+	// echo -n '{"a":1}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"aa9e2e3575f5d7098b6caccd790888c36d5fdb63342a73bada2d6a51747a8494",
+		Sign([]byte("secret"), []byte(`{"a":1}`)))
+}